@@ -0,0 +1,191 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrMaxIterations is returned by Agent.Run when the model is still calling
+// tools after the configured number of iterations.
+var ErrMaxIterations = errors.New("llm: agent exceeded max iterations")
+
+// ErrMaxCost is returned by Agent.Run when the total estimated cost of the
+// conversation reaches the configured limit.
+var ErrMaxCost = errors.New("llm: agent exceeded max cost")
+
+// ErrMaxTokens is returned by Agent.Run when a response stops at the
+// MaxTokens limit, since its text or tool call input may be cut off.
+var ErrMaxTokens = errors.New("llm: agent response reached max tokens")
+
+// toolHandler runs a registered tool on raw JSON input.
+type toolHandler func(ctx context.Context, input json.RawMessage) (string, error)
+
+// Agent drives a tool-use conversation: it sends the registered tools with
+// each request, runs the Go handler for every tool call the model makes,
+// returns the results to the model, and repeats until the model stops
+// calling tools.
+//
+//	agent := gov.NewAgent(llm.WithMaxIterations(5))
+//	llm.AddTool(agent, "lookup_gene", "Look up a gene by symbol",
+//		func(ctx context.Context, in GeneQuery) (string, error) { ... })
+//	result, err := agent.Run(ctx, &llm.InvokeRequest{...})
+type Agent struct {
+	gov           *Governor
+	tools         []Tool
+	handlers      map[string]toolHandler
+	maxIterations int
+	maxCostUsd    float64
+}
+
+// AgentOption configures an Agent.
+type AgentOption func(*Agent)
+
+// WithMaxIterations caps the number of model invocations in a single Run.
+// The default is 10.
+func WithMaxIterations(n int) AgentOption {
+	return func(a *Agent) {
+		a.maxIterations = n
+	}
+}
+
+// WithMaxCost caps the total EstimatedCostUsd of a single Run. Zero (the
+// default) means no limit.
+func WithMaxCost(usd float64) AgentOption {
+	return func(a *Agent) {
+		a.maxCostUsd = usd
+	}
+}
+
+// NewAgent creates an Agent that invokes models through this Governor.
+func (g *Governor) NewAgent(opts ...AgentOption) *Agent {
+	a := &Agent{
+		gov:           g,
+		handlers:      map[string]toolHandler{},
+		maxIterations: 10,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// AddTool registers a Go handler as a tool on the agent. The tool's input
+// schema is derived from T with SchemaFor, and the model's input is decoded
+// into T before fn is called. The string fn returns is sent back to the
+// model as the tool result; an error is sent back as a failed tool result.
+func AddTool[T any](a *Agent, name, description string, fn func(ctx context.Context, input T) (string, error)) {
+	a.tools = append(a.tools, Tool{
+		Name:        name,
+		Description: description,
+		InputSchema: SchemaFor[T](),
+	})
+	a.handlers[name] = func(ctx context.Context, raw json.RawMessage) (string, error) {
+		var input T
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &input); err != nil {
+				return "", fmt.Errorf("invalid input for tool %s: %w", name, err)
+			}
+		}
+		return fn(ctx, input)
+	}
+}
+
+// Tools returns the tool definitions registered on the agent.
+func (a *Agent) Tools() []Tool {
+	out := make([]Tool, len(a.tools))
+	copy(out, a.tools)
+	return out
+}
+
+// AgentResult is the outcome of an Agent run.
+type AgentResult struct {
+	// Messages is the full transcript, starting with the request's
+	// messages and ending with the model's final reply.
+	Messages []Message
+
+	// Responses holds every model response, one per iteration.
+	Responses []*InvokeResponse
+
+	// Usage is the total token usage and estimated cost of the run.
+	Usage UsageInfo
+}
+
+// Final returns the last model response, or nil if none was received.
+func (r *AgentResult) Final() *InvokeResponse {
+	if len(r.Responses) == 0 {
+		return nil
+	}
+	return r.Responses[len(r.Responses)-1]
+}
+
+// Text returns the text of the final model response.
+func (r *AgentResult) Text() string {
+	if final := r.Final(); final != nil {
+		return final.Text()
+	}
+	return ""
+}
+
+// Run sends req with the agent's tools appended and loops until the model
+// replies without calling a tool. It stops with ErrMaxTokens if a response
+// is cut off at MaxTokens, and with ErrMaxCost once the run's cost reaches
+// the limit, including on the final turn. The result is returned even when
+// Run fails, so the transcript up to the failure is available.
+func (a *Agent) Run(ctx context.Context, req *InvokeRequest) (*AgentResult, error) {
+	result := &AgentResult{
+		Messages: append([]Message(nil), req.Messages...),
+	}
+
+	tools := append(append([]Tool(nil), req.Tools...), a.tools...)
+	for i := 0; i < a.maxIterations; i++ {
+		turn := *req
+		turn.Messages = result.Messages
+		turn.Tools = tools
+
+		resp, err := a.gov.Invoke(ctx, &turn)
+		if err != nil {
+			return result, err
+		}
+		result.Responses = append(result.Responses, resp)
+		result.Messages = append(result.Messages, resp.Message())
+		result.Usage.InputTokens += resp.Usage.InputTokens
+		result.Usage.OutputTokens += resp.Usage.OutputTokens
+		result.Usage.CacheCreationInputTokens += resp.Usage.CacheCreationInputTokens
+		result.Usage.CacheReadInputTokens += resp.Usage.CacheReadInputTokens
+		result.Usage.EstimatedCostUsd += resp.Usage.EstimatedCostUsd
+
+		if resp.StopReason == "max_tokens" {
+			return result, ErrMaxTokens
+		}
+		if a.maxCostUsd > 0 && result.Usage.EstimatedCostUsd >= a.maxCostUsd {
+			return result, ErrMaxCost
+		}
+		calls := resp.ToolCalls()
+		if len(calls) == 0 {
+			return result, nil
+		}
+
+		results := make([]ContentBlock, 0, len(calls))
+		for _, call := range calls {
+			results = append(results, a.runTool(ctx, call))
+		}
+		result.Messages = append(result.Messages, UserMessage(results...))
+	}
+
+	return result, ErrMaxIterations
+}
+
+// runTool executes a single tool call and returns its tool_result block.
+func (a *Agent) runTool(ctx context.Context, call ResponseContent) ContentBlock {
+	handler, ok := a.handlers[call.Name]
+	if !ok {
+		return ToolErrorBlock(call.ID, fmt.Sprintf("unknown tool: %s", call.Name))
+	}
+	out, err := handler(ctx, call.Input)
+	if err != nil {
+		return ToolErrorBlock(call.ID, err.Error())
+	}
+	return ToolResultBlock(call.ID, out)
+}
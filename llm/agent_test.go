@@ -0,0 +1,213 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type cityInput struct {
+	City  string `json:"city" description:"City name"`
+	Units string `json:"units,omitempty" enum:"c,f"`
+}
+
+func TestAgent_RunsToolsUntilEndTurn(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponses([]*InvokeResponse{
+		withUsage(MockToolCall("toolu_1", "get_weather", cityInput{City: "Paris"}), 0.01),
+		withUsage(MockToolCall("toolu_2", "get_weather", cityInput{City: "Oslo"}), 0.01),
+		withUsage(&InvokeResponse{Content: []ResponseContent{{Type: "text", Text: "Paris is warmer."}}, StopReason: "end_turn"}, 0.02),
+	})
+	g := NewGovernor(WithBackend(mock))
+
+	var cities []string
+	agent := g.NewAgent()
+	AddTool(agent, "get_weather", "Get the weather", func(_ context.Context, in cityInput) (string, error) {
+		cities = append(cities, in.City)
+		return in.City + ": 20C", nil
+	})
+
+	result, err := agent.Run(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("Compare Paris and Oslo"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cities) != 2 || cities[0] != "Paris" || cities[1] != "Oslo" {
+		t.Errorf("unexpected handler calls: %v", cities)
+	}
+	if result.Text() != "Paris is warmer." {
+		t.Errorf("unexpected final text %q", result.Text())
+	}
+	// user, (assistant tool_use, user tool_result) x2, assistant final
+	if len(result.Messages) != 6 {
+		t.Fatalf("expected 6 transcript messages, got %d", len(result.Messages))
+	}
+	toolResult := result.Messages[2].Content[0]
+	if toolResult.Type != "tool_result" || toolResult.ToolUseID != "toolu_1" || toolResult.Content[0].Text != "Paris: 20C" {
+		t.Errorf("unexpected tool result: %+v", toolResult)
+	}
+	if result.Usage.EstimatedCostUsd < 0.0399 || result.Usage.EstimatedCostUsd > 0.0401 {
+		t.Errorf("expected total cost 0.04, got %f", result.Usage.EstimatedCostUsd)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("expected 3 invocations, got %d", len(calls))
+	}
+	if len(calls[0].Tools) != 1 || calls[0].Tools[0].Name != "get_weather" {
+		t.Errorf("expected tool definition on request, got %+v", calls[0].Tools)
+	}
+	if len(calls[2].Messages) != 5 {
+		t.Errorf("expected final request to carry 5 messages, got %d", len(calls[2].Messages))
+	}
+}
+
+func TestAgent_HandlerErrorsAndUnknownTools(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponses([]*InvokeResponse{
+		{
+			Content: []ResponseContent{
+				MockToolCall("toolu_1", "get_weather", cityInput{City: "Nowhere"}).Content[0],
+				MockToolCall("toolu_2", "launch_rocket", nil).Content[0],
+			},
+			StopReason: "tool_use",
+		},
+		{Content: []ResponseContent{{Type: "text", Text: "done"}}, StopReason: "end_turn"},
+	})
+	g := NewGovernor(WithBackend(mock))
+	agent := g.NewAgent()
+	AddTool(agent, "get_weather", "Get the weather", func(_ context.Context, in cityInput) (string, error) {
+		return "", errors.New("city not found")
+	})
+
+	result, err := agent.Run(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("go"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := result.Messages[2].Content
+	if len(results) != 2 {
+		t.Fatalf("expected 2 tool results, got %d", len(results))
+	}
+	if !results[0].IsError || results[0].Content[0].Text != "city not found" {
+		t.Errorf("unexpected handler error result: %+v", results[0])
+	}
+	if !results[1].IsError || results[1].Content[0].Text != "unknown tool: launch_rocket" {
+		t.Errorf("unexpected unknown tool result: %+v", results[1])
+	}
+}
+
+func TestAgent_MaxIterations(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(MockToolCall("toolu_1", "noop", struct{}{}))
+	g := NewGovernor(WithBackend(mock))
+	agent := g.NewAgent(WithMaxIterations(3))
+	AddTool(agent, "noop", "Do nothing", func(context.Context, struct{}) (string, error) { return "ok", nil })
+
+	result, err := agent.Run(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("loop"))},
+	})
+	if !errors.Is(err, ErrMaxIterations) {
+		t.Fatalf("expected ErrMaxIterations, got %v", err)
+	}
+	if len(result.Responses) != 3 {
+		t.Errorf("expected 3 responses, got %d", len(result.Responses))
+	}
+}
+
+func TestAgent_MaxCost(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(withUsage(MockToolCall("toolu_1", "noop", struct{}{}), 0.5))
+	g := NewGovernor(WithBackend(mock))
+	agent := g.NewAgent(WithMaxCost(1.0))
+	AddTool(agent, "noop", "Do nothing", func(context.Context, struct{}) (string, error) { return "ok", nil })
+
+	result, err := agent.Run(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("loop"))},
+	})
+	if !errors.Is(err, ErrMaxCost) {
+		t.Fatalf("expected ErrMaxCost, got %v", err)
+	}
+	if len(result.Responses) != 2 {
+		t.Errorf("expected to stop after 2 responses, got %d", len(result.Responses))
+	}
+}
+
+func TestAgent_MaxCostOnFinalTurn(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(withUsage(&InvokeResponse{Content: []ResponseContent{{Type: "text", Text: "done"}}, StopReason: "end_turn"}, 2.0))
+	g := NewGovernor(WithBackend(mock))
+	agent := g.NewAgent(WithMaxCost(1.0))
+
+	result, err := agent.Run(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("go"))},
+	})
+	if !errors.Is(err, ErrMaxCost) {
+		t.Fatalf("expected ErrMaxCost, got %v", err)
+	}
+	if result.Text() != "done" {
+		t.Errorf("expected the final reply in the result, got %q", result.Text())
+	}
+}
+
+func TestAgent_StopsAtMaxTokens(t *testing.T) {
+	truncated := MockToolCall("toolu_1", "noop", struct{}{})
+	truncated.StopReason = "max_tokens"
+	mock := NewMockBackend()
+	mock.SetResponse(truncated)
+	g := NewGovernor(WithBackend(mock))
+	agent := g.NewAgent()
+	ran := false
+	AddTool(agent, "noop", "Do nothing", func(context.Context, struct{}) (string, error) {
+		ran = true
+		return "ok", nil
+	})
+
+	_, err := agent.Run(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("go"))},
+	})
+	if !errors.Is(err, ErrMaxTokens) {
+		t.Fatalf("expected ErrMaxTokens, got %v", err)
+	}
+	if ran {
+		t.Error("expected no tool to run on a truncated response")
+	}
+}
+
+func TestAgent_SumsCacheUsage(t *testing.T) {
+	mock := NewMockBackend()
+	first := MockToolCall("toolu_1", "noop", struct{}{})
+	first.Usage = UsageInfo{InputTokens: 10, CacheCreationInputTokens: 500}
+	final := &InvokeResponse{Content: []ResponseContent{{Type: "text", Text: "done"}}, StopReason: "end_turn"}
+	final.Usage = UsageInfo{InputTokens: 20, CacheReadInputTokens: 500}
+	mock.SetResponses([]*InvokeResponse{first, final})
+	g := NewGovernor(WithBackend(mock))
+	agent := g.NewAgent()
+	AddTool(agent, "noop", "Do nothing", func(context.Context, struct{}) (string, error) { return "ok", nil })
+
+	result, err := agent.Run(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("go"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := result.Usage
+	if u.InputTokens != 30 || u.CacheCreationInputTokens != 500 || u.CacheReadInputTokens != 500 {
+		t.Errorf("unexpected usage totals %+v", u)
+	}
+}
+
+func withUsage(resp *InvokeResponse, costUsd float64) *InvokeResponse {
+	resp.Usage = UsageInfo{InputTokens: 100, OutputTokens: 10, EstimatedCostUsd: costUsd}
+	return resp
+}
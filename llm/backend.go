@@ -1,20 +1,62 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 // Backend is the interface that all LLM backends must satisfy.
 type Backend interface {
 	Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error)
+	InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error)
 	CheckBudget(ctx context.Context, executionRunID string) (*CheckBudgetResponse, error)
 	ListModels(ctx context.Context) (*ListModelsResponse, error)
+	CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error)
 }
 
-// allModels returns ModelInfo for all known models.
+// BatchBackend is implemented by backends that also support message
+// batches. The Governor's batch methods fail with ErrBatchesNotSupported
+// for backends that do not implement it.
+type BatchBackend interface {
+	Backend
+	SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error)
+	BatchStatus(ctx context.Context, batchID string) (*Batch, error)
+	BatchResults(ctx context.Context, batchID string) (*BatchResults, error)
+}
+
+// ErrBatchesNotSupported is returned by batch calls to a backend that
+// does not implement BatchBackend.
+var ErrBatchesNotSupported = errors.New("llm: backend does not support batches")
+
+// batchBackend returns b as a BatchBackend, or an error matching
+// ErrBatchesNotSupported.
+func batchBackend(b Backend) (BatchBackend, error) {
+	bb, ok := b.(BatchBackend)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrBatchesNotSupported, b)
+	}
+	return bb, nil
+}
+
+// unwrapBackend follows the Unwrap methods of backend decorators such as
+// RetryBackend down to the backend that actually serves requests.
+func unwrapBackend(b Backend) Backend {
+	for {
+		w, ok := b.(interface{ Unwrap() Backend })
+		if !ok {
+			return b
+		}
+		b = w.Unwrap()
+	}
+}
+
+// allModels returns ModelInfo for all registered models.
 func allModels() []ModelInfo {
-	return []ModelInfo{
-		{ModelID: ModelHaiku45, Status: "available"},
-		{ModelID: ModelSonnet45, Status: "available"},
-		{ModelID: ModelSonnet46, Status: "available"},
-		{ModelID: ModelSonnet4, Status: "available"},
+	specs := Models()
+	models := make([]ModelInfo, len(specs))
+	for i, spec := range specs {
+		models[i] = ModelInfo{ModelID: spec.ID, Status: "available"}
 	}
+	return models
 }
\ No newline at end of file
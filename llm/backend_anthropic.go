@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -9,26 +10,22 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// modelMap maps Bedrock inference profile IDs to Anthropic API model names.
-var modelMap = map[string]string{
-	ModelHaiku45:  "claude-haiku-4-5-20251001",
-	ModelSonnet45: "claude-sonnet-4-5-20250929",
-	ModelSonnet46: "claude-sonnet-4-6",
-	ModelSonnet4:  "claude-sonnet-4-20250514",
-}
-
-// MapModel converts a Bedrock inference profile ID to an Anthropic API model name.
+// MapModel converts a Bedrock inference profile ID to an Anthropic API
+// model name, using the model registry (see RegisterModel).
 func MapModel(bedrockID string) string {
-	if name, ok := modelMap[bedrockID]; ok {
-		return name
+	if spec, ok := LookupModel(bedrockID); ok && spec.AnthropicName != "" {
+		return spec.AnthropicName
 	}
-	if strings.HasPrefix(bedrockID, "us.anthropic.") {
-		return strings.TrimPrefix(bedrockID, "us.anthropic.")
+	if name, ok := strings.CutPrefix(stripRegionPrefix(bedrockID), "anthropic."); ok {
+		return name
 	}
 	return bedrockID
 }
@@ -90,24 +87,70 @@ func NewAnthropicBackend(opts ...AnthropicOption) *AnthropicBackend {
 type anthropicRequest struct {
 	Model       string                   `json:"model"`
 	MaxTokens   int32                    `json:"max_tokens"`
-	System      string                   `json:"system,omitempty"`
+	System      interface{}              `json:"system,omitempty"`
 	Temperature float32                  `json:"temperature,omitempty"`
 	Messages    []map[string]interface{} `json:"messages"`
+	Stream      bool                     `json:"stream,omitempty"`
+	Tools       []anthropicTool          `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice     `json:"tool_choice,omitempty"`
+	Thinking    *anthropicThinking       `json:"thinking,omitempty"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int32  `json:"budget_tokens"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type                   string `json:"type"`
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
 }
 
 type anthropicResponse struct {
 	Content    []anthropicContentBlock `json:"content"`
-	Model      string                 `json:"model"`
-	StopReason string                 `json:"stop_reason"`
-	Usage      struct {
-		InputTokens  int64 `json:"input_tokens"`
-		OutputTokens int64 `json:"output_tokens"`
-	} `json:"usage"`
+	Model      string                  `json:"model"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      anthropicUsage          `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	CacheCreation            struct {
+		Ephemeral1hInputTokens int64 `json:"ephemeral_1h_input_tokens"`
+	} `json:"cache_creation"`
+}
+
+// usageInfo converts API usage to UsageInfo, pricing it for model.
+func (u anthropicUsage) usageInfo(model string) UsageInfo {
+	usage := UsageInfo{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+	}
+	usage.EstimatedCostUsd = estimateCostUsd(model, usage, u.CacheCreation.Ephemeral1hInputTokens)
+	return usage
 }
 
 type anthropicContentBlock struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	Thinking  string          `json:"thinking"`
+	Signature string          `json:"signature"`
+	Data      string          `json:"data"`
 }
 
 type anthropicErrorResponse struct {
@@ -118,31 +161,139 @@ type anthropicErrorResponse struct {
 }
 
 func (b *AnthropicBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
-	model := MapModel(req.Model)
+	resp, err := b.post(ctx, "/v1/messages", b.buildRequest(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var apiResp anthropicResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Anthropic response: %w", err)
+	}
+	return apiResp.invokeResponse(req.Model), nil
+}
+
+// invokeResponse converts a Messages API response to an InvokeResponse,
+// pricing its usage for requestModel.
+func (r *anthropicResponse) invokeResponse(requestModel string) *InvokeResponse {
+	content := make([]ResponseContent, 0, len(r.Content))
+	for _, block := range r.Content {
+		switch block.Type {
+		case "text":
+			content = append(content, ResponseContent{Type: "text", Text: block.Text})
+		case "tool_use":
+			content = append(content, ResponseContent{Type: "tool_use", ID: block.ID, Name: block.Name, Input: block.Input})
+		case "thinking":
+			content = append(content, ResponseContent{Type: "thinking", Text: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			content = append(content, ResponseContent{Type: "redacted_thinking", Data: block.Data})
+		}
+	}
+
+	return &InvokeResponse{
+		Content:    content,
+		Model:      r.Model,
+		Usage:      r.Usage.usageInfo(requestModel),
+		StopReason: r.StopReason,
+	}
+}
+
+// InvokeStream calls the Messages API with server-sent events enabled and
+// emits text deltas as they arrive.
+func (b *AnthropicBackend) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	resp, err := b.post(ctx, "/v1/messages", b.buildRequest(req, true))
+	if err != nil {
+		return nil, err
+	}
+
+	return newStream(ctx, func(ctx context.Context, emit func(StreamEvent) error) error {
+		defer resp.Body.Close()
+		// Closing the body unblocks a read from a stalled connection when
+		// the stream is closed or ctx is cancelled.
+		stop := context.AfterFunc(ctx, func() { resp.Body.Close() })
+		defer stop()
+		if err := readAnthropicStream(resp.Body, req.Model, emit); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		return nil
+	}), nil
+}
+
+// buildRequest converts an SDK request into a Messages API request.
+func (b *AnthropicBackend) buildRequest(req *InvokeRequest, stream bool) anthropicRequest {
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
-		maxTokens = 1024
+		maxTokens = defaultMaxTokens
 	}
 
 	apiReq := anthropicRequest{
-		Model:       model,
+		Model:       MapModel(req.Model),
 		MaxTokens:   maxTokens,
-		System:      req.System,
 		Temperature: req.Temperature,
 		Messages:    convertMessages(req.Messages),
+		Stream:      stream,
+	}
+	switch {
+	case req.SystemCacheControl != nil:
+		// A cacheable system prompt must be sent as a list of text blocks.
+		apiReq.System = []interface{}{
+			convertContentBlock(ContentBlock{Type: "text", Text: req.System, CacheControl: req.SystemCacheControl}),
+		}
+	case req.System != "":
+		apiReq.System = req.System
+	}
+	for _, tool := range req.Tools {
+		apiReq.Tools = append(apiReq.Tools, anthropicTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	if req.Thinking != nil {
+		apiReq.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: req.Thinking.BudgetTokens}
+	}
+	if req.ToolChoice != nil {
+		apiReq.ToolChoice = &anthropicToolChoice{
+			Type:                   req.ToolChoice.Type,
+			Name:                   req.ToolChoice.Name,
+			DisableParallelToolUse: req.ToolChoice.DisableParallelToolUse,
+		}
 	}
+	return apiReq
+}
 
+// post sends a request to an Anthropic API path. On success the caller
+// owns the response body; non-200 responses are converted to errors.
+func (b *AnthropicBackend) post(ctx context.Context, path string, apiReq interface{}) (*http.Response, error) {
 	body, err := json.Marshal(apiReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal Anthropic request: %w", err)
 	}
+	return b.send(ctx, "POST", path, bytes.NewReader(body))
+}
+
+// get is like post for requests without a body.
+func (b *AnthropicBackend) get(ctx context.Context, path string) (*http.Response, error) {
+	return b.send(ctx, "GET", path, nil)
+}
 
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(body))
+func (b *AnthropicBackend) send(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, "https://api.anthropic.com"+path, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
 	httpReq.Header.Set("x-api-key", b.apiKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 
@@ -150,42 +301,191 @@ func (b *AnthropicBackend) Invoke(ctx context.Context, req *InvokeRequest) (*Inv
 	if err != nil {
 		return nil, fmt.Errorf("Anthropic API request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
 		var errResp anthropicErrorResponse
-		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
-			return nil, fmt.Errorf("Anthropic API error (%s): %s", errResp.Error.Type, errResp.Error.Message)
+		if json.Unmarshal(respBody, &errResp) != nil || errResp.Error.Message == "" {
+			errResp.Error.Message = string(respBody)
 		}
-		return nil, fmt.Errorf("Anthropic API returned status %d: %s", resp.StatusCode, string(respBody))
+		return nil, anthropicError(resp.StatusCode, errResp.Error.Type, errResp.Error.Message,
+			parseRetryAfter(resp.Header.Get("retry-after")))
 	}
 
-	var apiResp anthropicResponse
-	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal Anthropic response: %w", err)
+	return resp, nil
+}
+
+// anthropicErrorCodes maps Anthropic error types to GovernorError codes.
+var anthropicErrorCodes = map[string]string{
+	"invalid_request_error": "invalid_request",
+	"authentication_error":  "authentication_failed",
+	"permission_error":      "permission_denied",
+	"not_found_error":       "not_found",
+	"request_too_large":     "request_too_large",
+	"rate_limit_error":      "bedrock_throttled",
+	"overloaded_error":      "model_overloaded",
+	"api_error":             "provider_error",
+}
+
+// anthropicStatusCodes maps HTTP status codes to GovernorError codes for
+// error bodies without a recognised error type.
+var anthropicStatusCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_request",
+	http.StatusUnauthorized:          "authentication_failed",
+	http.StatusForbidden:             "permission_denied",
+	http.StatusNotFound:              "not_found",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusTooManyRequests:       "bedrock_throttled",
+	529:                              "model_overloaded",
+}
+
+// anthropicError translates an Anthropic API error into a GovernorError
+// with the same code the governor would use, so callers behave the same
+// locally as in production. status is 0 for errors reported mid-stream.
+func anthropicError(status int, errType, msg string, retryAfterSec int) *GovernorError {
+	code, ok := anthropicErrorCodes[errType]
+	if !ok {
+		code, ok = anthropicStatusCodes[status]
+	}
+	if !ok {
+		code = "provider_error"
 	}
+	return &GovernorError{Code: code, Msg: msg, RetryAfterSec: retryAfterSec}
+}
 
-	content := make([]ResponseContent, 0, len(apiResp.Content))
-	for _, block := range apiResp.Content {
-		if block.Type == "text" {
-			content = append(content, ResponseContent{Type: "text", Text: block.Text})
+// parseRetryAfter parses a retry-after header given either in seconds or
+// as an HTTP date. It returns 0 if the header is absent or malformed.
+func parseRetryAfter(value string) int {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && secs > 0 {
+		return secs
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if secs := int(math.Ceil(time.Until(t).Seconds())); secs > 0 {
+			return secs
 		}
 	}
+	return 0
+}
 
-	return &InvokeResponse{
-		Content: content,
-		Model:   apiResp.Model,
-		Usage: UsageInfo{
-			InputTokens:  apiResp.Usage.InputTokens,
-			OutputTokens: apiResp.Usage.OutputTokens,
-		},
-		StopReason: apiResp.StopReason,
-	}, nil
+// anthropicStreamEvent is the union of the server-sent event payloads
+// emitted by the Messages API when streaming.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Model string         `json:"model"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Index        int                   `json:"index"`
+	ContentBlock anthropicContentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		Thinking    string `json:"thinking"`
+		Signature   string `json:"signature"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int64 `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// readAnthropicStream parses a server-sent event body and emits text and
+// thinking deltas and completed tool calls, followed by a single
+// message_stop event whose usage is priced for requestModel.
+func readAnthropicStream(body io.Reader, requestModel string, emit func(StreamEvent) error) error {
+	var (
+		model      string
+		stopReason string
+		usage      anthropicUsage
+		toolCalls  = map[int]*StreamEvent{}
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var ev anthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &ev); err != nil {
+			return fmt.Errorf("failed to unmarshal Anthropic stream event: %w", err)
+		}
+
+		switch ev.Type {
+		case "message_start":
+			model = ev.Message.Model
+			usage = ev.Message.Usage
+		case "content_block_start":
+			switch ev.ContentBlock.Type {
+			case "tool_use":
+				toolCalls[ev.Index] = &StreamEvent{Type: "tool_use", ID: ev.ContentBlock.ID, Name: ev.ContentBlock.Name}
+			case "redacted_thinking":
+				if err := emit(StreamEvent{Type: "redacted_thinking", Data: ev.ContentBlock.Data}); err != nil {
+					return err
+				}
+			}
+		case "content_block_delta":
+			switch ev.Delta.Type {
+			case "text_delta":
+				if err := emit(StreamEvent{Type: "text_delta", Text: ev.Delta.Text}); err != nil {
+					return err
+				}
+			case "input_json_delta":
+				if call, ok := toolCalls[ev.Index]; ok {
+					call.Input = append(call.Input, ev.Delta.PartialJSON...)
+				}
+			case "thinking_delta":
+				if err := emit(StreamEvent{Type: "thinking_delta", Text: ev.Delta.Thinking}); err != nil {
+					return err
+				}
+			case "signature_delta":
+				if err := emit(StreamEvent{Type: "signature_delta", Signature: ev.Delta.Signature}); err != nil {
+					return err
+				}
+			}
+		case "content_block_stop":
+			if call, ok := toolCalls[ev.Index]; ok {
+				delete(toolCalls, ev.Index)
+				if len(call.Input) == 0 {
+					call.Input = json.RawMessage("{}")
+				}
+				if err := emit(*call); err != nil {
+					return err
+				}
+			}
+		case "message_delta":
+			stopReason = ev.Delta.StopReason
+			usage.OutputTokens = ev.Usage.OutputTokens
+		case "message_stop":
+			info := usage.usageInfo(requestModel)
+			return emit(StreamEvent{
+				Type:       "message_stop",
+				Model:      model,
+				StopReason: stopReason,
+				Usage:      &info,
+			})
+		case "error":
+			return anthropicError(0, ev.Error.Type, ev.Error.Message, 0)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read Anthropic stream: %w", err)
+	}
+	return fmt.Errorf("Anthropic stream ended before message_stop")
 }
 
 func (b *AnthropicBackend) CheckBudget(_ context.Context, _ string) (*CheckBudgetResponse, error) {
@@ -200,6 +500,176 @@ func (b *AnthropicBackend) ListModels(_ context.Context) (*ListModelsResponse, e
 	return &ListModelsResponse{Models: allModels()}, nil
 }
 
+// anthropicCountRequest is a count_tokens request: the parts of a Messages
+// API request that contribute input tokens.
+type anthropicCountRequest struct {
+	Model      string                   `json:"model"`
+	System     interface{}              `json:"system,omitempty"`
+	Messages   []map[string]interface{} `json:"messages"`
+	Tools      []anthropicTool          `json:"tools,omitempty"`
+	ToolChoice *anthropicToolChoice     `json:"tool_choice,omitempty"`
+	Thinking   *anthropicThinking       `json:"thinking,omitempty"`
+}
+
+// CountTokens counts input tokens with one count_tokens request. The
+// endpoint only reports a total, so the per-message counts are the local
+// estimates of each message, scaled to sum to that total.
+func (b *AnthropicBackend) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	apiReq := b.buildRequest(req, false)
+	total, err := b.countTokens(ctx, anthropicCountRequest{
+		Model:      apiReq.Model,
+		System:     apiReq.System,
+		Messages:   apiReq.Messages,
+		Tools:      apiReq.Tools,
+		ToolChoice: apiReq.ToolChoice,
+		Thinking:   apiReq.Thinking,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scaleTokenCounts(estimateTokenCounts(req), total), nil
+}
+
+func (b *AnthropicBackend) countTokens(ctx context.Context, countReq anthropicCountRequest) (int64, error) {
+	resp, err := b.post(ctx, "/v1/messages/count_tokens", countReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		InputTokens int64 `json:"input_tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to parse Anthropic count_tokens response: %w", err)
+	}
+	return result.InputTokens, nil
+}
+
+// anthropicBatch is a Message Batches API batch object.
+type anthropicBatch struct {
+	ID               string     `json:"id"`
+	ProcessingStatus string     `json:"processing_status"`
+	CreatedAt        time.Time  `json:"created_at"`
+	EndedAt          *time.Time `json:"ended_at"`
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+}
+
+func (a *anthropicBatch) batch() *Batch {
+	return &Batch{
+		ID:        a.ID,
+		Status:    a.ProcessingStatus,
+		Counts:    BatchCounts(a.RequestCounts),
+		CreatedAt: a.CreatedAt,
+		EndedAt:   a.EndedAt,
+		ExpiresAt: a.ExpiresAt,
+	}
+}
+
+type anthropicBatchRequest struct {
+	CustomID string           `json:"custom_id"`
+	Params   anthropicRequest `json:"params"`
+}
+
+// anthropicBatchResult is one line of a batch's JSONL results.
+type anthropicBatchResult struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string                 `json:"type"`
+		Message anthropicResponse      `json:"message"`
+		Error   anthropicErrorResponse `json:"error"`
+	} `json:"result"`
+}
+
+// SubmitBatch creates a batch with the Message Batches API.
+func (b *AnthropicBackend) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	if err := requireBatchRequests(requests); err != nil {
+		return nil, err
+	}
+	apiReq := struct {
+		Requests []anthropicBatchRequest `json:"requests"`
+	}{Requests: make([]anthropicBatchRequest, len(requests))}
+	for i, br := range requests {
+		apiReq.Requests[i] = anthropicBatchRequest{CustomID: br.CustomID, Params: b.buildRequest(br.Request, false)}
+	}
+
+	resp, err := b.post(ctx, "/v1/messages/batches", apiReq)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAnthropicBatch(resp)
+}
+
+func (b *AnthropicBackend) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	resp, err := b.get(ctx, "/v1/messages/batches/"+url.PathEscape(batchID))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAnthropicBatch(resp)
+}
+
+func decodeAnthropicBatch(resp *http.Response) (*Batch, error) {
+	defer resp.Body.Close()
+	var batch anthropicBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, fmt.Errorf("failed to parse Anthropic batch: %w", err)
+	}
+	return batch.batch(), nil
+}
+
+// BatchResults streams the batch's JSONL results file. Responses are
+// priced at the batch rate.
+func (b *AnthropicBackend) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	resp, err := b.get(ctx, "/v1/messages/batches/"+url.PathEscape(batchID)+"/results")
+	if err != nil {
+		return nil, err
+	}
+
+	return newBatchResults(ctx, func(_ context.Context, emit func(BatchResult) error) error {
+		defer resp.Body.Close()
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var line anthropicBatchResult
+			if err := dec.Decode(&line); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("failed to parse Anthropic batch results: %w", err)
+			}
+			if err := emit(line.batchResult()); err != nil {
+				return err
+			}
+		}
+	}), nil
+}
+
+func (r *anthropicBatchResult) batchResult() BatchResult {
+	res := BatchResult{CustomID: r.CustomID}
+	switch r.Result.Type {
+	case "succeeded":
+		resp := r.Result.Message.invokeResponse(r.Result.Message.Model)
+		resp.Usage.EstimatedCostUsd *= batchPriceFactor
+		res.Response = resp
+	case "errored":
+		apiErr := r.Result.Error.Error
+		ge := anthropicError(0, apiErr.Type, apiErr.Message, 0)
+		res.Error = &ErrorResponse{Error: ge.Code, Message: ge.Msg}
+	case "canceled":
+		res.Error = &ErrorResponse{Error: "request_canceled", Message: "batch was canceled before the request was processed"}
+	case "expired":
+		res.Error = &ErrorResponse{Error: "request_expired", Message: "batch expired before the request was processed"}
+	default:
+		res.Error = &ErrorResponse{Error: "provider_error", Message: fmt.Sprintf("unknown batch result type %q", r.Result.Type)}
+	}
+	return res
+}
+
 // convertMessages converts SDK messages to Anthropic API format.
 func convertMessages(messages []Message) []map[string]interface{} {
 	result := make([]map[string]interface{}, 0, len(messages))
@@ -217,6 +687,18 @@ func convertMessages(messages []Message) []map[string]interface{} {
 }
 
 func convertContentBlock(block ContentBlock) map[string]interface{} {
+	result := convertContentBlockBody(block)
+	if block.CacheControl != nil {
+		cacheControl := map[string]interface{}{"type": block.CacheControl.Type}
+		if block.CacheControl.TTL != "" {
+			cacheControl["ttl"] = block.CacheControl.TTL
+		}
+		result["cache_control"] = cacheControl
+	}
+	return result
+}
+
+func convertContentBlockBody(block ContentBlock) map[string]interface{} {
 	switch block.Type {
 	case "text":
 		return map[string]interface{}{
@@ -251,6 +733,42 @@ func convertContentBlock(block ContentBlock) map[string]interface{} {
 		}
 	case "efs_document":
 		return convertEFSDocument(block)
+	case "tool_use":
+		input := block.Input
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		return map[string]interface{}{
+			"type":  "tool_use",
+			"id":    block.ID,
+			"name":  block.Name,
+			"input": input,
+		}
+	case "thinking":
+		return map[string]interface{}{
+			"type":      "thinking",
+			"thinking":  block.Text,
+			"signature": block.Signature,
+		}
+	case "redacted_thinking":
+		return map[string]interface{}{
+			"type": "redacted_thinking",
+			"data": block.Data,
+		}
+	case "tool_result":
+		content := make([]interface{}, 0, len(block.Content))
+		for _, c := range block.Content {
+			content = append(content, convertContentBlock(c))
+		}
+		result := map[string]interface{}{
+			"type":        "tool_result",
+			"tool_use_id": block.ToolUseID,
+			"content":     content,
+		}
+		if block.IsError {
+			result["is_error"] = true
+		}
+		return result
 	default:
 		return map[string]interface{}{
 			"type": "text",
@@ -284,4 +802,4 @@ func convertEFSDocument(block ContentBlock) map[string]interface{} {
 			"data":       base64.StdEncoding.EncodeToString(data),
 		},
 	}
-}
\ No newline at end of file
+}
@@ -1,73 +1,169 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
 )
 
-// LambdaBackend calls the LLM Governor Lambda function.
+// LambdaInvoker is the subset of the Lambda API used by LambdaBackend.
+// It is satisfied by *lambda.Client and by in-process stand-ins such as
+// the governor emulator or MockLambdaClient.
+type LambdaInvoker interface {
+	Invoke(ctx context.Context, params *lambda.InvokeInput, optFns ...func(*lambda.Options)) (*lambda.InvokeOutput, error)
+}
+
+// lambdaStreamInvoker is implemented by clients that support Lambda
+// response streaming, such as *lambda.Client.
+type lambdaStreamInvoker interface {
+	InvokeWithResponseStream(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, optFns ...func(*lambda.Options)) (*lambda.InvokeWithResponseStreamOutput, error)
+}
+
+// LambdaBackend calls the LLM Governor Lambda function. The governor reads
+// efs_document paths relative to its data directory, so requests with
+// absolute paths, or paths escaping that directory, are rejected with a
+// *ValidationError before they are sent.
 type LambdaBackend struct {
 	functionName string
-	lambdaClient *lambda.Client
+	logTail      bool
+
+	clientMu     sync.Mutex
+	lambdaClient LambdaInvoker
+
+	// batchModels maps the custom IDs of batches submitted through this
+	// backend to their models, so results without a model can be priced.
+	batchMu     sync.Mutex
+	batchModels map[string]map[string]string
+}
+
+// LambdaOption configures a LambdaBackend.
+type LambdaOption func(*LambdaBackend)
+
+// WithLogTail requests the tail of the function's execution log with every
+// invocation, so that LambdaFunctionError.LogTail is populated when the
+// governor fails.
+func WithLogTail() LambdaOption {
+	return func(b *LambdaBackend) {
+		b.logTail = true
+	}
 }
 
-// NewLambdaBackend creates a new Lambda backend.
-func NewLambdaBackend(functionName string, lambdaClient *lambda.Client) *LambdaBackend {
-	return &LambdaBackend{
+// NewLambdaBackend creates a new Lambda backend. If lambdaClient is nil, a
+// client is created from the default AWS config on first use.
+func NewLambdaBackend(functionName string, lambdaClient LambdaInvoker, opts ...LambdaOption) *LambdaBackend {
+	b := &LambdaBackend{
 		functionName: functionName,
 		lambdaClient: lambdaClient,
 	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// logType returns the LogType to request from Lambda.
+func (b *LambdaBackend) logType() types.LogType {
+	if b.logTail {
+		return types.LogTypeTail
+	}
+	return types.LogTypeNone
 }
 
-func (b *LambdaBackend) ensureClient(ctx context.Context) error {
+// client returns the Lambda client, creating one from the default AWS
+// config on first use. It is safe for concurrent use.
+func (b *LambdaBackend) client(ctx context.Context) (LambdaInvoker, error) {
+	b.clientMu.Lock()
+	defer b.clientMu.Unlock()
+
 	if b.lambdaClient != nil {
-		return nil
+		return b.lambdaClient, nil
 	}
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %w", err)
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 	b.lambdaClient = lambda.NewFromConfig(cfg)
-	return nil
+	return b.lambdaClient, nil
+}
+
+// traceContextKey is the context key for ContextWithTraceContext.
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of ctx whose governor calls carry
+// carrier, trace propagation fields such as the W3C traceparent and
+// tracestate, in the Lambda payload's traceContext field so that the
+// governor can join the caller's trace.
+func ContextWithTraceContext(ctx context.Context, carrier map[string]string) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, carrier)
+}
+
+// TraceContextFromContext returns the trace propagation fields set by
+// ContextWithTraceContext, or nil.
+func TraceContextFromContext(ctx context.Context) map[string]string {
+	carrier, _ := ctx.Value(traceContextKey{}).(map[string]string)
+	return carrier
+}
+
+// marshalPayload encodes a governor payload, adding the trace context
+// carried by ctx.
+func marshalPayload(ctx context.Context, payload interface{}) ([]byte, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	carrier := TraceContextFromContext(ctx)
+	if len(carrier) == 0 {
+		return payloadBytes, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payloadBytes, &fields); err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if fields["traceContext"], err = json.Marshal(carrier); err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return json.Marshal(fields)
 }
 
 func (b *LambdaBackend) call(ctx context.Context, payload interface{}, result interface{}) error {
-	if err := b.ensureClient(ctx); err != nil {
+	client, err := b.client(ctx)
+	if err != nil {
 		return err
 	}
 
-	payloadBytes, err := json.Marshal(payload)
+	payloadBytes, err := marshalPayload(ctx, payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return err
 	}
 
-	output, err := b.lambdaClient.Invoke(ctx, &lambda.InvokeInput{
+	output, err := client.Invoke(ctx, &lambda.InvokeInput{
 		FunctionName: aws.String(b.functionName),
 		Payload:      payloadBytes,
+		LogType:      b.logType(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to invoke governor: %w", err)
 	}
 
 	if output.FunctionError != nil {
-		return fmt.Errorf("governor function error: %s", *output.FunctionError)
+		return newLambdaFunctionError(output)
 	}
 
 	// Try to detect a governor error response.
 	var errResp ErrorResponse
 	if err := json.Unmarshal(output.Payload, &errResp); err == nil && errResp.Error != "" {
-		return &GovernorError{
-			Code:            errResp.Error,
-			Msg:             errResp.Message,
-			AllowedModels:   errResp.AllowedModels,
-			BudgetRemaining: errResp.BudgetRemaining,
-			RetryAfterSec:   errResp.RetryAfterSec,
-		}
+		return errResp.governorError()
 	}
 
 	if err := json.Unmarshal(output.Payload, result); err != nil {
@@ -78,11 +174,182 @@ func (b *LambdaBackend) call(ctx context.Context, payload interface{}, result in
 }
 
 func (b *LambdaBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	if err := validateEFSPaths(req.Messages, ""); err != nil {
+		return nil, err
+	}
 	var resp InvokeResponse
 	if err := b.call(ctx, req, &resp); err != nil {
 		return nil, err
 	}
-	return &resp, nil
+	return withEstimatedCost(&resp, req.Model), nil
+}
+
+// InvokeStream calls the governor with Lambda response streaming. The
+// governor writes one JSON-encoded StreamEvent (or ErrorResponse) per line.
+//
+// Clients without response streaming support receive a buffered "invoke"
+// call whose response is replayed as a stream.
+func (b *LambdaBackend) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	if err := validateEFSPaths(req.Messages, ""); err != nil {
+		return nil, err
+	}
+	client, err := b.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	streamer, ok := client.(lambdaStreamInvoker)
+	if !ok {
+		buffered := *req
+		buffered.Action = "invoke"
+		resp, err := b.Invoke(ctx, &buffered)
+		if err != nil {
+			return nil, err
+		}
+		return newStream(ctx, func(_ context.Context, emit func(StreamEvent) error) error {
+			return streamResponse(resp, 64, emit)
+		}), nil
+	}
+
+	payloadBytes, err := marshalPayload(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := streamer.InvokeWithResponseStream(ctx, &lambda.InvokeWithResponseStreamInput{
+		FunctionName: aws.String(b.functionName),
+		Payload:      payloadBytes,
+		LogType:      b.logType(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to invoke governor: %w", err)
+	}
+
+	eventStream := output.GetStream()
+	requestID, _ := awsmiddleware.GetRequestIDMetadata(output.ResultMetadata)
+	return newStream(ctx, func(ctx context.Context, emit func(StreamEvent) error) error {
+		defer eventStream.Close()
+		// Closing the event stream unblocks a wait for the next event when
+		// the stream is closed or ctx is cancelled.
+		stop := context.AfterFunc(ctx, func() { eventStream.Close() })
+		defer stop()
+		err := readLambdaStream(eventStream.Events(), emit)
+		if err == nil {
+			if streamErr := eventStream.Err(); streamErr != nil {
+				err = fmt.Errorf("governor stream failed: %w", streamErr)
+			}
+		}
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err == nil:
+			return nil
+		}
+		if fe, ok := IsLambdaFunctionError(err); ok {
+			fe.RequestID = requestID
+			fe.StatusCode = output.StatusCode
+		}
+		return err
+	}), nil
+}
+
+// readLambdaStream reassembles newline-delimited JSON events from Lambda
+// payload chunks and emits them until the invocation completes.
+func readLambdaStream(events <-chan types.InvokeWithResponseStreamResponseEvent, emit func(StreamEvent) error) error {
+	var buf []byte
+	for event := range events {
+		switch e := event.(type) {
+		case *types.InvokeWithResponseStreamResponseEventMemberPayloadChunk:
+			buf = append(buf, e.Value.Payload...)
+			for {
+				i := bytes.IndexByte(buf, '\n')
+				if i < 0 {
+					break
+				}
+				line := buf[:i]
+				buf = buf[i+1:]
+				if err := emitLambdaStreamLine(line, emit); err != nil {
+					return err
+				}
+			}
+		case *types.InvokeWithResponseStreamResponseEventMemberInvokeComplete:
+			if e.Value.ErrorCode != nil {
+				return &LambdaFunctionError{
+					FunctionError: "Unhandled",
+					ErrorType:     aws.ToString(e.Value.ErrorCode),
+					ErrorMessage:  aws.ToString(e.Value.ErrorDetails),
+					LogTail:       decodeLogResult(e.Value.LogResult),
+				}
+			}
+			return emitLambdaStreamLine(buf, emit)
+		}
+	}
+	return emitLambdaStreamLine(buf, emit)
+}
+
+// newLambdaFunctionError parses the error payload of a failed invocation.
+// Lambda runtimes report failures as {"errorMessage", "errorType",
+// "stackTrace"}; a payload in any other shape becomes the message.
+func newLambdaFunctionError(output *lambda.InvokeOutput) *LambdaFunctionError {
+	fe := &LambdaFunctionError{
+		FunctionError: aws.ToString(output.FunctionError),
+		LogTail:       decodeLogResult(output.LogResult),
+		StatusCode:    output.StatusCode,
+	}
+	fe.RequestID, _ = awsmiddleware.GetRequestIDMetadata(output.ResultMetadata)
+
+	var payload struct {
+		ErrorMessage string            `json:"errorMessage"`
+		ErrorType    string            `json:"errorType"`
+		StackTrace   []json.RawMessage `json:"stackTrace"`
+	}
+	if err := json.Unmarshal(output.Payload, &payload); err != nil || payload.ErrorMessage == "" && payload.ErrorType == "" {
+		fe.ErrorMessage = strings.TrimSpace(string(output.Payload))
+		return fe
+	}
+	fe.ErrorMessage = payload.ErrorMessage
+	fe.ErrorType = payload.ErrorType
+	for _, frame := range payload.StackTrace {
+		// Python and Node runtimes report frames as strings; the Go
+		// runtime reports objects, which are kept as JSON.
+		var line string
+		if err := json.Unmarshal(frame, &line); err != nil {
+			line = string(frame)
+		}
+		fe.StackTrace = append(fe.StackTrace, line)
+	}
+	return fe
+}
+
+// decodeLogResult decodes the base64 log tail Lambda returns when LogType
+// is Tail.
+func decodeLogResult(logResult *string) string {
+	if logResult == nil {
+		return ""
+	}
+	data, err := base64.StdEncoding.DecodeString(*logResult)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func emitLambdaStreamLine(line []byte, emit func(StreamEvent) error) error {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(line, &errResp); err == nil && errResp.Error != "" {
+		return errResp.governorError()
+	}
+
+	var ev StreamEvent
+	if err := json.Unmarshal(line, &ev); err != nil {
+		return fmt.Errorf("failed to unmarshal stream event: %w", err)
+	}
+	return emit(ev)
 }
 
 func (b *LambdaBackend) CheckBudget(ctx context.Context, executionRunID string) (*CheckBudgetResponse, error) {
@@ -106,4 +373,119 @@ func (b *LambdaBackend) ListModels(ctx context.Context) (*ListModelsResponse, er
 		return nil, err
 	}
 	return &resp, nil
-}
\ No newline at end of file
+}
+
+// CountTokens sends the request to the governor's "count-tokens" action.
+func (b *LambdaBackend) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	if err := validateEFSPaths(req.Messages, ""); err != nil {
+		return nil, err
+	}
+	countReq := *req
+	countReq.Action = "count-tokens"
+	var resp CountTokensResponse
+	if err := b.call(ctx, &countReq, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SubmitBatch sends the requests to the governor's "submit-batch" action.
+// The batch is billed to the execution run of the first request.
+func (b *LambdaBackend) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	for i, br := range requests {
+		if br.Request == nil {
+			continue
+		}
+		if err := validateEFSPaths(br.Request.Messages, fmt.Sprintf("requests[%d].request.", i)); err != nil {
+			return nil, err
+		}
+	}
+	payload := SubmitBatchRequest{Action: "submit-batch", Requests: requests}
+	if len(requests) > 0 && requests[0].Request != nil {
+		payload.ExecutionRunID = requests[0].Request.ExecutionRunID
+	}
+	var batch Batch
+	if err := b.call(ctx, payload, &batch); err != nil {
+		return nil, err
+	}
+
+	models := make(map[string]string, len(requests))
+	for _, br := range requests {
+		if br.Request != nil {
+			models[br.CustomID] = br.Request.Model
+		}
+	}
+	b.batchMu.Lock()
+	if b.batchModels == nil {
+		b.batchModels = map[string]map[string]string{}
+	}
+	b.batchModels[batch.ID] = models
+	b.batchMu.Unlock()
+	return &batch, nil
+}
+
+func (b *LambdaBackend) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	payload := map[string]interface{}{
+		"action":  "batch-status",
+		"batchId": batchID,
+	}
+	var batch Batch
+	if err := b.call(ctx, payload, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// BatchResults reads the results from the governor's "batch-results"
+// action a page at a time. The first page is fetched before returning so
+// that errors such as an unknown batch are reported immediately. Results
+// without a cost are priced at the batch rate of their response's model,
+// or of their request's model for batches submitted through b, which b
+// forgets once every result has been read.
+func (b *LambdaBackend) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	fetch := func(ctx context.Context, nextToken string) (*BatchResultsResponse, error) {
+		payload := map[string]interface{}{
+			"action":  "batch-results",
+			"batchId": batchID,
+		}
+		if nextToken != "" {
+			payload["nextToken"] = nextToken
+		}
+		var page BatchResultsResponse
+		if err := b.call(ctx, payload, &page); err != nil {
+			return nil, err
+		}
+		return &page, nil
+	}
+
+	page, err := fetch(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	b.batchMu.Lock()
+	models := b.batchModels[batchID]
+	b.batchMu.Unlock()
+
+	return newBatchResults(ctx, func(ctx context.Context, emit func(BatchResult) error) error {
+		for {
+			for _, res := range page.Results {
+				if res.Response != nil {
+					res.Response = withBatchCost(res.Response, models[res.CustomID])
+				}
+				if err := emit(res); err != nil {
+					return err
+				}
+			}
+			if page.NextToken == "" {
+				b.batchMu.Lock()
+				delete(b.batchModels, batchID)
+				b.batchMu.Unlock()
+				return nil
+			}
+			var err error
+			if page, err = fetch(ctx, page.NextToken); err != nil {
+				return err
+			}
+		}
+	}), nil
+}
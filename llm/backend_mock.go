@@ -2,23 +2,58 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 )
 
 // MockBackend returns canned responses for local testing.
 //
 // Register responses with SetResponse or SetResponses.
-// Unmatched calls return a default echo response.
+// Unmatched calls return a default echo response with estimated usage.
+// Responses are priced from their usage unless they set EstimatedCostUsd.
+// InvokeStream replays the same responses in chunks of text.
+//
+// Batches move from "in_progress" to "ended" on the first BatchStatus call,
+// or after SetBatchPolls further calls; each request is then answered like
+// an Invoke call. Queued errors with codes "request_canceled" and
+// "request_expired" count as canceled and expired requests.
 type MockBackend struct {
-	mu        sync.Mutex
-	responses []*InvokeResponse
-	callLog   []*InvokeRequest
+	mu         sync.Mutex
+	responses  []*InvokeResponse
+	errs       []error
+	callLog    []*InvokeRequest
+	chunkSize  int
+	batchPolls int
+	batches    map[string]*mockBatch
+}
+
+// mockBatch is a batch submitted to a MockBackend.
+type mockBatch struct {
+	batch    Batch
+	requests []BatchRequest
+	polls    int
+	results  []BatchResult
+}
+
+// MockToolCall builds a canned response in which the model calls the named
+// tool, for scripting tool use with SetResponse or SetResponses. It panics
+// if input cannot be marshaled to JSON.
+func MockToolCall(id, name string, input interface{}) *InvokeResponse {
+	data, err := json.Marshal(input)
+	if err != nil {
+		panic(fmt.Sprintf("llm: MockToolCall input: %v", err))
+	}
+	return &InvokeResponse{
+		Content:    []ResponseContent{{Type: "tool_use", ID: id, Name: name, Input: data}},
+		StopReason: "tool_use",
+	}
 }
 
 // NewMockBackend creates a new mock backend.
 func NewMockBackend() *MockBackend {
-	return &MockBackend{}
+	return &MockBackend{chunkSize: 8}
 }
 
 // SetResponse sets a single canned response returned for every call.
@@ -36,6 +71,31 @@ func (b *MockBackend) SetResponses(responses []*InvokeResponse) {
 	copy(b.responses, responses)
 }
 
+// QueueErrors makes the next len(errs) invocations fail with the given
+// errors, in order. A nil entry lets that invocation succeed normally.
+func (b *MockBackend) QueueErrors(errs ...error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errs = append(b.errs, errs...)
+}
+
+// SetStreamChunkSize sets the number of runes per text delta emitted by
+// InvokeStream. The default is 8.
+func (b *MockBackend) SetStreamChunkSize(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.chunkSize = n
+}
+
+// SetBatchPolls sets how many BatchStatus calls report a batch as
+// "in_progress" before it ends. The default is 0, so a batch ends on the
+// first BatchStatus call.
+func (b *MockBackend) SetBatchPolls(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batchPolls = n
+}
+
 // Calls returns all InvokeRequests received, for test assertions.
 func (b *MockBackend) Calls() []*InvokeRequest {
 	b.mu.Lock()
@@ -49,15 +109,52 @@ func (b *MockBackend) Invoke(_ context.Context, req *InvokeRequest) (*InvokeResp
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	return b.respond(req)
+}
+
+func (b *MockBackend) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	b.mu.Lock()
+	resp, err := b.respond(req)
+	chunkSize := b.chunkSize
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return newStream(ctx, func(_ context.Context, emit func(StreamEvent) error) error {
+		return streamResponse(resp, chunkSize, emit)
+	}), nil
+}
+
+// respond records the call and picks the next queued error or canned
+// response, priced at the standard rate. The caller must hold b.mu.
+func (b *MockBackend) respond(req *InvokeRequest) (*InvokeResponse, error) {
+	resp, err := b.reply(req)
+	if err != nil {
+		return nil, err
+	}
+	return withEstimatedCost(resp, req.Model), nil
+}
+
+// reply is like respond but leaves the response unpriced. The caller must
+// hold b.mu.
+func (b *MockBackend) reply(req *InvokeRequest) (*InvokeResponse, error) {
 	b.callLog = append(b.callLog, req)
 
+	if len(b.errs) > 0 {
+		err := b.errs[0]
+		b.errs = b.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if len(b.responses) > 0 {
+		resp := b.responses[0]
 		if len(b.responses) > 1 {
-			resp := b.responses[0]
 			b.responses = b.responses[1:]
-			return resp, nil
 		}
-		return b.responses[0], nil
+		return resp, nil
 	}
 
 	// Default: echo the last user prompt.
@@ -74,10 +171,15 @@ func (b *MockBackend) Invoke(_ context.Context, req *InvokeRequest) (*InvokeResp
 		}
 	}
 
+	text := fmt.Sprintf("[mock] %s", promptText)
 	return &InvokeResponse{
-		Content:    []ResponseContent{{Type: "text", Text: fmt.Sprintf("[mock] %s", promptText)}},
+		Content:    []ResponseContent{{Type: "text", Text: text}},
 		Model:      req.Model,
 		StopReason: "end_turn",
+		Usage: UsageInfo{
+			InputTokens:  estimateInputTokens(req),
+			OutputTokens: textTokens(text),
+		},
 	}, nil
 }
 
@@ -93,4 +195,120 @@ func (b *MockBackend) CheckBudget(_ context.Context, _ string) (*CheckBudgetResp
 
 func (b *MockBackend) ListModels(_ context.Context) (*ListModelsResponse, error) {
 	return &ListModelsResponse{Models: allModels()}, nil
-}
\ No newline at end of file
+}
+
+// CountTokens estimates input tokens with the same deterministic heuristic
+// used for mock usage, about four characters per token.
+func (b *MockBackend) CountTokens(_ context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	return estimateTokenCounts(req), nil
+}
+
+// SubmitBatch records the batch as "in_progress". Requests are answered
+// when the batch ends.
+func (b *MockBackend) SubmitBatch(_ context.Context, requests []BatchRequest) (*Batch, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.batches == nil {
+		b.batches = map[string]*mockBatch{}
+	}
+	now := time.Now().UTC()
+	mb := &mockBatch{
+		batch: Batch{
+			ID:        fmt.Sprintf("mockbatch_%d", len(b.batches)+1),
+			Status:    "in_progress",
+			Counts:    BatchCounts{Processing: len(requests)},
+			CreatedAt: now,
+			ExpiresAt: now.Add(24 * time.Hour),
+		},
+		requests: append([]BatchRequest(nil), requests...),
+		polls:    b.batchPolls,
+	}
+	b.batches[mb.batch.ID] = mb
+	batch := mb.batch
+	return &batch, nil
+}
+
+// BatchStatus advances the batch's lifecycle and reports its state.
+func (b *MockBackend) BatchStatus(_ context.Context, batchID string) (*Batch, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	mb, err := b.batch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	if mb.polls > 0 {
+		mb.polls--
+	} else if !mb.batch.Ended() {
+		b.endBatch(mb)
+	}
+	batch := mb.batch
+	return &batch, nil
+}
+
+// BatchResults returns the results of an ended batch, in submission
+// order. Responses are priced at the batch rate.
+func (b *MockBackend) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	b.mu.Lock()
+	mb, err := b.batch(batchID)
+	if err == nil && !mb.batch.Ended() {
+		err = &GovernorError{Code: "invalid_request", Msg: fmt.Sprintf("batch %s has not ended", batchID)}
+	}
+	var results []BatchResult
+	if err == nil {
+		results = mb.results
+	}
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return newBatchResults(ctx, func(_ context.Context, emit func(BatchResult) error) error {
+		for _, res := range results {
+			if err := emit(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	}), nil
+}
+
+// batch looks up a submitted batch. The caller must hold b.mu.
+func (b *MockBackend) batch(batchID string) (*mockBatch, error) {
+	mb, ok := b.batches[batchID]
+	if !ok {
+		return nil, &GovernorError{Code: "not_found", Msg: fmt.Sprintf("batch %s not found", batchID)}
+	}
+	return mb, nil
+}
+
+// endBatch answers every request in the batch and marks it ended. The
+// caller must hold b.mu.
+func (b *MockBackend) endBatch(mb *mockBatch) {
+	counts := BatchCounts{}
+	mb.results = make([]BatchResult, len(mb.requests))
+	for i, br := range mb.requests {
+		res := BatchResult{CustomID: br.CustomID}
+		resp, err := b.reply(br.Request)
+		if err != nil {
+			res.Error = errorResponse(err)
+			switch res.Error.Error {
+			case "request_canceled":
+				counts.Canceled++
+			case "request_expired":
+				counts.Expired++
+			default:
+				counts.Errored++
+			}
+		} else {
+			res.Response = withBatchCost(resp, br.Request.Model)
+			counts.Succeeded++
+		}
+		mb.results[i] = res
+	}
+	ended := time.Now().UTC()
+	mb.batch.Status = "ended"
+	mb.batch.Counts = counts
+	mb.batch.EndedAt = &ended
+}
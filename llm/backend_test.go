@@ -3,9 +3,21 @@ package llm
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/smithy-go/middleware"
 )
 
 // --- Model mapping tests ---
@@ -375,4 +387,461 @@ func TestGovernor_BackendAccessor(t *testing.T) {
 	if g.Backend() != mock {
 		t.Error("Backend() should return the active backend")
 	}
-}
\ No newline at end of file
+
+	g = NewGovernor(WithBackend(mock), WithRetryPolicy(DefaultRetryPolicy()), WithBudgetLedger())
+	if g.Backend() != mock {
+		t.Errorf("Backend() should return the configured backend under policy wrappers, got %T", g.Backend())
+	}
+	if _, ok := g.WrappedBackend().(*BudgetLedger); !ok {
+		t.Errorf("expected WrappedBackend() to return the ledger, got %T", g.WrappedBackend())
+	}
+}
+
+// --- Anthropic error mapping tests ---
+
+func TestAnthropicBackend_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		code     string
+		sentinel error
+	}{
+		{400, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`, "invalid_request", ErrInvalidRequest},
+		{401, `{"type":"error","error":{"type":"authentication_error","message":"bad key"}}`, "authentication_failed", ErrAuthFailure},
+		{403, `{"type":"error","error":{"type":"permission_error","message":"denied"}}`, "permission_denied", ErrAuthFailure},
+		{404, `{"type":"error","error":{"type":"not_found_error","message":"no model"}}`, "not_found", ErrNotFound},
+		{413, `{"type":"error","error":{"type":"request_too_large","message":"too big"}}`, "request_too_large", ErrRequestTooLarge},
+		{429, `{"type":"error","error":{"type":"rate_limit_error","message":"slow"}}`, "bedrock_throttled", ErrThrottled},
+		{500, `{"type":"error","error":{"type":"api_error","message":"oops"}}`, "provider_error", ErrProviderError},
+		{529, `{"type":"error","error":{"type":"overloaded_error","message":"busy"}}`, "model_overloaded", ErrOverloaded},
+		{413, `<html>Request Entity Too Large</html>`, "request_too_large", ErrRequestTooLarge},
+		{502, `Bad Gateway`, "provider_error", ErrProviderError},
+	}
+	for _, tt := range tests {
+		client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}, nil
+		})}
+		b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+		_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+		ge, ok := IsGovernorError(err)
+		if !ok {
+			t.Errorf("status %d: expected GovernorError, got %v", tt.status, err)
+			continue
+		}
+		if ge.Code != tt.code {
+			t.Errorf("status %d: expected code %q, got %q", tt.status, tt.code, ge.Code)
+		}
+		if !errors.Is(err, tt.sentinel) {
+			t.Errorf("status %d: expected errors.Is(%v)", tt.status, tt.sentinel)
+		}
+		if ge.Msg == "" {
+			t.Errorf("status %d: expected a message", tt.status)
+		}
+	}
+}
+
+// --- LambdaBackend tests ---
+
+func TestLambdaBackend_Invoke(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(&InvokeResponse{
+		Content:    []ResponseContent{{Type: "text", Text: "hello"}},
+		Model:      ModelHaiku45,
+		StopReason: "end_turn",
+		Usage:      UsageInfo{InputTokens: 4, OutputTokens: 1, EstimatedCostUsd: 0.001},
+	})
+	g := NewGovernor(WithFunctionName("llm-governor"), WithLambdaClient(client), WithExecutionRunID("run-1"))
+
+	text, err := g.Ask(context.Background(), ModelHaiku45, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("expected 'hello', got %q", text)
+	}
+
+	calls := client.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(calls))
+	}
+	if *calls[0].FunctionName != "llm-governor" {
+		t.Errorf("expected function 'llm-governor', got %q", *calls[0].FunctionName)
+	}
+	var sent InvokeRequest
+	if err := json.Unmarshal(client.Payloads()[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Action != "invoke" || sent.ExecutionRunID != "run-1" || sent.Model != ModelHaiku45 {
+		t.Errorf("unexpected payload: %+v", sent)
+	}
+}
+
+func TestLambdaBackend_GovernorErrorResponse(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(ErrorResponse{
+		Error:         "model_not_allowed",
+		Message:       "not allowed",
+		AllowedModels: []string{ModelHaiku45},
+	})
+	b := NewLambdaBackend("gov", client)
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelSonnet46})
+	ge, ok := IsGovernorError(err)
+	if !ok || !ge.IsModelNotAllowed() {
+		t.Fatalf("expected model_not_allowed, got %v", err)
+	}
+	if len(ge.AllowedModels) != 1 || ge.AllowedModels[0] != ModelHaiku45 {
+		t.Errorf("unexpected allowed models: %v", ge.AllowedModels)
+	}
+}
+
+func TestLambdaBackend_FunctionError(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueFunctionError("Runtime.ExitError", "process exited")
+	b := NewLambdaBackend("gov", client)
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	fe, ok := IsLambdaFunctionError(err)
+	if !ok {
+		t.Fatalf("expected LambdaFunctionError, got %v", err)
+	}
+	if fe.FunctionError != "Unhandled" || fe.ErrorType != "Runtime.ExitError" || fe.ErrorMessage != "process exited" {
+		t.Errorf("unexpected function error: %+v", fe)
+	}
+	if !fe.Transient() || !IsRetryable(err) {
+		t.Error("expected a runtime exit to be transient")
+	}
+	if _, ok := IsGovernorError(err); ok {
+		t.Error("function errors should not be reported as GovernorErrors")
+	}
+}
+
+func TestLambdaBackend_FunctionErrorDetails(t *testing.T) {
+	var md middleware.Metadata
+	awsmiddleware.SetRequestIDMetadata(&md, "req-123")
+	client := NewMockLambdaClient()
+	client.QueueOutput(&lambda.InvokeOutput{
+		StatusCode:     200,
+		FunctionError:  aws.String("Unhandled"),
+		Payload:        []byte(`{"errorMessage":"'model' is required","errorType":"KeyError","stackTrace":["  File \"handler.py\", line 12"]}`),
+		LogResult:      aws.String(base64.StdEncoding.EncodeToString([]byte("START RequestId: req-123\nTraceback ..."))),
+		ResultMetadata: md,
+	})
+	b := NewLambdaBackend("gov", client, WithLogTail())
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	fe, ok := IsLambdaFunctionError(err)
+	if !ok {
+		t.Fatalf("expected LambdaFunctionError, got %v", err)
+	}
+	if fe.ErrorType != "KeyError" || len(fe.StackTrace) != 1 || !strings.Contains(fe.StackTrace[0], "handler.py") {
+		t.Errorf("unexpected parsed payload: %+v", fe)
+	}
+	if !strings.Contains(fe.LogTail, "Traceback") {
+		t.Errorf("expected decoded log tail, got %q", fe.LogTail)
+	}
+	if fe.RequestID != "req-123" || fe.StatusCode != 200 {
+		t.Errorf("unexpected request metadata: %q %d", fe.RequestID, fe.StatusCode)
+	}
+	if fe.Transient() || IsRetryable(err) {
+		t.Error("expected a handler exception to be deterministic")
+	}
+	if got := client.Calls()[0].LogType; got != types.LogTypeTail {
+		t.Errorf("expected LogType Tail, got %q", got)
+	}
+}
+
+func TestLambdaBackend_FunctionErrorRawPayload(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueOutput(&lambda.InvokeOutput{
+		StatusCode:    200,
+		FunctionError: aws.String("Unhandled"),
+		Payload:       []byte("Task timed out after 900.00 seconds\n"),
+	})
+	b := NewLambdaBackend("gov", client)
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	fe, ok := IsLambdaFunctionError(err)
+	if !ok {
+		t.Fatalf("expected LambdaFunctionError, got %v", err)
+	}
+	if fe.ErrorMessage != "Task timed out after 900.00 seconds" {
+		t.Errorf("unexpected message %q", fe.ErrorMessage)
+	}
+	if !fe.Transient() {
+		t.Error("expected a timeout to be transient")
+	}
+	if got := client.Calls()[0].LogType; got != types.LogTypeNone {
+		t.Errorf("expected LogType None, got %q", got)
+	}
+}
+
+func TestLambdaBackend_InvokeError(t *testing.T) {
+	client := NewMockLambdaClient()
+	denied := errors.New("AccessDeniedException")
+	client.QueueError(denied)
+	b := NewLambdaBackend("gov", client)
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	if !errors.Is(err, denied) {
+		t.Errorf("expected wrapped invoke error, got %v", err)
+	}
+}
+
+func TestLambdaBackend_MalformedPayload(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueOutput(&lambda.InvokeOutput{StatusCode: 200, Payload: []byte("not json")})
+	b := NewLambdaBackend("gov", client)
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	if err == nil || !strings.Contains(err.Error(), "failed to unmarshal response") {
+		t.Errorf("expected unmarshal error, got %v", err)
+	}
+}
+
+func TestLambdaBackend_UnmarshalableRequest(t *testing.T) {
+	client := NewMockLambdaClient()
+	b := NewLambdaBackend("gov", client)
+
+	var resp InvokeResponse
+	err := b.call(context.Background(), map[string]interface{}{"bad": make(chan int)}, &resp)
+	if err == nil || !strings.Contains(err.Error(), "failed to marshal request") {
+		t.Errorf("expected marshal error, got %v", err)
+	}
+	if len(client.Calls()) != 0 {
+		t.Error("expected no call to be made")
+	}
+}
+
+func TestLambdaBackend_CheckBudgetAndListModels(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(CheckBudgetResponse{BudgetPeriod: "daily", PeriodBudgetUsd: 5})
+	client.QueueResponse(ListModelsResponse{Models: []ModelInfo{{ModelID: ModelHaiku45, Status: "available"}}})
+	b := NewLambdaBackend("gov", client)
+
+	budget, err := b.CheckBudget(context.Background(), "run-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.BudgetPeriod != "daily" || budget.PeriodBudgetUsd != 5 {
+		t.Errorf("unexpected budget: %+v", budget)
+	}
+	models, err := b.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models.Models) != 1 || models.Models[0].ModelID != ModelHaiku45 {
+		t.Errorf("unexpected models: %+v", models)
+	}
+
+	payloads := client.Payloads()
+	if string(payloads[0]) != `{"action":"check-budget","executionRunId":"run-7"}` {
+		t.Errorf("unexpected check-budget payload: %s", payloads[0])
+	}
+	if string(payloads[1]) != `{"action":"list-models"}` {
+		t.Errorf("unexpected list-models payload: %s", payloads[1])
+	}
+}
+
+func TestLambdaBackend_TraceContext(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(ListModelsResponse{})
+	b := NewLambdaBackend("gov", client)
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := ContextWithTraceContext(context.Background(), map[string]string{"traceparent": traceparent})
+	if _, err := b.ListModels(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"action":"list-models","traceContext":{"traceparent":"` + traceparent + `"}}`
+	if got := string(client.Payloads()[0]); got != want {
+		t.Errorf("expected payload %s, got %s", want, got)
+	}
+}
+
+func TestLambdaBackend_InvokeStream(t *testing.T) {
+	client := NewMockLambdaStreamClient()
+	client.QueueStreamEvents(
+		StreamEvent{Type: "text_delta", Text: "Hel"},
+		StreamEvent{Type: "text_delta", Text: "lo"},
+		StreamEvent{Type: "message_stop", StopReason: "end_turn", Usage: &UsageInfo{InputTokens: 3, OutputTokens: 2}},
+	)
+	g := NewGovernor(WithFunctionName("gov"), WithLambdaClient(client))
+
+	s, err := g.AskStream(context.Background(), ModelHaiku45, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := collectStream(t, s); len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	resp := s.Response()
+	if resp.Text() != "Hello" || resp.StopReason != "end_turn" || resp.Usage.OutputTokens != 2 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	var sent InvokeRequest
+	if err := json.Unmarshal(client.Payloads()[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Action != "invoke-stream" {
+		t.Errorf("expected streamed 'invoke-stream' action, got %q", sent.Action)
+	}
+}
+
+func TestLambdaBackend_InvokeStreamErrors(t *testing.T) {
+	req := &InvokeRequest{Action: "invoke-stream", Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}
+	ctx := context.Background()
+
+	t.Run("invoke error", func(t *testing.T) {
+		client := NewMockLambdaStreamClient()
+		client.QueueError(errors.New("access denied"))
+		_, err := NewLambdaBackend("gov", client).InvokeStream(ctx, req)
+		if err == nil || !strings.Contains(err.Error(), "access denied") {
+			t.Errorf("expected the invoke error, got %v", err)
+		}
+	})
+
+	t.Run("function error", func(t *testing.T) {
+		client := NewMockLambdaStreamClient()
+		client.QueueStream(
+			lambdaChunk(`{"type":"text_delta","text":"partial"}`+"\n"),
+			&types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{Value: types.InvokeWithResponseStreamCompleteEvent{
+				ErrorCode:    aws.String("Runtime.ExitError"),
+				ErrorDetails: aws.String("process exited"),
+				LogResult:    aws.String(base64.StdEncoding.EncodeToString([]byte("log tail"))),
+			}},
+		)
+		s, err := NewLambdaBackend("gov", client).InvokeStream(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		for s.Next() {
+		}
+		fe, ok := IsLambdaFunctionError(s.Err())
+		if !ok {
+			t.Fatalf("expected a LambdaFunctionError, got %v", s.Err())
+		}
+		if fe.ErrorType != "Runtime.ExitError" || fe.ErrorMessage != "process exited" || fe.LogTail != "log tail" {
+			t.Errorf("unexpected function error %+v", fe)
+		}
+		if fe.RequestID != "mock-request-id" || fe.StatusCode != http.StatusOK {
+			t.Errorf("expected the invocation's request ID and status, got %q and %d", fe.RequestID, fe.StatusCode)
+		}
+		if s.Response().Text() != "partial" {
+			t.Errorf("expected the text before the error, got %q", s.Response().Text())
+		}
+	})
+
+	t.Run("governor error", func(t *testing.T) {
+		client := NewMockLambdaStreamClient()
+		client.QueueStream(
+			lambdaChunk(`{"error":"budget_exceeded","message":"no budget left"}`+"\n"),
+			&types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{},
+		)
+		s, err := NewLambdaBackend("gov", client).InvokeStream(ctx, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		for s.Next() {
+		}
+		if !errors.Is(s.Err(), ErrBudgetExceeded) {
+			t.Errorf("expected budget_exceeded, got %v", s.Err())
+		}
+	})
+}
+
+// stalledLambdaClient streams one event and then stalls, like a governor
+// that stops responding mid-stream.
+type stalledLambdaClient struct {
+	*MockLambdaClient
+	body *io.PipeReader
+}
+
+func (c *stalledLambdaClient) InvokeWithResponseStream(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, _ ...func(*lambda.Options)) (*lambda.InvokeWithResponseStreamOutput, error) {
+	return newLambdaStreamOutput(ctx, params, c.body)
+}
+
+func TestLambdaBackend_CloseStalledStream(t *testing.T) {
+	body, w := io.Pipe()
+	defer w.Close()
+	go encodeLambdaStream(w, []types.InvokeWithResponseStreamResponseEvent{
+		lambdaChunk(`{"type":"text_delta","text":"Hel"}` + "\n"),
+	})
+	b := NewLambdaBackend("gov", &stalledLambdaClient{MockLambdaClient: NewMockLambdaClient(), body: body})
+
+	s, err := b.InvokeStream(context.Background(), &InvokeRequest{
+		Action:   "invoke-stream",
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Next() {
+		t.Fatalf("expected a first event, got error %v", s.Err())
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		s.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a stalled stream")
+	}
+}
+
+func TestLambdaBackend_InvokeStreamBuffered(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(&InvokeResponse{
+		Content:    []ResponseContent{{Type: "text", Text: "streamed"}},
+		StopReason: "end_turn",
+	})
+	g := NewGovernor(WithFunctionName("gov"), WithLambdaClient(client))
+
+	s, err := g.AskStream(context.Background(), ModelHaiku45, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectStream(t, s)
+	if s.Response().Text() != "streamed" {
+		t.Errorf("expected 'streamed', got %q", s.Response().Text())
+	}
+	var sent InvokeRequest
+	if err := json.Unmarshal(client.Payloads()[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Action != "invoke" {
+		t.Errorf("expected buffered 'invoke' action, got %q", sent.Action)
+	}
+}
+
+func TestMockLambdaClient_ScriptExhausted(t *testing.T) {
+	client := NewMockLambdaClient()
+	if _, err := client.Invoke(context.Background(), &lambda.InvokeInput{}); err == nil {
+		t.Error("expected error when no output is scripted")
+	}
+}
+
+func TestLambdaBackend_ConfigError(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "does-not-exist")
+	b := NewLambdaBackend("gov", nil)
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	if err == nil || !strings.Contains(err.Error(), "failed to load AWS config") {
+		t.Errorf("expected config error, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// maxBatchRequests is the largest number of requests in one batch.
+const maxBatchRequests = 100_000
+
+// customIDPattern matches the custom IDs accepted by the Message Batches
+// API.
+var customIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// BatchResults is an iterator over the results of an ended batch, in the
+// order the backend reports them, which need not be the submission order.
+//
+// Call Next until it returns false, then check Err. Close must be called
+// if the results are abandoned before they are fully read.
+//
+//	results, err := gov.BatchResults(ctx, batch.ID)
+//	if err != nil { ... }
+//	defer results.Close()
+//	for results.Next() {
+//		id, resp, err := results.Result()
+//		...
+//	}
+//	if err := results.Err(); err != nil { ... }
+type BatchResults struct {
+	results chan BatchResult
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	prodErr error
+
+	cur BatchResult
+	err error
+}
+
+// newBatchResults starts produce in a goroutine and returns a BatchResults
+// reading the results it emits. emit blocks until the consumer reads the
+// result and returns an error once the iterator has been closed or ctx is
+// cancelled.
+func newBatchResults(ctx context.Context, produce func(ctx context.Context, emit func(BatchResult) error) error) *BatchResults {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &BatchResults{
+		results: make(chan BatchResult),
+		cancel:  cancel,
+	}
+
+	emit := func(res BatchResult) error {
+		select {
+		case r.results <- res:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(r.results)
+		err := produce(ctx, emit)
+		r.mu.Lock()
+		r.prodErr = err
+		r.mu.Unlock()
+	}()
+
+	return r
+}
+
+// Next advances to the next result. It returns false when all results
+// have been read or an error occurred.
+func (r *BatchResults) Next() bool {
+	res, ok := <-r.results
+	if !ok {
+		r.mu.Lock()
+		r.err = r.prodErr
+		r.mu.Unlock()
+		r.cancel()
+		return false
+	}
+	r.cur = res
+	return true
+}
+
+// Result returns the result read by the last call to Next: the request's
+// custom ID and either its response or the GovernorError it failed with.
+// Requests canceled or expired before processing fail with codes
+// "request_canceled" and "request_expired".
+func (r *BatchResults) Result() (customID string, resp *InvokeResponse, err error) {
+	if r.cur.Error != nil {
+		return r.cur.CustomID, nil, r.cur.Error.governorError()
+	}
+	return r.cur.CustomID, r.cur.Response, nil
+}
+
+// Err returns the error that ended the iteration, if any. It is only
+// meaningful after Next has returned false.
+func (r *BatchResults) Err() error {
+	return r.err
+}
+
+// Close stops the iteration and releases its resources. It is safe to
+// call Close after the results have been fully read.
+func (r *BatchResults) Close() error {
+	r.cancel()
+	for range r.results {
+	}
+	return nil
+}
+
+// SubmitBatch submits requests as a message batch, which is processed
+// asynchronously at a discount. Each request is defaulted and validated as
+// by Invoke. Use BatchStatus or WaitBatch to follow the batch, then
+// BatchResults to read its results. The batch methods fail with
+// ErrBatchesNotSupported if the backend is not a BatchBackend.
+func (g *Governor) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	for _, br := range requests {
+		if br.Request == nil {
+			continue
+		}
+		if br.Request.Action == "" {
+			br.Request.Action = "invoke"
+		}
+		if br.Request.ExecutionRunID == "" {
+			br.Request.ExecutionRunID = g.executionRunID
+		}
+	}
+	if err := validateBatch(requests, !g.skipValidation); err != nil {
+		return nil, err
+	}
+	bb, err := batchBackend(g.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.SubmitBatch(ctx, requests)
+}
+
+// BatchStatus returns the current state of a batch.
+func (g *Governor) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	bb, err := batchBackend(g.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.BatchStatus(ctx, batchID)
+}
+
+// defaultBatchPollInterval is how often WaitBatch polls when it is not
+// given a positive interval.
+const defaultBatchPollInterval = time.Second
+
+// WaitBatch polls the batch every pollInterval, or every second if
+// pollInterval is not positive, until it has ended, and returns its final
+// state. It returns early with ctx's error if ctx ends.
+func (g *Governor) WaitBatch(ctx context.Context, batchID string, pollInterval time.Duration) (*Batch, error) {
+	bb, err := batchBackend(g.backend)
+	if err != nil {
+		return nil, err
+	}
+	if pollInterval <= 0 {
+		pollInterval = defaultBatchPollInterval
+	}
+	for {
+		batch, err := bb.BatchStatus(ctx, batchID)
+		if err != nil {
+			return nil, err
+		}
+		if batch.Ended() {
+			return batch, nil
+		}
+		if err := sleepContext(ctx, pollInterval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// BatchResults returns an iterator over the results of an ended batch.
+// The caller must Close the returned iterator.
+func (g *Governor) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	bb, err := batchBackend(g.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.BatchResults(ctx, batchID)
+}
+
+// requireBatchRequests reports batch entries without a request, which a
+// backend cannot send. Governor.SubmitBatch reports them along with the
+// rest of its validation; backends check again for direct callers.
+func requireBatchRequests(requests []BatchRequest) error {
+	v := &requestValidator{}
+	for i, br := range requests {
+		if br.Request == nil {
+			v.report(fmt.Sprintf("requests[%d].request", i), "is required")
+		}
+	}
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validateBatch checks the batch's size and custom IDs and, if
+// validateRequests is set, each request, reporting problems with paths
+// such as "requests[3].request.messages".
+func validateBatch(requests []BatchRequest, validateRequests bool) error {
+	v := &requestValidator{}
+	switch {
+	case len(requests) == 0:
+		v.report("requests", "must not be empty")
+	case len(requests) > maxBatchRequests:
+		v.report("requests", "must contain at most %d requests, got %d", maxBatchRequests, len(requests))
+	}
+
+	seen := make(map[string]int, len(requests))
+	for i, br := range requests {
+		p := fmt.Sprintf("requests[%d]", i)
+		if !customIDPattern.MatchString(br.CustomID) {
+			v.report(p+".customId", "must be 1-64 letters, digits, hyphens or underscores, got %q", br.CustomID)
+		} else if first, ok := seen[br.CustomID]; ok {
+			v.report(p+".customId", "duplicates requests[%d].customId %q", first, br.CustomID)
+		} else {
+			seen[br.CustomID] = i
+		}
+
+		if br.Request == nil {
+			v.report(p+".request", "is required")
+			continue
+		}
+		if !validateRequests {
+			continue
+		}
+		if err := br.Request.Validate(); err != nil {
+			for _, fe := range err.(*ValidationError).Problems {
+				v.report(p+".request."+fe.Path, "%s", fe.Msg)
+			}
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
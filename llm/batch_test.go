@@ -0,0 +1,429 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func batchOf(prompts ...string) []BatchRequest {
+	requests := make([]BatchRequest, len(prompts))
+	for i, p := range prompts {
+		requests[i] = BatchRequest{
+			CustomID: p,
+			Request:  &InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock(p))}},
+		}
+	}
+	return requests
+}
+
+type batchOutcome struct {
+	resp *InvokeResponse
+	err  error
+}
+
+func collectBatchResults(t *testing.T, r *BatchResults) map[string]batchOutcome {
+	t.Helper()
+	defer r.Close()
+	out := map[string]batchOutcome{}
+	for r.Next() {
+		id, resp, err := r.Result()
+		out[id] = batchOutcome{resp, err}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("batch results error: %v", err)
+	}
+	return out
+}
+
+func TestGovernor_BatchLifecycle(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetBatchPolls(1)
+	mock.QueueErrors(nil, &GovernorError{Code: "model_overloaded", Msg: "busy"}, &GovernorError{Code: "request_expired"})
+	g := NewGovernor(WithBackend(mock), WithExecutionRunID("run-1"))
+	ctx := context.Background()
+
+	batch, err := g.SubmitBatch(ctx, batchOf("a", "b", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Status != "in_progress" || batch.Counts.Processing != 3 {
+		t.Errorf("unexpected submitted batch: %+v", batch)
+	}
+
+	status, err := g.BatchStatus(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Ended() {
+		t.Fatal("expected batch to still be in progress after the first poll")
+	}
+	if _, err := g.BatchResults(ctx, batch.ID); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected invalid_request for results of a running batch, got %v", err)
+	}
+
+	final, err := g.WaitBatch(ctx, batch.ID, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BatchCounts{Succeeded: 1, Errored: 1, Expired: 1}
+	if final.Counts != want || final.EndedAt == nil {
+		t.Errorf("unexpected final batch: %+v", final)
+	}
+
+	results, err := g.BatchResults(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectBatchResults(t, results)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(got))
+	}
+	if got["a"].err != nil || got["a"].resp.Text() != "[mock] a" {
+		t.Errorf("unexpected result a: %+v", got["a"])
+	}
+	if !errors.Is(got["b"].err, ErrOverloaded) {
+		t.Errorf("expected overloaded error for b, got %v", got["b"].err)
+	}
+	if !errors.Is(got["c"].err, ErrRequestExpired) {
+		t.Errorf("expected expired error for c, got %v", got["c"].err)
+	}
+
+	usage := got["a"].resp.Usage
+	full := estimateCostUsd(ModelHaiku45, usage, 0)
+	if math.Abs(usage.EstimatedCostUsd-full*batchPriceFactor) > 1e-12 {
+		t.Errorf("expected batch price %g, got %g", full*batchPriceFactor, usage.EstimatedCostUsd)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 3 || calls[0].Action != "invoke" || calls[0].ExecutionRunID != "run-1" {
+		t.Errorf("expected defaulted requests, got %+v", calls)
+	}
+}
+
+func TestGovernor_SubmitBatchValidation(t *testing.T) {
+	g := NewGovernor(WithBackend(NewMockBackend()))
+	requests := batchOf("a", "a", "b c")
+	requests = append(requests, BatchRequest{CustomID: "d"})
+	requests[0].Request.Messages = nil
+
+	_, err := g.SubmitBatch(context.Background(), requests)
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	paths := map[string]bool{}
+	for _, p := range ve.Problems {
+		paths[p.Path] = true
+	}
+	for _, want := range []string{"requests[0].request.messages", "requests[1].customId", "requests[2].customId", "requests[3].request"} {
+		if !paths[want] {
+			t.Errorf("expected a problem at %s, got %v", want, err)
+		}
+	}
+
+	if _, err := g.SubmitBatch(context.Background(), nil); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected invalid_request for an empty batch, got %v", err)
+	}
+}
+
+const anthropicBatchJSON = `{"id":"msgbatch_1","type":"message_batch","processing_status":"%s",
+"request_counts":{"processing":%d,"succeeded":%d,"errored":%d,"canceled":0,"expired":0},
+"created_at":"2026-01-02T03:04:05Z","ended_at":null,"expires_at":"2026-01-03T03:04:05Z"}`
+
+const anthropicBatchResults = `{"custom_id":"a","result":{"type":"succeeded","message":{"model":"claude-haiku-4-5-20251001","content":[{"type":"text","text":"hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1000,"output_tokens":100}}}}
+{"custom_id":"b","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}}}
+{"custom_id":"c","result":{"type":"canceled"}}
+`
+
+func TestAnthropicBackend_Batch(t *testing.T) {
+	var submitted struct {
+		Requests []struct {
+			CustomID string `json:"custom_id"`
+			Params   struct {
+				Model     string `json:"model"`
+				MaxTokens int    `json:"max_tokens"`
+			} `json:"params"`
+		} `json:"requests"`
+	}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch {
+		case req.Method == "POST" && req.URL.Path == "/v1/messages/batches":
+			if err := json.NewDecoder(req.Body).Decode(&submitted); err != nil {
+				t.Errorf("bad submit body: %v", err)
+			}
+			body = strings.NewReplacer("%s", "in_progress", "%d", "0").Replace(anthropicBatchJSON)
+		case req.Method == "GET" && req.URL.Path == "/v1/messages/batches/msgbatch_1":
+			body = strings.NewReplacer("%s", "ended", "%d", "1").Replace(anthropicBatchJSON)
+		case req.Method == "GET" && req.URL.Path == "/v1/messages/batches/msgbatch_1/results":
+			body = anthropicBatchResults
+		default:
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+	ctx := context.Background()
+
+	batch, err := b.SubmitBatch(ctx, batchOf("a", "b", "c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.ID != "msgbatch_1" || batch.Status != "in_progress" {
+		t.Errorf("unexpected batch: %+v", batch)
+	}
+	if len(submitted.Requests) != 3 || submitted.Requests[1].CustomID != "b" ||
+		submitted.Requests[0].Params.Model != MapModel(ModelHaiku45) || submitted.Requests[0].Params.MaxTokens != defaultMaxTokens {
+		t.Errorf("unexpected submitted requests: %+v", submitted)
+	}
+
+	status, err := b.BatchStatus(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Ended() || status.Counts.Succeeded != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	results, err := b.BatchResults(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectBatchResults(t, results)
+	if got["a"].err != nil || got["a"].resp.Text() != "hi" {
+		t.Errorf("unexpected result a: %+v", got["a"])
+	}
+	usage := got["a"].resp.Usage
+	if want := estimateCostUsd(ModelHaiku45, usage, 0) * batchPriceFactor; math.Abs(usage.EstimatedCostUsd-want) > 1e-12 {
+		t.Errorf("expected batch price %g, got %g", want, usage.EstimatedCostUsd)
+	}
+	if !errors.Is(got["b"].err, ErrInvalidRequest) {
+		t.Errorf("expected invalid_request for b, got %v", got["b"].err)
+	}
+	if !errors.Is(got["c"].err, ErrRequestCanceled) {
+		t.Errorf("expected canceled for c, got %v", got["c"].err)
+	}
+}
+
+func TestLambdaBackend_BatchResultsPages(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(BatchResultsResponse{
+		Results:   []BatchResult{{CustomID: "a", Response: &InvokeResponse{Content: []ResponseContent{{Type: "text", Text: "one"}}}}},
+		NextToken: "page-2",
+	})
+	client.QueueResponse(BatchResultsResponse{
+		Results: []BatchResult{{CustomID: "b", Error: &ErrorResponse{Error: "request_expired", Message: "expired"}}},
+	})
+	b := NewLambdaBackend("llm-governor", client)
+
+	results, err := b.BatchResults(context.Background(), "batch-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectBatchResults(t, results)
+	if got["a"].resp.Text() != "one" || !errors.Is(got["b"].err, ErrRequestExpired) {
+		t.Errorf("unexpected results: %+v", got)
+	}
+
+	payloads := client.Payloads()
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(payloads))
+	}
+	var second map[string]string
+	if err := json.Unmarshal(payloads[1], &second); err != nil {
+		t.Fatal(err)
+	}
+	if second["action"] != "batch-results" || second["batchId"] != "batch-1" || second["nextToken"] != "page-2" {
+		t.Errorf("unexpected second page request: %v", second)
+	}
+}
+
+func TestBudgetLedger_BatchResults(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(&InvokeResponse{Content: []ResponseContent{{Type: "text", Text: "ok"}}, Usage: UsageInfo{EstimatedCostUsd: 0.5}})
+	g := NewGovernor(WithBackend(mock), WithBudgetLedger(WithLedgerExecutionBudget(1.2)))
+	ctx := context.Background()
+
+	requests := batchOf("a", "b")
+	requests[1].Request.ExecutionRunID = "run-2"
+	batch, err := g.SubmitBatch(ctx, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.WaitBatch(ctx, batch.ID, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	results, err := g.BatchResults(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectBatchResults(t, results)
+	if rem := got["b"].resp.BudgetRemaining.ExecutionRemainingUsd; math.Abs(rem-0.7) > 1e-9 {
+		t.Errorf("expected run-2 to have $0.70 left, got %g", rem)
+	}
+
+	budget, err := g.WrappedBackend().CheckBudget(ctx, "run-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(budget.PeriodUsedUsd-1.0) > 1e-9 {
+		t.Errorf("expected $1.00 spent in the period, got %g", budget.PeriodUsedUsd)
+	}
+	ledger := g.WrappedBackend().(*BudgetLedger)
+	if len(ledger.batchRuns) != 0 || ledger.state.PeriodPendingUsd != 0 {
+		t.Errorf("expected the read batch to be forgotten with its reservations settled, got %v and $%g pending",
+			ledger.batchRuns, ledger.state.PeriodPendingUsd)
+	}
+}
+
+// batchlessBackend hides the batch methods of the backend it embeds.
+type batchlessBackend struct {
+	Backend
+}
+
+func TestGovernor_BatchesNotSupported(t *testing.T) {
+	ctx := context.Background()
+	for _, g := range []*Governor{
+		NewGovernor(WithBackend(batchlessBackend{NewMockBackend()})),
+		NewGovernor(WithBackend(batchlessBackend{NewMockBackend()}), WithRetryPolicy(DefaultRetryPolicy()), WithBudgetLedger()),
+	} {
+		if _, err := g.SubmitBatch(ctx, batchOf("a")); !errors.Is(err, ErrBatchesNotSupported) {
+			t.Errorf("expected ErrBatchesNotSupported from SubmitBatch, got %v", err)
+		}
+		if _, err := g.BatchStatus(ctx, "batch-1"); !errors.Is(err, ErrBatchesNotSupported) {
+			t.Errorf("expected ErrBatchesNotSupported from BatchStatus, got %v", err)
+		}
+		if _, err := g.BatchResults(ctx, "batch-1"); !errors.Is(err, ErrBatchesNotSupported) {
+			t.Errorf("expected ErrBatchesNotSupported from BatchResults, got %v", err)
+		}
+	}
+}
+
+func TestBatchBackends_SubmitNilRequest(t *testing.T) {
+	backends := map[string]BatchBackend{
+		"anthropic": NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			t.Error("expected no request to be sent")
+			return nil, errors.New("unexpected request")
+		})})),
+		"ledger": NewBudgetLedger(NewMockBackend()),
+		"router": NewRouterBackend(WithDefaultRoute(NewMockBackend())),
+	}
+	for name, b := range backends {
+		t.Run(name, func(t *testing.T) {
+			_, err := b.SubmitBatch(context.Background(), append(batchOf("a"), BatchRequest{CustomID: "b"}))
+			var ve *ValidationError
+			if !errors.As(err, &ve) || ve.Problems[0].Path != "requests[1].request" {
+				t.Errorf("expected requests[1].request to be reported, got %v", err)
+			}
+		})
+	}
+}
+
+// pendingBatchBackend reports every batch as in progress.
+type pendingBatchBackend struct {
+	*MockBackend
+	polls int
+}
+
+func (b *pendingBatchBackend) BatchStatus(_ context.Context, batchID string) (*Batch, error) {
+	b.polls++
+	return &Batch{ID: batchID, Status: "in_progress"}, nil
+}
+
+func TestGovernor_WaitBatchDefaultsPollInterval(t *testing.T) {
+	backend := &pendingBatchBackend{MockBackend: NewMockBackend()}
+	g := NewGovernor(WithBackend(backend))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := g.WaitBatch(ctx, "batch-1", 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the wait to time out, got %v", err)
+	}
+	if backend.polls != 1 {
+		t.Errorf("expected one poll before the default interval, got %d", backend.polls)
+	}
+}
+
+// throttledBatchBackend fails every batch submission with bedrock_throttled.
+type throttledBatchBackend struct {
+	*MockBackend
+	submits int
+}
+
+func (b *throttledBatchBackend) SubmitBatch(context.Context, []BatchRequest) (*Batch, error) {
+	b.submits++
+	return nil, &GovernorError{Code: "bedrock_throttled"}
+}
+
+func TestRetryBackend_DoesNotRetrySubmitBatch(t *testing.T) {
+	backend := &throttledBatchBackend{MockBackend: NewMockBackend()}
+	policy := noJitterPolicy()
+	policy.InitialBackoff = time.Millisecond
+	g := NewGovernor(WithBackend(backend), WithRetryPolicy(policy))
+
+	if _, err := g.SubmitBatch(context.Background(), batchOf("a")); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected the throttling error, got %v", err)
+	}
+	if backend.submits != 1 {
+		t.Errorf("expected one submission, got %d", backend.submits)
+	}
+}
+
+func TestLambdaBackend_BatchResultsPricedByRequestModel(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(Batch{ID: "batch-1", Status: "in_progress"})
+	client.QueueResponse(BatchResultsResponse{
+		Results: []BatchResult{{CustomID: "a", Response: &InvokeResponse{
+			Content: []ResponseContent{{Type: "text", Text: "one"}},
+			Usage:   UsageInfo{InputTokens: 1000, OutputTokens: 1000},
+		}}},
+	})
+	b := NewLambdaBackend("llm-governor", client)
+	ctx := context.Background()
+
+	if _, err := b.SubmitBatch(ctx, batchOf("a")); err != nil {
+		t.Fatal(err)
+	}
+	results, err := b.BatchResults(ctx, "batch-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collectBatchResults(t, results)
+	want := estimateCostUsd(ModelHaiku45, UsageInfo{InputTokens: 1000, OutputTokens: 1000}, 0) * batchPriceFactor
+	if cost := got["a"].resp.Usage.EstimatedCostUsd; want == 0 || math.Abs(cost-want) > 1e-12 {
+		t.Errorf("expected the batch price of the request's model, $%g, got $%g", want, cost)
+	}
+	if len(b.batchModels) != 0 {
+		t.Errorf("expected the read batch to be forgotten, got %v", b.batchModels)
+	}
+}
+
+func TestLambdaBackend_BatchStatus(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(&Batch{ID: "batch-1", Status: "in_progress", Counts: BatchCounts{Processing: 2}})
+	client.QueueResponse(&ErrorResponse{Error: "not_found", Message: "no batch batch-2"})
+	b := NewLambdaBackend("gov", client)
+	ctx := context.Background()
+
+	batch, err := b.BatchStatus(ctx, "batch-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.ID != "batch-1" || batch.Ended() || batch.Counts.Processing != 2 {
+		t.Errorf("unexpected batch %+v", batch)
+	}
+	if want := `{"action":"batch-status","batchId":"batch-1"}`; string(client.Payloads()[0]) != want {
+		t.Errorf("expected payload %s, got %s", want, client.Payloads()[0])
+	}
+
+	if _, err := b.BatchStatus(ctx, "batch-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not_found, got %v", err)
+	}
+}
@@ -1,12 +1,16 @@
 package llm
 
+import "encoding/json"
+
 // TextBlock creates a text content block.
 func TextBlock(text string) ContentBlock {
 	return ContentBlock{Type: "text", Text: text}
 }
 
 // FileBlock creates an efs_document content block from a file path.
-// The path is relative to the compute node's data directory on EFS.
+// LambdaBackend requires a path relative to the compute node's data
+// directory on EFS; AnthropicBackend reads the file from the local
+// filesystem.
 func FileBlock(path string) ContentBlock {
 	return ContentBlock{Type: "efs_document", Path: path}
 }
@@ -22,6 +26,50 @@ func DocumentBlock(name, format, base64Data string) ContentBlock {
 	return ContentBlock{Type: "document", Name: name, Format: format, Data: base64Data}
 }
 
+// ToolUseBlock creates a tool_use content block. It is used to replay a
+// model's tool call in an assistant message.
+func ToolUseBlock(id, name string, input json.RawMessage) ContentBlock {
+	return ContentBlock{Type: "tool_use", ID: id, Name: name, Input: input}
+}
+
+// ToolResultBlock creates a tool_result content block answering the tool
+// call with the given ID.
+func ToolResultBlock(toolUseID, result string) ContentBlock {
+	return ContentBlock{Type: "tool_result", ToolUseID: toolUseID, Content: []ContentBlock{TextBlock(result)}}
+}
+
+// ToolErrorBlock creates a tool_result content block reporting that the
+// tool call with the given ID failed.
+func ToolErrorBlock(toolUseID, message string) ContentBlock {
+	return ContentBlock{Type: "tool_result", ToolUseID: toolUseID, Content: []ContentBlock{TextBlock(message)}, IsError: true}
+}
+
+// ThinkingBlock creates a thinking content block. It is used to replay a
+// model's thinking in an assistant message; the signature must be the one
+// returned with it.
+func ThinkingBlock(thinking, signature string) ContentBlock {
+	return ContentBlock{Type: "thinking", Text: thinking, Signature: signature}
+}
+
+// RedactedThinkingBlock creates a redacted_thinking content block from the
+// encrypted data returned by the model.
+func RedactedThinkingBlock(data string) ContentBlock {
+	return ContentBlock{Type: "redacted_thinking", Data: data}
+}
+
+// EphemeralCache returns a cache control marker with the given TTL, "5m"
+// or "1h". An empty TTL uses the provider default of five minutes.
+func EphemeralCache(ttl string) *CacheControl {
+	return &CacheControl{Type: "ephemeral", TTL: ttl}
+}
+
+// CachedBlock marks block as a prompt cache breakpoint, so the prompt up to
+// and including it is cached for the given TTL (see EphemeralCache).
+func CachedBlock(block ContentBlock, ttl string) ContentBlock {
+	block.CacheControl = EphemeralCache(ttl)
+	return block
+}
+
 // UserMessage creates a user message with the given content blocks.
 func UserMessage(blocks ...ContentBlock) Message {
 	return Message{Role: "user", Content: blocks}
@@ -30,4 +78,4 @@ func UserMessage(blocks ...ContentBlock) Message {
 // AssistantMessage creates an assistant message with the given content blocks.
 func AssistantMessage(blocks ...ContentBlock) Message {
 	return Message{Role: "assistant", Content: blocks}
-}
\ No newline at end of file
+}
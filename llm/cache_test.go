@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConvertMessages_CacheControl(t *testing.T) {
+	msgs := []Message{
+		UserMessage(CachedBlock(DocumentBlock("paper", "pdf", "base64pdf"), "1h"), TextBlock("Summarize")),
+	}
+	blocks := convertMessages(msgs)[0]["content"].([]interface{})
+
+	doc := blocks[0].(map[string]interface{})
+	cc, ok := doc["cache_control"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected cache_control on cached block, got %v", doc)
+	}
+	if cc["type"] != "ephemeral" || cc["ttl"] != "1h" {
+		t.Errorf("unexpected cache_control: %v", cc)
+	}
+	if _, ok := blocks[1].(map[string]interface{})["cache_control"]; ok {
+		t.Error("expected no cache_control on uncached block")
+	}
+}
+
+func TestAnthropicBackend_CachedSystemAndUsage(t *testing.T) {
+	var apiReq map[string]interface{}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &apiReq); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`{
+				"model": "claude-sonnet-4-6",
+				"stop_reason": "end_turn",
+				"content": [{"type": "text", "text": "ok"}],
+				"usage": {
+					"input_tokens": 100,
+					"output_tokens": 1000,
+					"cache_creation_input_tokens": 300000,
+					"cache_read_input_tokens": 1000000,
+					"cache_creation": {"ephemeral_5m_input_tokens": 200000, "ephemeral_1h_input_tokens": 100000}
+				}
+			}`)),
+		}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+	resp, err := b.Invoke(context.Background(), &InvokeRequest{
+		Model:              ModelSonnet46,
+		System:             "You are a curator.",
+		SystemCacheControl: EphemeralCache(""),
+		Messages:           []Message{UserMessage(TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	system, ok := apiReq["system"].([]interface{})
+	if !ok || len(system) != 1 {
+		t.Fatalf("expected system as a block list, got %v", apiReq["system"])
+	}
+	block := system[0].(map[string]interface{})
+	if block["text"] != "You are a curator." || block["cache_control"] == nil {
+		t.Errorf("unexpected system block: %v", block)
+	}
+
+	u := resp.Usage
+	if u.CacheCreationInputTokens != 300000 || u.CacheReadInputTokens != 1000000 {
+		t.Errorf("unexpected cache usage: %+v", u)
+	}
+	// Sonnet at $3/$15 per MTok: 100 input, 1000 output, 200k 5m writes at
+	// 1.25x, 100k 1h writes at 2x, 1M reads at 0.1x.
+	want := (100*3 + 1000*15 + 200000*3*1.25 + 100000*3*2.0 + 1000000*3*0.1) / 1e6
+	if math.Abs(u.EstimatedCostUsd-want) > 1e-9 {
+		t.Errorf("expected cost %f, got %f", want, u.EstimatedCostUsd)
+	}
+}
+
+func TestAnthropicBackend_PlainSystemIsString(t *testing.T) {
+	b := NewAnthropicBackend(WithAPIKey("test"))
+	apiReq := b.buildRequest(&InvokeRequest{Model: ModelHaiku45, System: "plain"}, false)
+	if apiReq.System != "plain" {
+		t.Errorf("expected plain string system, got %v", apiReq.System)
+	}
+	data, _ := json.Marshal(b.buildRequest(&InvokeRequest{Model: ModelHaiku45}, false))
+	if strings.Contains(string(data), `"system"`) {
+		t.Errorf("expected system to be omitted, got %s", data)
+	}
+}
+
+func TestInvokeRequest_CacheControlSerialization(t *testing.T) {
+	req := &InvokeRequest{
+		Model:              ModelHaiku45,
+		System:             "sys",
+		SystemCacheControl: EphemeralCache("5m"),
+		Messages:           []Message{UserMessage(CachedBlock(FileBlock("input/paper.pdf"), ""))},
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed InvokeRequest
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.SystemCacheControl == nil || parsed.SystemCacheControl.TTL != "5m" {
+		t.Errorf("system cache control did not round-trip: %s", data)
+	}
+	if cc := parsed.Messages[0].Content[0].CacheControl; cc == nil || cc.Type != "ephemeral" {
+		t.Errorf("block cache control did not round-trip: %s", data)
+	}
+}
+
+func TestUsageInfo_CacheTokensDecode(t *testing.T) {
+	var resp InvokeResponse
+	payload := `{"usage":{"inputTokens":10,"outputTokens":5,"cacheCreationInputTokens":7,"cacheReadInputTokens":900,"estimatedCostUsd":0.002}}`
+	if err := json.Unmarshal([]byte(payload), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Usage.CacheCreationInputTokens != 7 || resp.Usage.CacheReadInputTokens != 900 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+}
@@ -0,0 +1,432 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen matches the *CircuitOpenError returned by a
+// CircuitBreakerBackend that is rejecting calls.
+var ErrCircuitOpen = errors.New("llm: circuit open")
+
+// CircuitOpenError is returned without calling the backend while the
+// circuit is open, or half-open with all trial calls in flight.
+type CircuitOpenError struct {
+	// RetryAfter is how long until the circuit lets a trial call through.
+	// While half-open with every trial call in flight, it is a short wait
+	// for the trials to finish.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("llm: circuit open, retry after %s", e.RetryAfter)
+}
+
+// Is reports whether target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// CircuitState is the state of a CircuitBreakerBackend.
+type CircuitState int
+
+const (
+	// CircuitClosed passes calls through and counts failures.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects calls until the open timeout has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of trial calls through to
+	// decide whether to close or reopen.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// IsCircuitFailure reports whether err indicates an unhealthy backend.
+// Transient governor errors (throttling, overload, provider errors),
+// Lambda function errors, timeouts and transport failures count; errors
+// that are the caller's or the request's fault, such as budget_exceeded,
+// invalid_request or a cancelled context, do not. CircuitBreakerBackend
+// also ignores any error from a call whose context had ended, so that a
+// caller's deadline does not count against the backend.
+func IsCircuitFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, ErrInvalidRequest) {
+		return false
+	}
+	if ge, ok := IsGovernorError(err); ok {
+		return retryableCodes[ge.Code]
+	}
+	return true
+}
+
+// CircuitBreakerPolicy controls when a CircuitBreakerBackend opens.
+type CircuitBreakerPolicy struct {
+	// ConsecutiveFailures opens the circuit after this many failures in
+	// a row. Zero disables the check.
+	ConsecutiveFailures int
+
+	// FailureRate opens the circuit when at least this fraction (0–1) of
+	// the calls completed in the last Window failed, once there have been
+	// at least MinCalls of them. Zero disables the check.
+	FailureRate float64
+	Window      time.Duration
+	MinCalls    int
+
+	// OpenTimeout is how long the circuit stays open before letting
+	// trial calls through.
+	OpenTimeout time.Duration
+
+	// HalfOpenCalls is the number of trial calls allowed while half-open.
+	// The circuit closes once that many succeed and reopens on the first
+	// failure.
+	HalfOpenCalls int
+
+	// IsFailure classifies errors. It defaults to IsCircuitFailure.
+	IsFailure func(error) bool
+
+	// OnStateChange, if set, is called after every state change.
+	OnStateChange func(from, to CircuitState)
+}
+
+// DefaultCircuitBreakerPolicy returns a policy that opens after 5
+// consecutive failures or a 50% failure rate over at least 10 calls in a
+// minute, and tries again after 30s with a single trial call.
+func DefaultCircuitBreakerPolicy() CircuitBreakerPolicy {
+	return CircuitBreakerPolicy{
+		ConsecutiveFailures: 5,
+		FailureRate:         0.5,
+		Window:              time.Minute,
+		MinCalls:            10,
+		OpenTimeout:         30 * time.Second,
+		HalfOpenCalls:       1,
+	}
+}
+
+// CircuitBreakerBackend wraps a Backend and stops calling it while it is
+// failing, so that callers fail fast with ErrCircuitOpen instead of
+// waiting on a degraded service.
+//
+// InvokeStream and BatchResults count failures to start as well as errors
+// that end the stream or results.
+type CircuitBreakerBackend struct {
+	backend Backend
+	policy  CircuitBreakerPolicy
+	now     func() time.Time
+
+	mu             sync.Mutex
+	state          CircuitState
+	openedAt       time.Time
+	consecutive    int
+	outcomes       []circuitOutcome
+	trials         int
+	trialSuccesses int
+}
+
+// circuitOutcome is a completed call within the failure-rate window.
+type circuitOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// NewCircuitBreakerBackend wraps backend with the given policy.
+func NewCircuitBreakerBackend(backend Backend, policy CircuitBreakerPolicy) *CircuitBreakerBackend {
+	if policy.HalfOpenCalls < 1 {
+		policy.HalfOpenCalls = 1
+	}
+	if policy.IsFailure == nil {
+		policy.IsFailure = IsCircuitFailure
+	}
+	return &CircuitBreakerBackend{
+		backend: backend,
+		policy:  policy,
+		now:     time.Now,
+	}
+}
+
+// Unwrap returns the wrapped backend.
+func (b *CircuitBreakerBackend) Unwrap() Backend {
+	return b.backend
+}
+
+// State returns the current state of the circuit.
+func (b *CircuitBreakerBackend) State() CircuitState {
+	b.mu.Lock()
+	from := b.state
+	to := b.advance()
+	b.mu.Unlock()
+	b.notify(from, to)
+	return to
+}
+
+func (b *CircuitBreakerBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	var resp *InvokeResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.Invoke(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (b *CircuitBreakerBackend) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	done, err := b.allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := b.backend.InvokeStream(ctx, req)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	return newStream(ctx, func(_ context.Context, emit func(StreamEvent) error) error {
+		defer inner.Close()
+		for inner.Next() {
+			if err := emit(inner.Event()); err != nil {
+				done(nil)
+				return err
+			}
+		}
+		done(inner.Err())
+		return inner.Err()
+	}), nil
+}
+
+func (b *CircuitBreakerBackend) CheckBudget(ctx context.Context, executionRunID string) (*CheckBudgetResponse, error) {
+	var resp *CheckBudgetResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.CheckBudget(ctx, executionRunID)
+		return err
+	})
+	return resp, err
+}
+
+func (b *CircuitBreakerBackend) ListModels(ctx context.Context) (*ListModelsResponse, error) {
+	var resp *ListModelsResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.ListModels(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (b *CircuitBreakerBackend) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	var resp *CountTokensResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.CountTokens(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (b *CircuitBreakerBackend) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	var batch *Batch
+	err = b.do(ctx, func() error {
+		var err error
+		batch, err = bb.SubmitBatch(ctx, requests)
+		return err
+	})
+	return batch, err
+}
+
+func (b *CircuitBreakerBackend) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	var batch *Batch
+	err = b.do(ctx, func() error {
+		var err error
+		batch, err = bb.BatchStatus(ctx, batchID)
+		return err
+	})
+	return batch, err
+}
+
+func (b *CircuitBreakerBackend) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	done, err := b.allow(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := bb.BatchResults(ctx, batchID)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	return newBatchResults(ctx, func(_ context.Context, emit func(BatchResult) error) error {
+		defer inner.Close()
+		for inner.Next() {
+			if err := emit(inner.cur); err != nil {
+				done(nil)
+				return err
+			}
+		}
+		done(inner.Err())
+		return inner.Err()
+	}), nil
+}
+
+// do runs call if the circuit allows it and records the outcome.
+func (b *CircuitBreakerBackend) do(ctx context.Context, call func() error) error {
+	done, err := b.allow(ctx)
+	if err != nil {
+		return err
+	}
+	err = call()
+	done(err)
+	return err
+}
+
+// halfOpenRetryAfter is the RetryAfter of calls rejected because every
+// half-open trial call is in flight, capped at the policy's OpenTimeout.
+const halfOpenRetryAfter = time.Second
+
+// allow admits a call made with ctx, or returns a *CircuitOpenError. The
+// returned done function must be called exactly once with the call's
+// outcome.
+func (b *CircuitBreakerBackend) allow(ctx context.Context) (done func(error), err error) {
+	b.mu.Lock()
+	from := b.state
+	to := b.advance()
+	switch {
+	case to == CircuitOpen:
+		err = &CircuitOpenError{RetryAfter: b.openedAt.Add(b.policy.OpenTimeout).Sub(b.now())}
+	case to == CircuitHalfOpen && b.trials >= b.policy.HalfOpenCalls:
+		err = &CircuitOpenError{RetryAfter: min(halfOpenRetryAfter, b.policy.OpenTimeout)}
+	case to == CircuitHalfOpen:
+		b.trials++
+	}
+	b.mu.Unlock()
+	b.notify(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(err error) {
+		// A call that failed after ctx ended, such as one whose deadline
+		// ran out while waiting for a rate limiter, says nothing about the
+		// backend's health and is not counted either way.
+		abandoned := err != nil && ctx.Err() != nil
+		once.Do(func() { b.record(to == CircuitHalfOpen, err, abandoned) })
+	}, nil
+}
+
+// record updates the circuit with the outcome of a call admitted in the
+// given state. An abandoned call only gives back its trial slot.
+func (b *CircuitBreakerBackend) record(trial bool, err error, abandoned bool) {
+	failed := err != nil && b.policy.IsFailure(err)
+
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case trial:
+		b.trials--
+		if b.state != CircuitHalfOpen || abandoned {
+			break
+		}
+		if failed {
+			b.open()
+		} else if b.trialSuccesses++; b.trialSuccesses >= b.policy.HalfOpenCalls {
+			b.close()
+		}
+	case b.state == CircuitClosed && !abandoned:
+		now := b.now()
+		if failed {
+			b.consecutive++
+		} else {
+			b.consecutive = 0
+		}
+		if b.policy.FailureRate > 0 {
+			b.outcomes = append(b.outcomes, circuitOutcome{at: now, failed: failed})
+		}
+		if b.tripped(now) {
+			b.open()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// tripped reports whether the closed circuit's failures call for it to
+// open. b.mu must be held.
+func (b *CircuitBreakerBackend) tripped(now time.Time) bool {
+	if b.policy.ConsecutiveFailures > 0 && b.consecutive >= b.policy.ConsecutiveFailures {
+		return true
+	}
+	if b.policy.FailureRate <= 0 {
+		return false
+	}
+
+	cutoff := now.Add(-b.policy.Window)
+	i := 0
+	for i < len(b.outcomes) && b.outcomes[i].at.Before(cutoff) {
+		i++
+	}
+	b.outcomes = b.outcomes[i:]
+	if len(b.outcomes) < max(b.policy.MinCalls, 1) {
+		return false
+	}
+	failures := 0
+	for _, o := range b.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+	return float64(failures)/float64(len(b.outcomes)) >= b.policy.FailureRate
+}
+
+// advance moves an open circuit to half-open once its timeout has passed
+// and returns the current state. b.mu must be held.
+func (b *CircuitBreakerBackend) advance() CircuitState {
+	if b.state == CircuitOpen && !b.now().Before(b.openedAt.Add(b.policy.OpenTimeout)) {
+		b.state = CircuitHalfOpen
+		b.trialSuccesses = 0
+	}
+	return b.state
+}
+
+// open and close change state. b.mu must be held.
+func (b *CircuitBreakerBackend) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.now()
+}
+
+func (b *CircuitBreakerBackend) close() {
+	b.state = CircuitClosed
+	b.consecutive = 0
+	b.outcomes = nil
+}
+
+// notify calls the state change callback if the state changed. It must be
+// called without holding b.mu.
+func (b *CircuitBreakerBackend) notify(from, to CircuitState) {
+	if from != to && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,219 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// newTestCircuitBreaker returns a breaker on a fake clock, the function
+// that advances the clock, and the recorded state transitions.
+func newTestCircuitBreaker(backend Backend, policy CircuitBreakerPolicy) (*CircuitBreakerBackend, func(time.Duration), *[]string) {
+	var transitions []string
+	policy.OnStateChange = func(from, to CircuitState) {
+		transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+	}
+	b := NewCircuitBreakerBackend(backend, policy)
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }, &transitions
+}
+
+func overloaded() error {
+	return &GovernorError{Code: "model_overloaded", Msg: "busy"}
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(overloaded(), overloaded(), overloaded())
+	b, advance, transitions := newTestCircuitBreaker(mock, CircuitBreakerPolicy{
+		ConsecutiveFailures: 3,
+		OpenTimeout:         10 * time.Second,
+	})
+	ctx := context.Background()
+	req := rateLimitRequest(10)
+
+	for range 3 {
+		if _, err := b.Invoke(ctx, req); !errors.Is(err, ErrOverloaded) {
+			t.Fatalf("expected overloaded, got %v", err)
+		}
+	}
+	if b.State() != CircuitOpen {
+		t.Fatalf("expected open circuit, got %s", b.State())
+	}
+
+	advance(4 * time.Second)
+	_, err := b.Invoke(ctx, req)
+	var open *CircuitOpenError
+	if !errors.As(err, &open) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected CircuitOpenError, got %v", err)
+	}
+	if open.RetryAfter != 6*time.Second {
+		t.Errorf("expected retry after 6s, got %s", open.RetryAfter)
+	}
+	if n := len(mock.Calls()); n != 3 {
+		t.Errorf("expected the open circuit not to call the backend, got %d calls", n)
+	}
+
+	advance(6 * time.Second)
+	if _, err := b.Invoke(ctx, req); err != nil {
+		t.Fatalf("expected the trial call to succeed, got %v", err)
+	}
+	if b.State() != CircuitClosed {
+		t.Errorf("expected closed circuit, got %s", b.State())
+	}
+	want := []string{"closed->open", "open->half-open", "half-open->closed"}
+	if fmt.Sprint(*transitions) != fmt.Sprint(want) {
+		t.Errorf("expected transitions %v, got %v", want, *transitions)
+	}
+}
+
+func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(overloaded(), overloaded())
+	b, advance, _ := newTestCircuitBreaker(mock, CircuitBreakerPolicy{
+		ConsecutiveFailures: 1,
+		OpenTimeout:         time.Second,
+	})
+	ctx := context.Background()
+
+	b.Invoke(ctx, rateLimitRequest(10))
+	advance(time.Second)
+	if b.State() != CircuitHalfOpen {
+		t.Fatalf("expected half-open circuit, got %s", b.State())
+	}
+	b.Invoke(ctx, rateLimitRequest(10))
+	if b.State() != CircuitOpen {
+		t.Errorf("expected the failed trial to reopen the circuit, got %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenTrialsInFlight(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(overloaded())
+	b, advance, _ := newTestCircuitBreaker(mock, CircuitBreakerPolicy{
+		ConsecutiveFailures: 1,
+		OpenTimeout:         10 * time.Second,
+	})
+	ctx := context.Background()
+
+	b.Invoke(ctx, rateLimitRequest(10))
+	advance(10 * time.Second)
+	done, err := b.allow(ctx)
+	if err != nil {
+		t.Fatalf("expected a trial call to be admitted, got %v", err)
+	}
+
+	_, err = b.Invoke(ctx, rateLimitRequest(10))
+	var open *CircuitOpenError
+	if !errors.As(err, &open) {
+		t.Fatalf("expected CircuitOpenError while the trial is in flight, got %v", err)
+	}
+	if open.RetryAfter != halfOpenRetryAfter {
+		t.Errorf("expected RetryAfter %s, got %s", halfOpenRetryAfter, open.RetryAfter)
+	}
+
+	done(nil)
+	if _, err := b.Invoke(ctx, rateLimitRequest(10)); err != nil {
+		t.Errorf("expected the circuit to close after the trial, got %v", err)
+	}
+}
+
+func TestCircuitBreaker_IgnoresNonFailures(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(
+		&GovernorError{Code: "budget_exceeded"},
+		&GovernorError{Code: "invalid_request"},
+		context.Canceled,
+	)
+	b, _, _ := newTestCircuitBreaker(mock, CircuitBreakerPolicy{ConsecutiveFailures: 1})
+
+	for range 3 {
+		if _, err := b.Invoke(context.Background(), rateLimitRequest(10)); err == nil {
+			t.Fatal("expected the queued error")
+		}
+	}
+	if b.State() != CircuitClosed {
+		t.Errorf("expected closed circuit, got %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_IgnoresRateLimitWaits(t *testing.T) {
+	limited := NewRateLimitBackend(NewMockBackend(), RateLimit{RequestsPerMinute: 1})
+	b, _, _ := newTestCircuitBreaker(limited, CircuitBreakerPolicy{ConsecutiveFailures: 1})
+
+	if _, err := b.Invoke(context.Background(), rateLimitRequest(10)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := b.Invoke(ctx, rateLimitRequest(10)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the rate limiter wait to time out, got %v", err)
+	}
+	if b.State() != CircuitClosed {
+		t.Errorf("expected a caller's deadline to leave the circuit closed, got %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_FailureRate(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(nil, overloaded(), nil, overloaded())
+	b, advance, _ := newTestCircuitBreaker(mock, CircuitBreakerPolicy{
+		FailureRate: 0.5,
+		Window:      time.Minute,
+		MinCalls:    4,
+		OpenTimeout: time.Minute,
+	})
+	ctx := context.Background()
+
+	// An old success falls out of the window and does not dilute the rate.
+	b.Invoke(ctx, rateLimitRequest(10))
+	advance(2 * time.Minute)
+	for range 3 {
+		b.Invoke(ctx, rateLimitRequest(10))
+	}
+	if b.State() != CircuitClosed {
+		t.Fatalf("expected closed circuit below MinCalls, got %s", b.State())
+	}
+	b.Invoke(ctx, rateLimitRequest(10))
+	if b.State() != CircuitOpen {
+		t.Errorf("expected 2 failures in 4 calls to open the circuit, got %s", b.State())
+	}
+}
+
+func TestCircuitBreaker_StreamErrors(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(overloaded())
+	b, _, _ := newTestCircuitBreaker(mock, CircuitBreakerPolicy{ConsecutiveFailures: 1, OpenTimeout: time.Minute})
+
+	if _, err := b.InvokeStream(context.Background(), rateLimitRequest(10)); !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("expected overloaded, got %v", err)
+	}
+	if _, err := b.InvokeStream(context.Background(), rateLimitRequest(10)); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+}
+
+func TestIsCircuitFailure(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&GovernorError{Code: "bedrock_throttled"}, true},
+		{&GovernorError{Code: "provider_error"}, true},
+		{&GovernorError{Code: "budget_exceeded"}, false},
+		{&GovernorError{Code: "model_not_allowed"}, false},
+		{&ValidationError{}, false},
+		{&LambdaFunctionError{ErrorMessage: "boom"}, true},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("wrapped: %w", context.Canceled), false},
+	}
+	for _, c := range cases {
+		if got := IsCircuitFailure(c.err); got != c.want {
+			t.Errorf("IsCircuitFailure(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
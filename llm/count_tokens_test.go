@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func conversation() *InvokeRequest {
+	return &InvokeRequest{
+		Model:  ModelSonnet46,
+		System: "You are terse.",
+		Messages: []Message{
+			UserMessage(TextBlock(strings.Repeat("a", 400))),
+			AssistantMessage(TextBlock("ok")),
+			UserMessage(TextBlock(strings.Repeat("b", 40))),
+		},
+	}
+}
+
+func TestMockBackend_CountTokens(t *testing.T) {
+	req := conversation()
+	resp, err := NewMockBackend().CountTokens(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.MessageTokens) != 3 {
+		t.Fatalf("expected 3 message counts, got %v", resp.MessageTokens)
+	}
+	var sum int64
+	for _, n := range resp.MessageTokens {
+		sum += n
+	}
+	if sum != resp.InputTokens || resp.InputTokens != estimateInputTokens(req) {
+		t.Errorf("expected counts to sum to %d, got %v (total %d)", estimateInputTokens(req), resp.MessageTokens, resp.InputTokens)
+	}
+	// 100 text tokens + 4 message overhead + 4 for the system prompt.
+	if resp.MessageTokens[0] != 108 || resp.MessageTokens[2] != 14 {
+		t.Errorf("unexpected per-message counts: %v", resp.MessageTokens)
+	}
+}
+
+func TestAnthropicBackend_CountTokens(t *testing.T) {
+	var bodies []map[string]interface{}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/v1/messages/count_tokens" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		var body map[string]interface{}
+		raw, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"input_tokens":250}`)),
+		}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+	req := conversation()
+	req.Messages = append(req.Messages, AssistantMessage(ToolUseBlock("call-1", "lookup", json.RawMessage(`{}`))))
+	resp, err := b.CountTokens(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("expected one request for the whole conversation, got %d", len(bodies))
+	}
+	if len(bodies[0]["messages"].([]interface{})) != 4 {
+		t.Errorf("expected all messages in the request, got %v", bodies[0]["messages"])
+	}
+	if _, ok := bodies[0]["max_tokens"]; ok {
+		t.Error("count_tokens requests must not include max_tokens")
+	}
+	if bodies[0]["model"] != "claude-sonnet-4-6" || bodies[0]["system"] != "You are terse." {
+		t.Errorf("unexpected request body: %v", bodies[0])
+	}
+
+	if resp.InputTokens != 250 || len(resp.MessageTokens) != 4 {
+		t.Fatalf("expected 250 tokens over 4 messages, got %+v", resp)
+	}
+	var sum int64
+	for _, n := range resp.MessageTokens {
+		sum += n
+	}
+	if sum != 250 {
+		t.Errorf("expected per-message counts to sum to the total, got %v", resp.MessageTokens)
+	}
+	if resp.MessageTokens[0] <= resp.MessageTokens[2] {
+		t.Errorf("expected counts in proportion to message size, got %v", resp.MessageTokens)
+	}
+}
+
+func TestLambdaBackend_CountTokens(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(CountTokensResponse{InputTokens: 42, MessageTokens: []int64{42}})
+	g := NewGovernor(WithFunctionName("gov"), WithLambdaClient(client), WithExecutionRunID("run-1"))
+
+	req := &InvokeRequest{Action: "invoke", Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}
+	resp, err := g.CountTokens(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.InputTokens != 42 {
+		t.Errorf("expected 42 tokens, got %d", resp.InputTokens)
+	}
+
+	var sent InvokeRequest
+	if err := json.Unmarshal(client.Payloads()[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Action != "count-tokens" || sent.ExecutionRunID != "run-1" {
+		t.Errorf("unexpected payload: %+v", sent)
+	}
+	if req.Action != "invoke" {
+		t.Error("expected the caller's request to be left unchanged")
+	}
+}
+
+func TestGovernor_CountTokensValidates(t *testing.T) {
+	g := NewGovernor(WithBackend(NewMockBackend()))
+	_, err := g.CountTokens(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected invalid request, got %v", err)
+	}
+}
+
+func TestGovernor_CountTokensIntercepted(t *testing.T) {
+	var seen []string
+	g := NewGovernor(WithBackend(NewMockBackend()), WithExecutionRunID("run-1"),
+		WithInterceptors(func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+			seen = append(seen, req.Action+" "+req.ExecutionRunID)
+			resp, err := next(ctx, req)
+			if err == nil {
+				resp.TokenCount.InputTokens++
+			}
+			return resp, err
+		}))
+
+	req := conversation()
+	resp, err := g.CountTokens(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(seen) != "[count-tokens run-1]" {
+		t.Errorf("expected the call to pass through the interceptor, got %v", seen)
+	}
+	if resp.InputTokens != estimateInputTokens(req)+1 {
+		t.Errorf("expected the interceptor's count, got %d", resp.InputTokens)
+	}
+	if req.Action != "" || req.ExecutionRunID != "" {
+		t.Errorf("expected the caller's request to be left unchanged, got %+v", req)
+	}
+
+	nilResult := NewGovernor(WithBackend(NewMockBackend()),
+		WithInterceptors(func(context.Context, *InvokeRequest, InvokeHandler) (*InvokeResponse, error) {
+			return nil, nil
+		}))
+	if _, err := nilResult.CountTokens(context.Background(), conversation()); err == nil {
+		t.Error("expected an error when the chain returns no result")
+	}
+}
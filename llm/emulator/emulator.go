@@ -0,0 +1,495 @@
+// Package emulator provides an in-process stand-in for the LLM Governor
+// Lambda. It implements the governor's "invoke", "count-tokens",
+// "check-budget", "list-models", "submit-batch", "batch-status" and
+// "batch-results" actions on top of any llm.Backend, enforcing budgets,
+// allowed models, size limits and throttling the way the governor does.
+//
+// Governor satisfies llm.LambdaInvoker, so the production LambdaBackend
+// code path — payload encoding, action routing and ErrorResponse decoding —
+// can be exercised offline:
+//
+//	emu := emulator.New(llm.NewMockBackend(), emulator.WithPeriodBudget("daily", 5))
+//	gov := llm.NewGovernor(llm.WithBackend(llm.NewLambdaBackend("llm-governor", emu)))
+package emulator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+
+	"github.com/pennsieve/pennsieve-go-llm/llm"
+)
+
+// Governor emulates the LLM Governor Lambda.
+type Governor struct {
+	backend llm.Backend
+
+	budgetPeriod              string
+	periodBudgetUsd           float64
+	defaultExecutionBudgetUsd float64
+	allowedModels             []string
+	maxSizeBytes              int64
+	dataDir                   string
+
+	mu                 sync.Mutex
+	periodUsedUsd      float64
+	executionBudgetUsd map[string]float64
+	executionUsedUsd   map[string]float64
+	throttleRemaining  int
+	throttleRetryAfter int
+	batchRuns          map[string]map[string]string
+}
+
+// Option configures a Governor.
+type Option func(*Governor)
+
+// WithPeriodBudget sets the budget period label and the spend allowed in
+// it. The default is 100 USD per "daily" period.
+func WithPeriodBudget(period string, usd float64) Option {
+	return func(g *Governor) {
+		g.budgetPeriod = period
+		g.periodBudgetUsd = usd
+	}
+}
+
+// WithExecutionBudget sets the budget applied to execution runs whose
+// requests do not set ExecutionBudgetUsd. Zero (the default) means runs
+// are only limited by the period budget.
+func WithExecutionBudget(usd float64) Option {
+	return func(g *Governor) {
+		g.defaultExecutionBudgetUsd = usd
+	}
+}
+
+// WithAllowedModels restricts invocations to the given model IDs. By
+// default every model is allowed.
+func WithAllowedModels(models ...string) Option {
+	return func(g *Governor) {
+		g.allowedModels = models
+	}
+}
+
+// WithMaxSizeBytes rejects request payloads and EFS documents larger than
+// n bytes with a request_too_large error.
+func WithMaxSizeBytes(n int64) Option {
+	return func(g *Governor) {
+		g.maxSizeBytes = n
+	}
+}
+
+// WithDataDir resolves efs_document paths relative to dir, as the governor
+// does with the compute node's data directory on EFS.
+func WithDataDir(dir string) Option {
+	return func(g *Governor) {
+		g.dataDir = dir
+	}
+}
+
+// New creates a governor emulator that delegates generation to backend.
+func New(backend llm.Backend, opts ...Option) *Governor {
+	g := &Governor{
+		backend:            backend,
+		budgetPeriod:       "daily",
+		periodBudgetUsd:    100,
+		executionBudgetUsd: map[string]float64{},
+		executionUsedUsd:   map[string]float64{},
+		batchRuns:          map[string]map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Throttle makes the next n invocations fail with bedrock_throttled,
+// advising callers to retry after retryAfterSec seconds.
+func (g *Governor) Throttle(n, retryAfterSec int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.throttleRemaining = n
+	g.throttleRetryAfter = retryAfterSec
+}
+
+// ResetPeriod clears the spend recorded for the current budget period.
+func (g *Governor) ResetPeriod() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.periodUsedUsd = 0
+}
+
+// Invoke implements llm.LambdaInvoker. Errors from the handler itself are
+// reported the way Lambda reports a crashed function: a FunctionError with
+// an errorMessage payload.
+func (g *Governor) Invoke(ctx context.Context, params *lambda.InvokeInput, _ ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	payload, err := g.Handle(ctx, params.Payload)
+	if err != nil {
+		crash, _ := json.Marshal(map[string]string{
+			"errorMessage": err.Error(),
+			"errorType":    "EmulatorError",
+		})
+		return &lambda.InvokeOutput{
+			StatusCode:    200,
+			FunctionError: aws.String("Unhandled"),
+			Payload:       crash,
+		}, nil
+	}
+	return &lambda.InvokeOutput{StatusCode: 200, Payload: payload}, nil
+}
+
+// Handle processes a raw governor payload and returns the raw response,
+// which is either the action's result or an llm.ErrorResponse.
+func (g *Governor) Handle(ctx context.Context, payload []byte) ([]byte, error) {
+	var envelope struct {
+		Action         string `json:"action"`
+		ExecutionRunID string `json:"executionRunId"`
+		BatchID        string `json:"batchId"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return errorPayload("invalid_request", fmt.Sprintf("malformed payload: %v", err))
+	}
+
+	switch envelope.Action {
+	case "invoke", "invoke-stream":
+		return g.handleInvoke(ctx, payload)
+	case "count-tokens":
+		return g.handleCountTokens(ctx, payload)
+	case "check-budget":
+		return g.handleCheckBudget(envelope.ExecutionRunID)
+	case "list-models":
+		return g.handleListModels(ctx)
+	case "submit-batch":
+		return g.handleSubmitBatch(ctx, payload)
+	case "batch-status":
+		return g.handleBatchStatus(ctx, envelope.BatchID)
+	case "batch-results":
+		return g.handleBatchResults(ctx, envelope.BatchID)
+	default:
+		return errorPayload("invalid_request", fmt.Sprintf("unknown action %q", envelope.Action))
+	}
+}
+
+func (g *Governor) handleInvoke(ctx context.Context, payload []byte) ([]byte, error) {
+	if g.maxSizeBytes > 0 && int64(len(payload)) > g.maxSizeBytes {
+		return json.Marshal(llm.ErrorResponse{
+			Error:        "request_too_large",
+			Message:      fmt.Sprintf("request is %d bytes, limit is %d", len(payload), g.maxSizeBytes),
+			MaxSizeBytes: g.maxSizeBytes,
+		})
+	}
+
+	var req llm.InvokeRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return errorPayload("invalid_request", fmt.Sprintf("malformed invoke request: %v", err))
+	}
+	if !g.modelAllowed(req.Model) {
+		return json.Marshal(llm.ErrorResponse{
+			Error:         "model_not_allowed",
+			Message:       fmt.Sprintf("model %s is not allowed", req.Model),
+			AllowedModels: g.allowedModels,
+			Model:         req.Model,
+		})
+	}
+	if errResp := g.resolveDocuments(&req); errResp != nil {
+		return json.Marshal(errResp)
+	}
+	if errResp := g.admit(&req); errResp != nil {
+		return json.Marshal(errResp)
+	}
+
+	resp, err := g.backend.Invoke(ctx, &req)
+	if err != nil {
+		return backendErrorPayload(err, req.Model)
+	}
+
+	g.mu.Lock()
+	g.periodUsedUsd += resp.Usage.EstimatedCostUsd
+	if req.ExecutionRunID != "" {
+		g.executionUsedUsd[req.ExecutionRunID] += resp.Usage.EstimatedCostUsd
+	}
+	resp.BudgetRemaining = g.budgetInfoLocked(req.ExecutionRunID)
+	g.mu.Unlock()
+
+	return json.Marshal(resp)
+}
+
+// admit records the run's budget and rejects the request if the governor
+// is throttling or the period or run budget is spent.
+func (g *Governor) admit(req *llm.InvokeRequest) *llm.ErrorResponse {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if req.ExecutionBudgetUsd > 0 && req.ExecutionRunID != "" {
+		g.executionBudgetUsd[req.ExecutionRunID] = req.ExecutionBudgetUsd
+	}
+	if g.throttleRemaining > 0 {
+		g.throttleRemaining--
+		return &llm.ErrorResponse{
+			Error:         "bedrock_throttled",
+			Message:       "request was throttled by Bedrock",
+			RetryAfterSec: g.throttleRetryAfter,
+		}
+	}
+	budget := g.budgetInfoLocked(req.ExecutionRunID)
+	exhausted := ""
+	switch {
+	case budget.PeriodRemainingUsd <= 0:
+		exhausted = fmt.Sprintf("%s budget of $%.2f exhausted", g.budgetPeriod, g.periodBudgetUsd)
+	case budget.ExecutionBudgetUsd > 0 && budget.ExecutionRemainingUsd <= 0:
+		exhausted = fmt.Sprintf("execution budget of $%.2f exhausted", budget.ExecutionBudgetUsd)
+	}
+	if exhausted != "" {
+		return &llm.ErrorResponse{
+			Error:           "budget_exceeded",
+			Message:         exhausted,
+			BudgetRemaining: &budget,
+		}
+	}
+	return nil
+}
+
+// resolveDocuments rewrites efs_document paths relative to the data
+// directory and enforces the size limit, as the governor does when it
+// reads documents from EFS.
+func (g *Governor) resolveDocuments(req *llm.InvokeRequest) *llm.ErrorResponse {
+	for i := range req.Messages {
+		for j := range req.Messages[i].Content {
+			block := &req.Messages[i].Content[j]
+			if block.Type != "efs_document" || g.dataDir == "" {
+				continue
+			}
+			clean := filepath.Clean(block.Path)
+			if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+				return &llm.ErrorResponse{
+					Error:   "invalid_request",
+					Message: fmt.Sprintf("document path %q must be relative to the data directory", block.Path),
+				}
+			}
+			full := filepath.Join(g.dataDir, clean)
+			if info, err := os.Stat(full); err == nil && g.maxSizeBytes > 0 && info.Size() > g.maxSizeBytes {
+				return &llm.ErrorResponse{
+					Error:        "request_too_large",
+					Message:      fmt.Sprintf("document %s is %d bytes, limit is %d", block.Path, info.Size(), g.maxSizeBytes),
+					MaxSizeBytes: g.maxSizeBytes,
+				}
+			}
+			block.Path = full
+		}
+	}
+	return nil
+}
+
+func (g *Governor) handleCountTokens(ctx context.Context, payload []byte) ([]byte, error) {
+	var req llm.InvokeRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return errorPayload("invalid_request", fmt.Sprintf("malformed count-tokens request: %v", err))
+	}
+	if !g.modelAllowed(req.Model) {
+		return json.Marshal(llm.ErrorResponse{
+			Error:         "model_not_allowed",
+			Message:       fmt.Sprintf("model %s is not allowed", req.Model),
+			AllowedModels: g.allowedModels,
+			Model:         req.Model,
+		})
+	}
+	if errResp := g.resolveDocuments(&req); errResp != nil {
+		return json.Marshal(errResp)
+	}
+
+	resp, err := g.backend.CountTokens(ctx, &req)
+	if err != nil {
+		return backendErrorPayload(err, req.Model)
+	}
+	return json.Marshal(resp)
+}
+
+// batchBackend returns the backend as an llm.BatchBackend, or false if it
+// does not support batches.
+func (g *Governor) batchBackend() (llm.BatchBackend, bool) {
+	bb, ok := g.backend.(llm.BatchBackend)
+	return bb, ok
+}
+
+func (g *Governor) handleSubmitBatch(ctx context.Context, payload []byte) ([]byte, error) {
+	bb, ok := g.batchBackend()
+	if !ok {
+		return errorPayload("invalid_request", "the backend does not support batches")
+	}
+	if g.maxSizeBytes > 0 && int64(len(payload)) > g.maxSizeBytes {
+		return json.Marshal(llm.ErrorResponse{
+			Error:        "request_too_large",
+			Message:      fmt.Sprintf("batch is %d bytes, limit is %d", len(payload), g.maxSizeBytes),
+			MaxSizeBytes: g.maxSizeBytes,
+		})
+	}
+
+	var submit llm.SubmitBatchRequest
+	if err := json.Unmarshal(payload, &submit); err != nil {
+		return errorPayload("invalid_request", fmt.Sprintf("malformed submit-batch request: %v", err))
+	}
+	if len(submit.Requests) == 0 {
+		return errorPayload("invalid_request", "batch has no requests")
+	}
+	runs := make(map[string]string, len(submit.Requests))
+	for _, br := range submit.Requests {
+		req := br.Request
+		if req == nil {
+			return errorPayload("invalid_request", fmt.Sprintf("batch request %q has no request", br.CustomID))
+		}
+		if !g.modelAllowed(req.Model) {
+			return json.Marshal(llm.ErrorResponse{
+				Error:         "model_not_allowed",
+				Message:       fmt.Sprintf("model %s is not allowed (batch request %q)", req.Model, br.CustomID),
+				AllowedModels: g.allowedModels,
+				Model:         req.Model,
+			})
+		}
+		if errResp := g.resolveDocuments(req); errResp != nil {
+			return json.Marshal(errResp)
+		}
+		if errResp := g.admit(req); errResp != nil {
+			return json.Marshal(errResp)
+		}
+		runs[br.CustomID] = req.ExecutionRunID
+	}
+
+	batch, err := bb.SubmitBatch(ctx, submit.Requests)
+	if err != nil {
+		return backendErrorPayload(err, "")
+	}
+	g.mu.Lock()
+	g.batchRuns[batch.ID] = runs
+	g.mu.Unlock()
+	return json.Marshal(batch)
+}
+
+func (g *Governor) handleBatchStatus(ctx context.Context, batchID string) ([]byte, error) {
+	bb, ok := g.batchBackend()
+	if !ok {
+		return errorPayload("invalid_request", "the backend does not support batches")
+	}
+	batch, err := bb.BatchStatus(ctx, batchID)
+	if err != nil {
+		return backendErrorPayload(err, "")
+	}
+	return json.Marshal(batch)
+}
+
+// handleBatchResults returns all results in a single page, recording the
+// cost of each response against its request's execution run.
+func (g *Governor) handleBatchResults(ctx context.Context, batchID string) ([]byte, error) {
+	bb, ok := g.batchBackend()
+	if !ok {
+		return errorPayload("invalid_request", "the backend does not support batches")
+	}
+	results, err := bb.BatchResults(ctx, batchID)
+	if err != nil {
+		return backendErrorPayload(err, "")
+	}
+	defer results.Close()
+
+	g.mu.Lock()
+	runs := g.batchRuns[batchID]
+	g.mu.Unlock()
+
+	page := llm.BatchResultsResponse{Results: []llm.BatchResult{}}
+	for results.Next() {
+		customID, resp, err := results.Result()
+		res := llm.BatchResult{CustomID: customID}
+		if err != nil {
+			ge, _ := llm.IsGovernorError(err)
+			res.Error = &llm.ErrorResponse{Error: ge.Code, Message: ge.Msg}
+		} else {
+			run := runs[customID]
+			billed := *resp
+			g.mu.Lock()
+			g.periodUsedUsd += resp.Usage.EstimatedCostUsd
+			if run != "" {
+				g.executionUsedUsd[run] += resp.Usage.EstimatedCostUsd
+			}
+			billed.BudgetRemaining = g.budgetInfoLocked(run)
+			g.mu.Unlock()
+			res.Response = &billed
+		}
+		page.Results = append(page.Results, res)
+	}
+	if err := results.Err(); err != nil {
+		return backendErrorPayload(err, "")
+	}
+	return json.Marshal(page)
+}
+
+func (g *Governor) handleCheckBudget(executionRunID string) ([]byte, error) {
+	g.mu.Lock()
+	budget := g.budgetInfoLocked(executionRunID)
+	g.mu.Unlock()
+	return json.Marshal(llm.CheckBudgetResponse(budget))
+}
+
+func (g *Governor) handleListModels(ctx context.Context) ([]byte, error) {
+	resp, err := g.backend.ListModels(ctx)
+	if err != nil {
+		return errorPayload("provider_error", err.Error())
+	}
+	models := make([]llm.ModelInfo, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		if !g.modelAllowed(m.ModelID) {
+			m.Status = "not_allowed"
+			m.Hint = "not in the allowed model list for this deployment"
+		}
+		models = append(models, m)
+	}
+	return json.Marshal(llm.ListModelsResponse{Models: models})
+}
+
+func (g *Governor) modelAllowed(model string) bool {
+	return len(g.allowedModels) == 0 || slices.Contains(g.allowedModels, model)
+}
+
+// budgetInfoLocked reports the budget state for a run. g.mu must be held.
+func (g *Governor) budgetInfoLocked(executionRunID string) llm.BudgetInfo {
+	info := llm.BudgetInfo{
+		BudgetPeriod:       g.budgetPeriod,
+		PeriodBudgetUsd:    g.periodBudgetUsd,
+		PeriodUsedUsd:      g.periodUsedUsd,
+		PeriodRemainingUsd: max(g.periodBudgetUsd-g.periodUsedUsd, 0),
+	}
+	execBudget, ok := g.executionBudgetUsd[executionRunID]
+	if !ok {
+		execBudget = g.defaultExecutionBudgetUsd
+	}
+	if execBudget > 0 {
+		used := g.executionUsedUsd[executionRunID]
+		info.ExecutionBudgetUsd = execBudget
+		info.ExecutionUsedUsd = used
+		info.ExecutionRemainingUsd = max(execBudget-used, 0)
+	}
+	return info
+}
+
+func errorPayload(code, msg string) ([]byte, error) {
+	return json.Marshal(llm.ErrorResponse{Error: code, Message: msg})
+}
+
+// backendErrorPayload reports a backend failure as the governor would:
+// GovernorErrors keep their code and anything else is a provider_error.
+func backendErrorPayload(err error, model string) ([]byte, error) {
+	if ge, ok := llm.IsGovernorError(err); ok {
+		return json.Marshal(llm.ErrorResponse{
+			Error:           ge.Code,
+			Message:         ge.Msg,
+			AllowedModels:   ge.AllowedModels,
+			BudgetRemaining: ge.BudgetRemaining,
+			RetryAfterSec:   ge.RetryAfterSec,
+			Model:           model,
+		})
+	}
+	return errorPayload("provider_error", err.Error())
+}
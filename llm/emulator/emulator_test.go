@@ -0,0 +1,338 @@
+package emulator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pennsieve/pennsieve-go-llm/llm"
+)
+
+func newTestGovernor(backend llm.Backend, opts ...Option) (*Governor, *llm.Governor) {
+	emu := New(backend, opts...)
+	gov := llm.NewGovernor(
+		llm.WithBackend(llm.NewLambdaBackend("llm-governor", emu)),
+		llm.WithExecutionRunID("run-1"),
+	)
+	return emu, gov
+}
+
+func costlyResponse(text string, cost float64) *llm.InvokeResponse {
+	return &llm.InvokeResponse{
+		Content:    []llm.ResponseContent{{Type: "text", Text: text}},
+		Model:      llm.ModelHaiku45,
+		StopReason: "end_turn",
+		Usage:      llm.UsageInfo{InputTokens: 10, OutputTokens: 5, EstimatedCostUsd: cost},
+	}
+}
+
+func TestEmulator_Invoke(t *testing.T) {
+	mock := llm.NewMockBackend()
+	mock.SetResponse(costlyResponse("hello", 0.25))
+	_, gov := newTestGovernor(mock, WithPeriodBudget("daily", 1))
+
+	resp, err := gov.Invoke(context.Background(), &llm.InvokeRequest{
+		Model:    llm.ModelHaiku45,
+		Messages: []llm.Message{llm.UserMessage(llm.TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "hello" {
+		t.Errorf("expected 'hello', got %q", resp.Text())
+	}
+	if resp.BudgetRemaining.PeriodUsedUsd != 0.25 || resp.BudgetRemaining.PeriodRemainingUsd != 0.75 {
+		t.Errorf("unexpected budget: %+v", resp.BudgetRemaining)
+	}
+	if calls := mock.Calls(); len(calls) != 1 || calls[0].ExecutionRunID != "run-1" {
+		t.Errorf("expected the request to reach the backend, got %+v", calls)
+	}
+}
+
+func TestEmulator_PeriodBudgetExceeded(t *testing.T) {
+	mock := llm.NewMockBackend()
+	mock.SetResponse(costlyResponse("hello", 0.6))
+	_, gov := newTestGovernor(mock, WithPeriodBudget("daily", 1))
+
+	for i := 0; i < 2; i++ {
+		if _, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi"); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	_, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi")
+	ge, ok := llm.IsGovernorError(err)
+	if !ok || !ge.IsBudgetExceeded() {
+		t.Fatalf("expected budget_exceeded, got %v", err)
+	}
+	if ge.BudgetRemaining == nil || ge.BudgetRemaining.BudgetPeriod != "daily" {
+		t.Errorf("expected budget details, got %+v", ge.BudgetRemaining)
+	}
+	if len(mock.Calls()) != 2 {
+		t.Errorf("expected rejected call not to reach the backend, got %d calls", len(mock.Calls()))
+	}
+}
+
+func TestEmulator_ExecutionBudgetPerRun(t *testing.T) {
+	mock := llm.NewMockBackend()
+	mock.SetResponse(costlyResponse("hello", 0.5))
+	emu, gov := newTestGovernor(mock, WithExecutionBudget(0.5))
+
+	if _, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi"); !errorsIsBudget(err) {
+		t.Fatalf("expected run-1 to be out of budget, got %v", err)
+	}
+
+	other := llm.NewGovernor(
+		llm.WithBackend(llm.NewLambdaBackend("llm-governor", emu)),
+		llm.WithExecutionRunID("run-2"),
+	)
+	if _, err := other.Ask(context.Background(), llm.ModelHaiku45, "hi"); err != nil {
+		t.Errorf("expected run-2 to have its own budget, got %v", err)
+	}
+}
+
+func errorsIsBudget(err error) bool {
+	ge, ok := llm.IsGovernorError(err)
+	return ok && ge.IsBudgetExceeded()
+}
+
+func TestEmulator_ModelNotAllowed(t *testing.T) {
+	_, gov := newTestGovernor(llm.NewMockBackend(), WithAllowedModels(llm.ModelHaiku45))
+
+	_, err := gov.Ask(context.Background(), llm.ModelSonnet46, "hi")
+	ge, ok := llm.IsGovernorError(err)
+	if !ok || !ge.IsModelNotAllowed() {
+		t.Fatalf("expected model_not_allowed, got %v", err)
+	}
+	if len(ge.AllowedModels) != 1 || ge.AllowedModels[0] != llm.ModelHaiku45 {
+		t.Errorf("unexpected allowed models: %v", ge.AllowedModels)
+	}
+
+	models, err := gov.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range models.Models {
+		allowed := m.ModelID == llm.ModelHaiku45
+		if allowed == (m.Status == "not_allowed") {
+			t.Errorf("unexpected status for %s: %q", m.ModelID, m.Status)
+		}
+	}
+}
+
+func TestEmulator_RequestTooLarge(t *testing.T) {
+	_, gov := newTestGovernor(llm.NewMockBackend(), WithMaxSizeBytes(256))
+
+	_, err := gov.Ask(context.Background(), llm.ModelHaiku45, strings.Repeat("x", 1024))
+	ge, ok := llm.IsGovernorError(err)
+	if !ok || ge.Code != "request_too_large" {
+		t.Fatalf("expected request_too_large, got %v", err)
+	}
+}
+
+func TestEmulator_Throttle(t *testing.T) {
+	emu, gov := newTestGovernor(llm.NewMockBackend())
+	emu.Throttle(1, 3)
+
+	_, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi")
+	ge, ok := llm.IsGovernorError(err)
+	if !ok || !ge.IsThrottled() {
+		t.Fatalf("expected bedrock_throttled, got %v", err)
+	}
+	if ge.RetryAfterSec != 3 {
+		t.Errorf("expected retry after 3s, got %d", ge.RetryAfterSec)
+	}
+	if _, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi"); err != nil {
+		t.Errorf("expected throttling to clear, got %v", err)
+	}
+}
+
+func TestEmulator_BackendError(t *testing.T) {
+	mock := llm.NewMockBackend()
+	mock.QueueErrors(&llm.GovernorError{Code: "model_overloaded", Msg: "busy"})
+	_, gov := newTestGovernor(mock)
+
+	_, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi")
+	ge, ok := llm.IsGovernorError(err)
+	if !ok || !ge.IsOverloaded() || ge.Msg != "busy" {
+		t.Errorf("expected backend error to round-trip, got %v", err)
+	}
+}
+
+func TestEmulator_CheckBudget(t *testing.T) {
+	mock := llm.NewMockBackend()
+	mock.SetResponse(costlyResponse("hello", 0.1))
+	_, gov := newTestGovernor(mock, WithPeriodBudget("monthly", 20), WithExecutionBudget(2))
+
+	if _, err := gov.Ask(context.Background(), llm.ModelHaiku45, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	budget, err := gov.CheckBudget(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.BudgetPeriod != "monthly" || budget.ExecutionBudgetUsd != 2 || budget.ExecutionUsedUsd != 0.1 {
+		t.Errorf("unexpected budget: %+v", budget)
+	}
+}
+
+func TestEmulator_UnknownAction(t *testing.T) {
+	out, err := New(llm.NewMockBackend()).Handle(context.Background(), []byte(`{"action":"reboot"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `"error":"invalid_request"`) {
+		t.Errorf("expected invalid_request, got %s", out)
+	}
+}
+
+func TestEmulator_DataDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("small"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "big.txt"), []byte(strings.Repeat("x", 4096)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mock := llm.NewMockBackend()
+	_, gov := newTestGovernor(mock, WithDataDir(dir), WithMaxSizeBytes(2048))
+
+	if _, err := gov.AskAboutFile(context.Background(), llm.ModelHaiku45, "summarize", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+	calls := mock.Calls()
+	if got := calls[0].Messages[0].Content[1].Path; got != filepath.Join(dir, "notes.txt") {
+		t.Errorf("expected path resolved against data dir, got %q", got)
+	}
+
+	_, err := gov.AskAboutFile(context.Background(), llm.ModelHaiku45, "summarize", "big.txt")
+	if ge, ok := llm.IsGovernorError(err); !ok || ge.Code != "request_too_large" {
+		t.Errorf("expected request_too_large for big document, got %v", err)
+	}
+	_, err = gov.AskAboutFile(context.Background(), llm.ModelHaiku45, "summarize", "../etc/passwd")
+	if !errors.Is(err, llm.ErrInvalidRequest) {
+		t.Errorf("expected LambdaBackend to reject the escaping path, got %v", err)
+	}
+
+	// Payloads from other clients are checked by the emulator itself.
+	payload, err := json.Marshal(&llm.InvokeRequest{
+		Action:   "invoke",
+		Model:    llm.ModelHaiku45,
+		Messages: []llm.Message{llm.UserMessage(llm.TextBlock("summarize"), llm.FileBlock("../etc/passwd"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := New(mock, WithDataDir(dir)).Handle(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errResp llm.ErrorResponse
+	if err := json.Unmarshal(out, &errResp); err != nil || errResp.Error != "invalid_request" {
+		t.Errorf("expected invalid_request for escaping path, got %s", out)
+	}
+}
+
+func TestEmulator_StreamFallsBackToInvoke(t *testing.T) {
+	_, gov := newTestGovernor(llm.NewMockBackend())
+
+	s, err := gov.AskStream(context.Background(), llm.ModelHaiku45, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for s.Next() {
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Response().Text() != "[mock] hello" {
+		t.Errorf("expected '[mock] hello', got %q", s.Response().Text())
+	}
+}
+
+func TestEmulator_CountTokens(t *testing.T) {
+	_, gov := newTestGovernor(llm.NewMockBackend(), WithAllowedModels(llm.ModelHaiku45))
+
+	resp, err := gov.CountTokens(context.Background(), &llm.InvokeRequest{
+		Model:    llm.ModelHaiku45,
+		Messages: []llm.Message{llm.UserMessage(llm.TextBlock(strings.Repeat("x", 40)))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.InputTokens != 14 || len(resp.MessageTokens) != 1 {
+		t.Errorf("unexpected count: %+v", resp)
+	}
+
+	_, err = gov.CountTokens(context.Background(), &llm.InvokeRequest{
+		Model:    llm.ModelSonnet46,
+		Messages: []llm.Message{llm.UserMessage(llm.TextBlock("hi"))},
+	})
+	if ge, ok := llm.IsGovernorError(err); !ok || !ge.IsModelNotAllowed() {
+		t.Errorf("expected model_not_allowed, got %v", err)
+	}
+}
+
+func TestEmulator_Batch(t *testing.T) {
+	mock := llm.NewMockBackend()
+	mock.SetResponse(costlyResponse("done", 0.25))
+	_, gov := newTestGovernor(mock, WithPeriodBudget("daily", 1), WithAllowedModels(llm.ModelHaiku45))
+	ctx := context.Background()
+
+	requests := []llm.BatchRequest{
+		{CustomID: "a", Request: &llm.InvokeRequest{Model: llm.ModelHaiku45, Messages: []llm.Message{llm.UserMessage(llm.TextBlock("a"))}}},
+		{CustomID: "b", Request: &llm.InvokeRequest{Model: llm.ModelHaiku45, Messages: []llm.Message{llm.UserMessage(llm.TextBlock("b"))}}},
+	}
+	batch, err := gov.SubmitBatch(ctx, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	final, err := gov.WaitBatch(ctx, batch.ID, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final.Counts.Succeeded != 2 {
+		t.Errorf("unexpected counts: %+v", final.Counts)
+	}
+
+	results, err := gov.BatchResults(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer results.Close()
+	n := 0
+	for results.Next() {
+		_, resp, err := results.Result()
+		if err != nil || resp.Text() != "done" {
+			t.Errorf("unexpected result: %v, %v", resp, err)
+		}
+		n++
+	}
+	if err := results.Err(); err != nil || n != 2 {
+		t.Fatalf("expected 2 results, got %d (%v)", n, err)
+	}
+
+	budget, err := gov.CheckBudget(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.PeriodUsedUsd != 0.5 {
+		t.Errorf("expected $0.50 spent, got %g", budget.PeriodUsedUsd)
+	}
+
+	requests[1].Request.Model = llm.ModelSonnet46
+	if _, err := gov.SubmitBatch(ctx, requests); !errors.Is(err, llm.ErrModelNotAllowed) {
+		t.Errorf("expected model_not_allowed, got %v", err)
+	}
+	if _, err := gov.BatchStatus(ctx, "missing"); !errors.Is(err, llm.ErrNotFound) {
+		t.Errorf("expected not_found, got %v", err)
+	}
+}
@@ -1,6 +1,48 @@
 package llm
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors for use with errors.Is. A GovernorError matches the
+// sentinel for its Code, so callers can write
+//
+//	if errors.Is(err, llm.ErrThrottled) { ... }
+var (
+	ErrBudgetExceeded     = errors.New("llm: budget exceeded")
+	ErrModelNotAllowed    = errors.New("llm: model not allowed")
+	ErrProviderNotAllowed = errors.New("llm: provider not allowed")
+	ErrModelNotEnabled    = errors.New("llm: model not enabled")
+	ErrThrottled          = errors.New("llm: throttled")
+	ErrOverloaded         = errors.New("llm: model overloaded")
+	ErrInvalidRequest     = errors.New("llm: invalid request")
+	ErrAuthFailure        = errors.New("llm: authentication failed")
+	ErrNotFound           = errors.New("llm: not found")
+	ErrRequestTooLarge    = errors.New("llm: request too large")
+	ErrProviderError      = errors.New("llm: provider error")
+	ErrRequestCanceled    = errors.New("llm: batch request canceled")
+	ErrRequestExpired     = errors.New("llm: batch request expired")
+)
+
+// codeSentinels maps GovernorError codes to their sentinel errors.
+var codeSentinels = map[string]error{
+	"budget_exceeded":       ErrBudgetExceeded,
+	"model_not_allowed":     ErrModelNotAllowed,
+	"provider_not_allowed":  ErrProviderNotAllowed,
+	"model_not_enabled":     ErrModelNotEnabled,
+	"bedrock_throttled":     ErrThrottled,
+	"model_overloaded":      ErrOverloaded,
+	"invalid_request":       ErrInvalidRequest,
+	"authentication_failed": ErrAuthFailure,
+	"permission_denied":     ErrAuthFailure,
+	"not_found":             ErrNotFound,
+	"request_too_large":     ErrRequestTooLarge,
+	"provider_error":        ErrProviderError,
+	"request_canceled":      ErrRequestCanceled,
+	"request_expired":       ErrRequestExpired,
+}
 
 // GovernorError represents an error returned by the LLM Governor.
 type GovernorError struct {
@@ -11,10 +53,27 @@ type GovernorError struct {
 	RetryAfterSec   int
 }
 
+// governorError converts a governor error payload into a GovernorError.
+func (r *ErrorResponse) governorError() *GovernorError {
+	return &GovernorError{
+		Code:            r.Error,
+		Msg:             r.Message,
+		AllowedModels:   r.AllowedModels,
+		BudgetRemaining: r.BudgetRemaining,
+		RetryAfterSec:   r.RetryAfterSec,
+	}
+}
+
 func (e *GovernorError) Error() string {
 	return fmt.Sprintf("governor error [%s]: %s", e.Code, e.Msg)
 }
 
+// Is reports whether target is the sentinel error for e's Code.
+func (e *GovernorError) Is(target error) bool {
+	sentinel, ok := codeSentinels[e.Code]
+	return ok && sentinel == target
+}
+
 // IsBudgetExceeded returns true if the error is a budget_exceeded error.
 func (e *GovernorError) IsBudgetExceeded() bool {
 	return e.Code == "budget_exceeded"
@@ -40,10 +99,105 @@ func (e *GovernorError) IsThrottled() bool {
 	return e.Code == "bedrock_throttled"
 }
 
-// IsGovernorError checks whether an error is a GovernorError and returns it.
+// IsOverloaded returns true if the model provider is temporarily overloaded.
+func (e *GovernorError) IsOverloaded() bool {
+	return e.Code == "model_overloaded"
+}
+
+// IsInvalidRequest returns true if the request was rejected as malformed.
+func (e *GovernorError) IsInvalidRequest() bool {
+	return e.Code == "invalid_request"
+}
+
+// IsAuthFailure returns true if the credentials were missing, invalid, or
+// not permitted to make the request.
+func (e *GovernorError) IsAuthFailure() bool {
+	return e.Code == "authentication_failed" || e.Code == "permission_denied"
+}
+
+// IsNotFound returns true if the model or endpoint does not exist.
+func (e *GovernorError) IsNotFound() bool {
+	return e.Code == "not_found"
+}
+
+// IsRequestTooLarge returns true if the request exceeded the size limit.
+func (e *GovernorError) IsRequestTooLarge() bool {
+	return e.Code == "request_too_large"
+}
+
+// errorResponse converts err to the governor's error format. A
+// GovernorError keeps its code; anything else is a provider_error.
+func errorResponse(err error) *ErrorResponse {
+	if ge, ok := IsGovernorError(err); ok {
+		return &ErrorResponse{
+			Error:           ge.Code,
+			Message:         ge.Msg,
+			AllowedModels:   ge.AllowedModels,
+			BudgetRemaining: ge.BudgetRemaining,
+			RetryAfterSec:   ge.RetryAfterSec,
+		}
+	}
+	return &ErrorResponse{Error: "provider_error", Message: err.Error()}
+}
+
+// IsGovernorError checks whether an error is, or wraps, a GovernorError
+// and returns it.
 func IsGovernorError(err error) (*GovernorError, bool) {
-	if ge, ok := err.(*GovernorError); ok {
+	var ge *GovernorError
+	if errors.As(err, &ge) {
 		return ge, true
 	}
 	return nil, false
-}
\ No newline at end of file
+}
+
+// transientFunctionErrors are Lambda error types caused by the execution
+// environment rather than by the request, so a fresh invocation of the same
+// request may succeed.
+var transientFunctionErrors = map[string]bool{
+	"Runtime.ExitError": true,
+	"Runtime.Unknown":   true,
+	"Sandbox.Timedout":  true,
+}
+
+// LambdaFunctionError reports that the governor Lambda itself failed while
+// handling a request, as opposed to returning a governor error response.
+// The fields are parsed from the function's error payload.
+type LambdaFunctionError struct {
+	// FunctionError is Lambda's classification: "Unhandled" or "Handled".
+	FunctionError string
+	ErrorType     string
+	ErrorMessage  string
+	StackTrace    []string
+
+	// LogTail holds the last 4 KB of the invocation's log when the backend
+	// was configured with WithLogTail.
+	LogTail string
+
+	RequestID  string
+	StatusCode int32
+}
+
+func (e *LambdaFunctionError) Error() string {
+	kind := e.ErrorType
+	if kind == "" {
+		kind = e.FunctionError
+	}
+	return fmt.Sprintf("governor function error [%s]: %s", kind, e.ErrorMessage)
+}
+
+// Transient returns true if the function crashed or timed out for reasons
+// unrelated to the request. Other function errors, such as validation
+// failures in the handler, will recur if the request is sent again.
+func (e *LambdaFunctionError) Transient() bool {
+	return transientFunctionErrors[e.ErrorType] || strings.Contains(e.ErrorMessage, "Task timed out")
+}
+
+// IsLambdaFunctionError checks whether an error is, or wraps, a
+// LambdaFunctionError and returns it.
+func IsLambdaFunctionError(err error) (*LambdaFunctionError, bool) {
+	var fe *LambdaFunctionError
+	if errors.As(err, &fe) {
+		return fe, true
+	}
+	return nil, false
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"slices"
+)
+
+// defaultFallbackCodes are the GovernorError codes that make the Governor
+// try the next fallback model unless WithFallbackCodes is given.
+var defaultFallbackCodes = []string{
+	"bedrock_throttled",
+	"model_overloaded",
+	"model_not_enabled",
+	"model_not_allowed",
+}
+
+// WithFallbackModels sets an ordered list of models to try, one after
+// another, when a request fails with one of the fallback codes (by
+// default bedrock_throttled, model_overloaded, model_not_enabled and
+// model_not_allowed). Requests can override the list with
+// InvokeRequest.FallbackModels.
+//
+// Models that a model_not_allowed error's AllowedModels excludes are
+// skipped. When a fallback model answers, InvokeResponse.FallbackUsed is
+// set and InvokeResponse.FallbackModel is its ID from the list.
+func WithFallbackModels(models ...string) GovernorOption {
+	return func(g *Governor) {
+		g.fallbackModels = models
+	}
+}
+
+// WithFallbackCodes sets the GovernorError codes that trigger a fallback
+// to the next model.
+func WithFallbackCodes(codes ...string) GovernorOption {
+	return func(g *Governor) {
+		g.fallbackCodes = codes
+	}
+}
+
+// invokeWithFallback invokes req, falling back to other models as
+// configured.
+func (g *Governor) invokeWithFallback(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	var resp *InvokeResponse
+	model, err := g.withFallback(req, func(attempt *InvokeRequest) error {
+		var err error
+		resp, err = g.backend.Invoke(ctx, attempt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	answered := *resp
+	if answered.Model == "" {
+		answered.Model = model
+	}
+	if model != req.Model {
+		answered.FallbackUsed = true
+		answered.FallbackModel = model
+	}
+	return &answered, nil
+}
+
+// invokeStreamWithFallback opens a stream for req, falling back to other
+// models if it cannot be opened. Errors after the stream has started are
+// returned to the caller. The message_stop event reports the fallback
+// model that answered, as invokeWithFallback does.
+func (g *Governor) invokeStreamWithFallback(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	var stream *Stream
+	model, err := g.withFallback(req, func(attempt *InvokeRequest) error {
+		var err error
+		stream, err = g.backend.InvokeStream(ctx, attempt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inner := stream
+	return newStream(ctx, func(_ context.Context, emit func(StreamEvent) error) error {
+		defer inner.Close()
+		for inner.Next() {
+			ev := inner.Event()
+			if ev.Type == "message_stop" {
+				if ev.Model == "" {
+					ev.Model = model
+				}
+				if model != req.Model {
+					ev.FallbackUsed = true
+					ev.FallbackModel = model
+				}
+			}
+			if err := emit(ev); err != nil {
+				return err
+			}
+		}
+		return inner.Err()
+	}), nil
+}
+
+// withFallback calls call with req and then, while it fails with a
+// fallback code, with a copy of req for each permitted fallback model in
+// turn. It returns the model of the successful call, or the last error.
+func (g *Governor) withFallback(req *InvokeRequest, call func(*InvokeRequest) error) (string, error) {
+	err := call(req)
+	if err == nil {
+		return req.Model, nil
+	}
+
+	models := g.fallbackModels
+	if req.FallbackModels != nil {
+		models = req.FallbackModels
+	}
+	codes := g.fallbackCodes
+	if codes == nil {
+		codes = defaultFallbackCodes
+	}
+
+	var allowed []string
+	tried := []string{req.Model}
+	for _, model := range models {
+		ge, ok := IsGovernorError(err)
+		if !ok || !slices.Contains(codes, ge.Code) {
+			return "", err
+		}
+		if len(ge.AllowedModels) > 0 {
+			allowed = ge.AllowedModels
+		}
+		if slices.Contains(tried, model) || allowed != nil && !slices.Contains(allowed, model) {
+			continue
+		}
+		tried = append(tried, model)
+
+		attempt := *req
+		attempt.Model = model
+		if err = call(&attempt); err == nil {
+			return model, nil
+		}
+	}
+	return "", err
+}
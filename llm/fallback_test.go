@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func calledModels(mock *MockBackend) []string {
+	var models []string
+	for _, c := range mock.Calls() {
+		models = append(models, c.Model)
+	}
+	return models
+}
+
+func TestGovernor_FallbackModels(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "bedrock_throttled"}, &GovernorError{Code: "model_not_enabled"})
+	g := NewGovernor(WithBackend(mock), WithFallbackModels(ModelSonnet45, ModelHaiku45))
+
+	resp, err := g.Invoke(context.Background(), &InvokeRequest{
+		Model:    ModelSonnet46,
+		Messages: []Message{UserMessage(TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Model != ModelHaiku45 || !resp.FallbackUsed || resp.FallbackModel != ModelHaiku45 {
+		t.Errorf("expected the answer from fallback %s, got %q (fallback %t, %q)", ModelHaiku45, resp.Model, resp.FallbackUsed, resp.FallbackModel)
+	}
+	want := []string{ModelSonnet46, ModelSonnet45, ModelHaiku45}
+	if got := calledModels(mock); !slices.Equal(got, want) {
+		t.Errorf("expected calls to %v, got %v", want, got)
+	}
+}
+
+func TestGovernor_FallbackSkipsDisallowedModels(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "model_not_allowed", AllowedModels: []string{ModelHaiku45}})
+	g := NewGovernor(WithBackend(mock), WithFallbackModels(ModelSonnet45, ModelHaiku45))
+
+	resp, err := g.Ask(context.Background(), ModelSonnet46, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "[mock] hi" {
+		t.Errorf("unexpected response %q", resp)
+	}
+	want := []string{ModelSonnet46, ModelHaiku45}
+	if got := calledModels(mock); !slices.Equal(got, want) {
+		t.Errorf("expected calls to %v, got %v", want, got)
+	}
+}
+
+func TestGovernor_FallbackCodes(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "budget_exceeded"}, &GovernorError{Code: "bedrock_throttled"})
+	g := NewGovernor(WithBackend(mock), WithFallbackModels(ModelHaiku45))
+
+	if _, err := g.Ask(context.Background(), ModelSonnet46, "hi"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget_exceeded without fallback, got %v", err)
+	}
+
+	g = NewGovernor(WithBackend(mock), WithFallbackModels(ModelHaiku45), WithFallbackCodes("model_overloaded"))
+	if _, err := g.Ask(context.Background(), ModelSonnet46, "hi"); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected throttled without fallback, got %v", err)
+	}
+	if n := len(mock.Calls()); n != 2 {
+		t.Errorf("expected 2 calls, got %d", n)
+	}
+}
+
+func TestGovernor_FallbackPerRequest(t *testing.T) {
+	mock := NewMockBackend()
+	throttled := &GovernorError{Code: "bedrock_throttled"}
+	mock.QueueErrors(throttled)
+	g := NewGovernor(WithBackend(mock), WithFallbackModels(ModelSonnet45))
+
+	resp, err := g.Invoke(context.Background(), &InvokeRequest{
+		Model:          ModelSonnet46,
+		Messages:       []Message{UserMessage(TextBlock("hi"))},
+		FallbackModels: []string{ModelHaiku45},
+	})
+	if err != nil || resp.Model != ModelHaiku45 {
+		t.Fatalf("expected the per-request fallback to answer, got %v, %v", resp, err)
+	}
+
+	mock.QueueErrors(throttled)
+	_, err = g.Invoke(context.Background(), &InvokeRequest{
+		Model:          ModelSonnet46,
+		Messages:       []Message{UserMessage(TextBlock("hi"))},
+		FallbackModels: []string{},
+	})
+	if !errors.Is(err, ErrThrottled) {
+		t.Errorf("expected an empty list to disable fallback, got %v", err)
+	}
+}
+
+func TestGovernor_FallbackStream(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "model_overloaded"})
+	g := NewGovernor(WithBackend(mock), WithFallbackModels(ModelHaiku45))
+
+	s, err := g.AskStream(context.Background(), ModelSonnet46, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectStream(t, s)
+	if s.Response().FallbackModel != ModelHaiku45 || !s.Response().FallbackUsed || s.Response().Text() != "[mock] hi" {
+		t.Errorf("unexpected streamed response: %+v", s.Response())
+	}
+}
+
+func TestGovernor_ResponseModelKept(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(&InvokeResponse{
+		Content: []ResponseContent{{Type: "text", Text: "ok"}},
+		Model:   "claude-sonnet-4-5",
+	})
+	mock.QueueErrors(nil, &GovernorError{Code: "model_overloaded"})
+	g := NewGovernor(WithBackend(mock), WithFallbackModels(ModelSonnet45))
+	req := func() *InvokeRequest {
+		return &InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}
+	}
+
+	resp, err := g.Invoke(context.Background(), req())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Model != "claude-sonnet-4-5" || resp.FallbackUsed || resp.FallbackModel != "" {
+		t.Errorf("expected the provider's model without a fallback, got %q (fallback %t, %q)", resp.Model, resp.FallbackUsed, resp.FallbackModel)
+	}
+
+	resp, err = g.Invoke(context.Background(), req())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Model != "claude-sonnet-4-5" || !resp.FallbackUsed || resp.FallbackModel != ModelSonnet45 {
+		t.Errorf("expected the provider's model and fallback %s, got %q (fallback %t, %q)", ModelSonnet45, resp.Model, resp.FallbackUsed, resp.FallbackModel)
+	}
+
+	s, err := g.AskStream(context.Background(), ModelHaiku45, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectStream(t, s)
+	if s.Response().Model != "claude-sonnet-4-5" || s.Response().FallbackUsed {
+		t.Errorf("expected the streamed provider's model without a fallback, got %q (fallback %t)", s.Response().Model, s.Response().FallbackUsed)
+	}
+}
@@ -2,17 +2,30 @@ package llm
 
 import (
 	"context"
+	"log/slog"
 	"os"
-
-	"github.com/aws/aws-sdk-go-v2/service/lambda"
 )
 
 // Governor is a client for the Pennsieve LLM platform.
 type Governor struct {
 	functionName   string
 	executionRunID string
-	lambdaClient   *lambda.Client
+	lambdaClient   LambdaInvoker
+	lambdaOptions  []LambdaOption
 	backend        Backend
+	base           Backend
+	retryPolicy    *RetryPolicy
+	rateLimit      *RateLimit
+	circuitPolicy  *CircuitBreakerPolicy
+	ledgerOptions  []LedgerOption
+	useLedger      bool
+	skipValidation bool
+	fallbackModels []string
+	fallbackCodes  []string
+	interceptors   []Interceptor
+	logger         *slog.Logger
+	logLevels      *LogLevels
+	logBodies      int
 }
 
 // GovernorOption configures a Governor instance.
@@ -34,13 +47,23 @@ func WithExecutionRunID(id string) GovernorOption {
 	}
 }
 
-// WithLambdaClient provides a custom Lambda client (useful for testing).
-func WithLambdaClient(client *lambda.Client) GovernorOption {
+// WithLambdaClient provides a custom Lambda client, such as a configured
+// *lambda.Client or a MockLambdaClient for testing.
+func WithLambdaClient(client LambdaInvoker) GovernorOption {
 	return func(g *Governor) {
 		g.lambdaClient = client
 	}
 }
 
+// WithLambdaLogTail requests the tail of the governor's execution log with
+// every Lambda invocation, so that LambdaFunctionError.LogTail is populated
+// when the governor fails.
+func WithLambdaLogTail() GovernorOption {
+	return func(g *Governor) {
+		g.lambdaOptions = append(g.lambdaOptions, WithLogTail())
+	}
+}
+
 // WithBackend provides an explicit backend, overriding automatic selection.
 func WithBackend(b Backend) GovernorOption {
 	return func(g *Governor) {
@@ -48,6 +71,53 @@ func WithBackend(b Backend) GovernorOption {
 	}
 }
 
+// WithRetryPolicy retries throttled and overloaded calls on any backend
+// according to policy. See RetryBackend.
+func WithRetryPolicy(policy RetryPolicy) GovernorOption {
+	return func(g *Governor) {
+		g.retryPolicy = &policy
+	}
+}
+
+// WithRateLimit paces invocations on any backend to stay within limit,
+// shared by every call made through the Governor. See RateLimitBackend.
+// With WithRetryPolicy, each retry is paced as well.
+func WithRateLimit(limit RateLimit) GovernorOption {
+	return func(g *Governor) {
+		g.rateLimit = &limit
+	}
+}
+
+// WithCircuitBreaker stops calling the backend while it is failing, so
+// calls fail fast with ErrCircuitOpen. See CircuitBreakerBackend. With
+// WithRetryPolicy, every attempt counts towards the breaker and an open
+// circuit is not retried.
+func WithCircuitBreaker(policy CircuitBreakerPolicy) GovernorOption {
+	return func(g *Governor) {
+		g.circuitPolicy = &policy
+	}
+}
+
+// WithBudgetLedger enforces budgets locally on any backend with a
+// BudgetLedger configured by opts. This is mainly useful with the Anthropic
+// and mock backends, which have no budgets of their own.
+func WithBudgetLedger(opts ...LedgerOption) GovernorOption {
+	return func(g *Governor) {
+		g.ledgerOptions = append(g.ledgerOptions, opts...)
+		g.useLedger = true
+	}
+}
+
+// WithRequestValidation controls whether Invoke, InvokeStream,
+// CountTokens and SubmitBatch check each request with
+// InvokeRequest.Validate before sending it. Validation is enabled by
+// default.
+func WithRequestValidation(enabled bool) GovernorOption {
+	return func(g *Governor) {
+		g.skipValidation = !enabled
+	}
+}
+
 // NewGovernor creates a new Governor client.
 //
 // Backend is selected automatically based on environment:
@@ -66,16 +136,39 @@ func NewGovernor(opts ...GovernorOption) *Governor {
 		opt(g)
 	}
 
+	source := "WithBackend"
 	if g.backend == nil {
 		switch {
 		case g.functionName != "":
-			g.backend = NewLambdaBackend(g.functionName, g.lambdaClient)
+			source = "function name"
+			g.backend = NewLambdaBackend(g.functionName, g.lambdaClient, g.lambdaOptions...)
 		case os.Getenv("ANTHROPIC_API_KEY") != "":
+			source = "ANTHROPIC_API_KEY"
 			g.backend = NewAnthropicBackend()
 		default:
+			source = "no backend configured"
 			g.backend = NewMockBackend()
 		}
 	}
+	g.base = g.backend
+
+	if g.rateLimit != nil {
+		g.backend = NewRateLimitBackend(g.backend, *g.rateLimit)
+	}
+	if g.circuitPolicy != nil {
+		g.backend = NewCircuitBreakerBackend(g.backend, *g.circuitPolicy)
+	}
+	if g.retryPolicy != nil {
+		g.backend = NewRetryBackend(g.backend, g.logRetries(*g.retryPolicy))
+	}
+	if g.useLedger {
+		opts := g.ledgerOptions
+		if g.logger != nil {
+			opts = append([]LedgerOption{WithLedgerLogger(g.logger)}, opts...)
+		}
+		g.backend = NewBudgetLedger(g.backend, opts...)
+	}
+	g.initLogging(source)
 
 	return g
 }
@@ -83,12 +176,21 @@ func NewGovernor(opts ...GovernorOption) *Governor {
 // Available returns true if the governor is configured with a real backend
 // (Lambda or Anthropic). Returns false for the mock backend.
 func (g *Governor) Available() bool {
-	_, isMock := g.backend.(*MockBackend)
+	_, isMock := unwrapBackend(g.backend).(*MockBackend)
 	return !isMock
 }
 
-// Backend returns the active backend instance.
+// Backend returns the backend set by WithBackend or selected by
+// NewGovernor, without the wrappers added by policy options.
 func (g *Governor) Backend() Backend {
+	return g.base
+}
+
+// WrappedBackend returns the backend the Governor calls: Backend wrapped by
+// the rate limiter, circuit breaker, retries and budget ledger configured
+// by WithRateLimit, WithCircuitBreaker, WithRetryPolicy and
+// WithBudgetLedger. It is Backend when none of them is set.
+func (g *Governor) WrappedBackend() Backend {
 	return g.backend
 }
 
@@ -100,7 +202,45 @@ func (g *Governor) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeRespo
 	if req.ExecutionRunID == "" {
 		req.ExecutionRunID = g.executionRunID
 	}
-	return g.backend.Invoke(ctx, req)
+	resp, err := g.intercept(ctx, req, func(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+		if !g.skipValidation {
+			if err := req.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		return g.invokeWithFallback(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errNoResult(req.Action)
+	}
+	if resp.TokenCount != nil || resp.Models != nil {
+		cleared := *resp
+		cleared.TokenCount, cleared.Models = nil, nil
+		resp = &cleared
+	}
+	return resp, nil
+}
+
+// InvokeStream sends messages to a model and streams the response as it is
+// generated. The caller must Close the returned stream.
+func (g *Governor) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	if req.Action == "" {
+		req.Action = "invoke-stream"
+	}
+	if req.ExecutionRunID == "" {
+		req.ExecutionRunID = g.executionRunID
+	}
+	return g.logStream(ctx, req, func() (*Stream, error) {
+		if !g.skipValidation {
+			if err := req.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		return g.invokeStreamWithFallback(ctx, req)
+	})
 }
 
 // Ask is a convenience method for simple text-in, text-out interactions.
@@ -128,6 +268,23 @@ func (g *Governor) AskWithSystem(ctx context.Context, model, system, prompt stri
 	return resp.Text(), nil
 }
 
+// AskStream is like Ask but streams the response.
+func (g *Governor) AskStream(ctx context.Context, model, prompt string) (*Stream, error) {
+	return g.InvokeStream(ctx, &InvokeRequest{
+		Model:    model,
+		Messages: []Message{UserMessage(TextBlock(prompt))},
+	})
+}
+
+// AskWithSystemStream is like AskWithSystem but streams the response.
+func (g *Governor) AskWithSystemStream(ctx context.Context, model, system, prompt string) (*Stream, error) {
+	return g.InvokeStream(ctx, &InvokeRequest{
+		Model:    model,
+		System:   system,
+		Messages: []Message{UserMessage(TextBlock(prompt))},
+	})
+}
+
 // AskAboutFile sends a text prompt along with an EFS file to the model.
 func (g *Governor) AskAboutFile(ctx context.Context, model, prompt, filePath string) (string, error) {
 	resp, err := g.Invoke(ctx, &InvokeRequest{
@@ -143,13 +300,81 @@ func (g *Governor) AskAboutFile(ctx context.Context, model, prompt, filePath str
 }
 
 // CheckBudget returns the current budget status.
+//
+// Interceptors see a request with Action "check-budget" and the budget in
+// the response's BudgetRemaining.
 func (g *Governor) CheckBudget(ctx context.Context) (*CheckBudgetResponse, error) {
-	return g.backend.CheckBudget(ctx, g.executionRunID)
+	req := &InvokeRequest{Action: "check-budget", ExecutionRunID: g.executionRunID}
+	resp, err := g.intercept(ctx, req, func(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+		budget, err := g.backend.CheckBudget(ctx, req.ExecutionRunID)
+		if err != nil {
+			return nil, err
+		}
+		return &InvokeResponse{BudgetRemaining: BudgetInfo(*budget)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errNoResult(req.Action)
+	}
+	budget := CheckBudgetResponse(resp.BudgetRemaining)
+	return &budget, nil
 }
 
 // ListModels returns the available models and their status.
+//
+// Interceptors see a request with Action "list-models" and the models in
+// the response's Models.
 func (g *Governor) ListModels(ctx context.Context) (*ListModelsResponse, error) {
-	return g.backend.ListModels(ctx)
+	req := &InvokeRequest{Action: "list-models"}
+	resp, err := g.intercept(ctx, req, func(ctx context.Context, _ *InvokeRequest) (*InvokeResponse, error) {
+		models, err := g.backend.ListModels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &InvokeResponse{Models: models.Models}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, errNoResult(req.Action)
+	}
+	return &ListModelsResponse{Models: resp.Models}, nil
+}
+
+// CountTokens counts the input tokens of a request without invoking the
+// model, so callers can check that a prompt fits in the model's context
+// window before paying for the call.
+//
+// Interceptors see a copy of req with Action "count-tokens", and the
+// counts in the response's TokenCount.
+func (g *Governor) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	countReq := *req
+	countReq.Action = "count-tokens"
+	if countReq.ExecutionRunID == "" {
+		countReq.ExecutionRunID = g.executionRunID
+	}
+	resp, err := g.intercept(ctx, &countReq, func(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+		if !g.skipValidation {
+			if err := req.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		counts, err := g.backend.CountTokens(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return &InvokeResponse{Model: req.Model, TokenCount: counts}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.TokenCount == nil {
+		return nil, errNoResult(countReq.Action)
+	}
+	return resp.TokenCount, nil
 }
 
 // Text returns the concatenated text content from the response.
@@ -161,4 +386,46 @@ func (r *InvokeResponse) Text() string {
 		}
 	}
 	return text
-}
\ No newline at end of file
+}
+
+// Thinking returns the concatenated extended thinking text from the
+// response. Redacted thinking is not included.
+func (r *InvokeResponse) Thinking() string {
+	var text string
+	for _, c := range r.Content {
+		if c.Type == "thinking" {
+			text += c.Text
+		}
+	}
+	return text
+}
+
+// ToolCalls returns the tool_use blocks from the response, in order.
+func (r *InvokeResponse) ToolCalls() []ResponseContent {
+	var calls []ResponseContent
+	for _, c := range r.Content {
+		if c.Type == "tool_use" {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Message returns the response as an assistant message, for appending to
+// the conversation in a follow-up request.
+func (r *InvokeResponse) Message() Message {
+	blocks := make([]ContentBlock, 0, len(r.Content))
+	for _, c := range r.Content {
+		switch c.Type {
+		case "text":
+			blocks = append(blocks, TextBlock(c.Text))
+		case "tool_use":
+			blocks = append(blocks, ToolUseBlock(c.ID, c.Name, c.Input))
+		case "thinking":
+			blocks = append(blocks, ThinkingBlock(c.Text, c.Signature))
+		case "redacted_thinking":
+			blocks = append(blocks, RedactedThinkingBlock(c.Data))
+		}
+	}
+	return AssistantMessage(blocks...)
+}
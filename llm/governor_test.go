@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -152,4 +154,46 @@ func TestInvokeRequest_Serialization(t *testing.T) {
 	if parsed.Messages[0].Content[0].Text != "Hello" {
 		t.Errorf("expected text 'Hello', got %q", parsed.Messages[0].Content[0].Text)
 	}
-}
\ No newline at end of file
+}
+
+func TestGovernorError_ErrorsIs(t *testing.T) {
+	err := fmt.Errorf("classify row 3: %w", &GovernorError{Code: "bedrock_throttled", RetryAfterSec: 4})
+
+	if !errors.Is(err, ErrThrottled) {
+		t.Error("expected errors.Is to match ErrThrottled")
+	}
+	if errors.Is(err, ErrBudgetExceeded) {
+		t.Error("expected errors.Is not to match ErrBudgetExceeded")
+	}
+
+	var ge *GovernorError
+	if !errors.As(err, &ge) || ge.RetryAfterSec != 4 {
+		t.Errorf("expected errors.As to find the GovernorError, got %v", ge)
+	}
+	if _, ok := IsGovernorError(err); !ok {
+		t.Error("expected IsGovernorError to unwrap wrapped errors")
+	}
+}
+
+func TestGovernorError_Predicates(t *testing.T) {
+	tests := []struct {
+		code  string
+		check func(*GovernorError) bool
+	}{
+		{"model_overloaded", (*GovernorError).IsOverloaded},
+		{"invalid_request", (*GovernorError).IsInvalidRequest},
+		{"authentication_failed", (*GovernorError).IsAuthFailure},
+		{"permission_denied", (*GovernorError).IsAuthFailure},
+		{"not_found", (*GovernorError).IsNotFound},
+		{"request_too_large", (*GovernorError).IsRequestTooLarge},
+	}
+	for _, tt := range tests {
+		if !tt.check(&GovernorError{Code: tt.code}) {
+			t.Errorf("expected predicate to match code %q", tt.code)
+		}
+		if tt.check(&GovernorError{Code: "budget_exceeded"}) {
+			t.Errorf("predicate for %q unexpectedly matched budget_exceeded", tt.code)
+		}
+	}
+}
+
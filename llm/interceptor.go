@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// InvokeHandler performs a call on behalf of an Interceptor.
+type InvokeHandler func(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error)
+
+// Interceptor wraps the calls a Governor makes to its backend. It may
+// inspect or modify the request, call next (or not) and inspect or
+// replace the response or error. See WithInterceptors.
+type Interceptor func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error)
+
+// WithInterceptors adds interceptors to Invoke, CheckBudget, ListModels
+// and CountTokens calls. The first interceptor is the outermost: it sees
+// the request first and the response last.
+//
+// Interceptors see requests after the Action and ExecutionRunID defaults
+// are filled in, and before validation, so a modified request is
+// validated and validation errors pass back through the chain. Invoke and
+// the Ask helpers have Action "invoke". CheckBudget and ListModels calls
+// have Action "check-budget" and "list-models" and carry no messages; a
+// check-budget response holds the budget in BudgetRemaining, and a
+// list-models response holds the models in Models. CountTokens calls have
+// Action "count-tokens" and their response holds the counts in
+// TokenCount. Interceptors may change these results, but must not return
+// a nil response without an error.
+//
+// Streams and batches are not intercepted.
+func WithInterceptors(interceptors ...Interceptor) GovernorOption {
+	return func(g *Governor) {
+		g.interceptors = append(g.interceptors, interceptors...)
+	}
+}
+
+// intercept runs req through the interceptor chain, ending with final.
+func (g *Governor) intercept(ctx context.Context, req *InvokeRequest, final InvokeHandler) (*InvokeResponse, error) {
+	h := final
+	for i := len(g.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := g.interceptors[i], h
+		h = func(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return h(ctx, req)
+}
+
+// errNoResult reports an interceptor chain that returned neither a result
+// nor an error for action.
+func errNoResult(action string) error {
+	return fmt.Errorf("llm: %s call returned no result", action)
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestGovernor_InterceptorOrder(t *testing.T) {
+	var trace []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+			trace = append(trace, name+" before")
+			resp, err := next(ctx, req)
+			trace = append(trace, name+" after")
+			return resp, err
+		}
+	}
+	g := NewGovernor(WithBackend(NewMockBackend()), WithInterceptors(record("outer"), record("inner")))
+
+	if _, err := g.Ask(context.Background(), ModelHaiku45, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"outer before", "inner before", "inner after", "outer after"}
+	if !slices.Equal(trace, want) {
+		t.Errorf("expected %v, got %v", want, trace)
+	}
+}
+
+func TestGovernor_InterceptorSeesDefaults(t *testing.T) {
+	var seen []InvokeRequest
+	spy := func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+		seen = append(seen, *req)
+		return next(ctx, req)
+	}
+	g := NewGovernor(WithBackend(NewMockBackend()), WithExecutionRunID("run-1"), WithInterceptors(spy))
+	ctx := context.Background()
+
+	if _, err := g.Ask(ctx, ModelHaiku45, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	budget, err := g.CheckBudget(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.ExecutionRemainingUsd != 10 {
+		t.Errorf("expected the backend's budget, got %+v", budget)
+	}
+	models, err := g.ListModels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models.Models) == 0 {
+		t.Error("expected the backend's models")
+	}
+
+	var actions []string
+	for _, req := range seen {
+		actions = append(actions, req.Action)
+		if req.Action != "list-models" && req.ExecutionRunID != "run-1" {
+			t.Errorf("%s: expected execution run ID run-1, got %q", req.Action, req.ExecutionRunID)
+		}
+	}
+	if want := []string{"invoke", "check-budget", "list-models"}; !slices.Equal(actions, want) {
+		t.Errorf("expected actions %v, got %v", want, actions)
+	}
+}
+
+func TestGovernor_InterceptorModifiesRequest(t *testing.T) {
+	mock := NewMockBackend()
+	redact := func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+		req.Messages = []Message{UserMessage(TextBlock("[redacted]"))}
+		return next(ctx, req)
+	}
+	g := NewGovernor(WithBackend(mock), WithInterceptors(redact))
+
+	text, err := g.Ask(context.Background(), ModelHaiku45, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "[mock] [redacted]" {
+		t.Errorf("expected the redacted prompt to be sent, got %q", text)
+	}
+}
+
+func TestGovernor_InterceptorShortCircuits(t *testing.T) {
+	mock := NewMockBackend()
+	deny := errors.New("denied")
+	block := func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+		if req.Action == "invoke" {
+			return nil, deny
+		}
+		return next(ctx, req)
+	}
+	g := NewGovernor(WithBackend(mock), WithInterceptors(block))
+
+	if _, err := g.Ask(context.Background(), ModelHaiku45, "hi"); !errors.Is(err, deny) {
+		t.Fatalf("expected the interceptor's error, got %v", err)
+	}
+	if len(mock.Calls()) != 0 {
+		t.Errorf("expected no backend calls, got %d", len(mock.Calls()))
+	}
+}
+
+func TestGovernor_InterceptorSeesValidationErrors(t *testing.T) {
+	var got error
+	spy := func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+		resp, err := next(ctx, req)
+		got = err
+		return resp, err
+	}
+	g := NewGovernor(WithBackend(NewMockBackend()), WithInterceptors(spy))
+
+	_, err := g.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest, got %v", err)
+	}
+	if !errors.Is(got, ErrInvalidRequest) {
+		t.Errorf("expected the interceptor to see the validation error, got %v", got)
+	}
+}
+
+func TestGovernor_InterceptorChangesResults(t *testing.T) {
+	filter := func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		switch req.Action {
+		case "list-models":
+			if len(resp.Models) == 0 {
+				t.Error("expected the interceptor to see the backend's models")
+			}
+			resp.Models = slices.DeleteFunc(resp.Models, func(m ModelInfo) bool { return m.ModelID != ModelHaiku45 })
+		case "check-budget":
+			resp.BudgetRemaining.ExecutionRemainingUsd = 1
+		}
+		return resp, nil
+	}
+	g := NewGovernor(WithBackend(NewMockBackend()), WithInterceptors(filter))
+	ctx := context.Background()
+
+	models, err := g.ListModels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models.Models) != 1 || models.Models[0].ModelID != ModelHaiku45 {
+		t.Errorf("expected only the interceptor's model, got %+v", models.Models)
+	}
+	budget, err := g.CheckBudget(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.ExecutionRemainingUsd != 1 {
+		t.Errorf("expected the interceptor's budget, got %+v", budget)
+	}
+}
+
+func TestGovernor_InterceptorNoResult(t *testing.T) {
+	empty := func(context.Context, *InvokeRequest, InvokeHandler) (*InvokeResponse, error) {
+		return nil, nil
+	}
+	g := NewGovernor(WithBackend(NewMockBackend()), WithInterceptors(empty))
+	ctx := context.Background()
+
+	if budget, err := g.CheckBudget(ctx); err == nil {
+		t.Errorf("expected an error for a nil check-budget response, got %+v", budget)
+	}
+	if models, err := g.ListModels(ctx); err == nil {
+		t.Errorf("expected an error for a nil list-models response, got %+v", models)
+	}
+	if text, err := g.Ask(ctx, ModelHaiku45, "hi"); err == nil {
+		t.Errorf("expected an error for a nil invoke response, got %q", text)
+	}
+}
+
+func TestGovernor_InvokeClearsInterceptorFields(t *testing.T) {
+	leak := func(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp.TokenCount = &CountTokensResponse{InputTokens: 1}
+		resp.Models = []ModelInfo{{ModelID: ModelHaiku45}}
+		return resp, nil
+	}
+	g := NewGovernor(WithBackend(NewMockBackend()), WithInterceptors(leak))
+
+	resp, err := g.Invoke(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TokenCount != nil || resp.Models != nil {
+		t.Errorf("expected interceptor-only fields to be cleared, got %+v", resp)
+	}
+}
@@ -0,0 +1,264 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream/eventstreamapi"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// MockLambdaClient is a LambdaInvoker for testing LambdaBackend without AWS.
+//
+// Script outputs with QueueResponse, QueueFunctionError, QueueOutput and
+// QueueError; they are consumed in order, one per Invoke call. Inspect what
+// was sent with Calls and Payloads.
+//
+//	client := llm.NewMockLambdaClient()
+//	client.QueueResponse(&llm.InvokeResponse{...})
+//	gov := llm.NewGovernor(llm.WithFunctionName("gov"), llm.WithLambdaClient(client))
+type MockLambdaClient struct {
+	mu      sync.Mutex
+	script  []mockLambdaStep
+	callLog []*lambda.InvokeInput
+}
+
+type mockLambdaStep struct {
+	output *lambda.InvokeOutput
+	err    error
+
+	// stream holds the events of a streamed invocation when streamed is set.
+	stream   []types.InvokeWithResponseStreamResponseEvent
+	streamed bool
+}
+
+// NewMockLambdaClient creates a mock Lambda client with no scripted outputs.
+func NewMockLambdaClient() *MockLambdaClient {
+	return &MockLambdaClient{}
+}
+
+// QueueOutput appends raw Lambda outputs to the script.
+func (c *MockLambdaClient) QueueOutput(outputs ...*lambda.InvokeOutput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, out := range outputs {
+		c.script = append(c.script, mockLambdaStep{output: out})
+	}
+}
+
+// QueueResponse appends a successful invocation whose payload is v encoded
+// as JSON, e.g. an *InvokeResponse or an ErrorResponse. It panics if v
+// cannot be marshaled.
+func (c *MockLambdaClient) QueueResponse(v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("llm: MockLambdaClient response: %v", err))
+	}
+	c.QueueOutput(&lambda.InvokeOutput{StatusCode: 200, Payload: payload})
+}
+
+// QueueFunctionError appends an invocation in which the function itself
+// failed, as Lambda reports an unhandled exception: FunctionError is set
+// to "Unhandled" and the payload carries errorType and errorMessage.
+func (c *MockLambdaClient) QueueFunctionError(errorType, message string) {
+	payload, _ := json.Marshal(map[string]string{
+		"errorType":    errorType,
+		"errorMessage": message,
+	})
+	c.QueueOutput(&lambda.InvokeOutput{
+		StatusCode:    200,
+		FunctionError: aws.String("Unhandled"),
+		Payload:       payload,
+	})
+}
+
+// QueueError appends an invocation that fails before reaching the
+// function, such as a network or permissions error from the Lambda API.
+func (c *MockLambdaClient) QueueError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.script = append(c.script, mockLambdaStep{err: err})
+}
+
+// Calls returns all InvokeInputs received, for test assertions.
+func (c *MockLambdaClient) Calls() []*lambda.InvokeInput {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]*lambda.InvokeInput, len(c.callLog))
+	copy(out, c.callLog)
+	return out
+}
+
+// Payloads returns the payload of every call received, in order.
+func (c *MockLambdaClient) Payloads() []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]json.RawMessage, len(c.callLog))
+	for i, in := range c.callLog {
+		out[i] = in.Payload
+	}
+	return out
+}
+
+// Invoke records the call and returns the next scripted output. It returns
+// an error if the script is exhausted.
+func (c *MockLambdaClient) Invoke(_ context.Context, params *lambda.InvokeInput, _ ...func(*lambda.Options)) (*lambda.InvokeOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.callLog = append(c.callLog, params)
+	if len(c.script) == 0 {
+		return nil, errors.New("llm: MockLambdaClient has no scripted output")
+	}
+	step := c.script[0]
+	c.script = c.script[1:]
+	if step.streamed {
+		return nil, errors.New("llm: MockLambdaClient scripted output is a stream")
+	}
+	return step.output, step.err
+}
+
+// MockLambdaStreamClient is a MockLambdaClient that also supports Lambda
+// response streaming, so LambdaBackend.InvokeStream calls
+// InvokeWithResponseStream instead of falling back to a buffered Invoke.
+//
+// Script streamed invocations with QueueStream or QueueStreamEvents; they
+// share the script of the embedded MockLambdaClient, so QueueError also
+// scripts a failed stream. Streamed events are encoded and decoded by the
+// AWS SDK as a real response stream would be.
+type MockLambdaStreamClient struct {
+	*MockLambdaClient
+}
+
+// NewMockLambdaStreamClient creates a streaming mock Lambda client with no
+// scripted outputs.
+func NewMockLambdaStreamClient() *MockLambdaStreamClient {
+	return &MockLambdaStreamClient{MockLambdaClient: NewMockLambdaClient()}
+}
+
+// QueueStream appends a streamed invocation that delivers events, usually
+// payload chunks followed by an InvokeComplete event.
+func (c *MockLambdaStreamClient) QueueStream(events ...types.InvokeWithResponseStreamResponseEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.script = append(c.script, mockLambdaStep{stream: events, streamed: true})
+}
+
+// QueueStreamEvents appends a streamed invocation that delivers events as
+// the governor does, one JSON line per payload chunk, followed by a
+// successful InvokeComplete event. It panics if an event cannot be
+// marshaled.
+func (c *MockLambdaStreamClient) QueueStreamEvents(events ...StreamEvent) {
+	var out []types.InvokeWithResponseStreamResponseEvent
+	for _, ev := range events {
+		line, err := json.Marshal(ev)
+		if err != nil {
+			panic(fmt.Sprintf("llm: MockLambdaStreamClient event: %v", err))
+		}
+		out = append(out, &types.InvokeWithResponseStreamResponseEventMemberPayloadChunk{
+			Value: types.InvokeResponseStreamUpdate{Payload: append(line, '\n')},
+		})
+	}
+	out = append(out, &types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{})
+	c.QueueStream(out...)
+}
+
+// InvokeWithResponseStream records the call as an InvokeInput and returns
+// the next scripted stream. It returns an error if the script is exhausted
+// or the next output is not a stream.
+func (c *MockLambdaStreamClient) InvokeWithResponseStream(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, _ ...func(*lambda.Options)) (*lambda.InvokeWithResponseStreamOutput, error) {
+	c.mu.Lock()
+	c.callLog = append(c.callLog, &lambda.InvokeInput{
+		FunctionName: params.FunctionName,
+		Payload:      params.Payload,
+		LogType:      params.LogType,
+	})
+	if len(c.script) == 0 {
+		c.mu.Unlock()
+		return nil, errors.New("llm: MockLambdaStreamClient has no scripted output")
+	}
+	step := c.script[0]
+	c.script = c.script[1:]
+	c.mu.Unlock()
+
+	if step.err != nil {
+		return nil, step.err
+	}
+	if !step.streamed {
+		return nil, errors.New("llm: MockLambdaStreamClient scripted output is not a stream")
+	}
+	var body bytes.Buffer
+	if err := encodeLambdaStream(&body, step.stream); err != nil {
+		return nil, err
+	}
+	return newLambdaStreamOutput(ctx, params, io.NopCloser(&body))
+}
+
+// encodeLambdaStream writes events in the event stream encoding Lambda
+// uses for response streams.
+func encodeLambdaStream(w io.Writer, events []types.InvokeWithResponseStreamResponseEvent) error {
+	enc := eventstream.NewEncoder()
+	for _, event := range events {
+		msg := eventstream.Message{Headers: eventstream.Headers{
+			{Name: eventstreamapi.MessageTypeHeader, Value: eventstream.StringValue(eventstreamapi.EventMessageType)},
+		}}
+		switch e := event.(type) {
+		case *types.InvokeWithResponseStreamResponseEventMemberPayloadChunk:
+			msg.Headers.Set(eventstreamapi.EventTypeHeader, eventstream.StringValue("PayloadChunk"))
+			msg.Headers.Set(eventstreamapi.ContentTypeHeader, eventstream.StringValue("application/octet-stream"))
+			msg.Payload = e.Value.Payload
+		case *types.InvokeWithResponseStreamResponseEventMemberInvokeComplete:
+			payload, err := json.Marshal(e.Value)
+			if err != nil {
+				return err
+			}
+			msg.Headers.Set(eventstreamapi.EventTypeHeader, eventstream.StringValue("InvokeComplete"))
+			msg.Headers.Set(eventstreamapi.ContentTypeHeader, eventstream.StringValue("application/json"))
+			msg.Payload = payload
+		default:
+			return fmt.Errorf("llm: MockLambdaStreamClient cannot encode %T", event)
+		}
+		if err := enc.Encode(w, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newLambdaStreamOutput returns the output of a streamed invocation whose
+// HTTP response body is body, decoded by the AWS SDK's Lambda client.
+func newLambdaStreamOutput(ctx context.Context, params *lambda.InvokeWithResponseStreamInput, body io.ReadCloser) (*lambda.InvokeWithResponseStreamOutput, error) {
+	client := lambda.New(lambda.Options{
+		Region:      "us-east-1",
+		Credentials: aws.AnonymousCredentials{},
+		Retryer:     aws.NopRetryer{},
+		HTTPClient: mockLambdaHTTPClient(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header: http.Header{
+					"Content-Type":     []string{"application/vnd.amazon.eventstream"},
+					"X-Amzn-Requestid": []string{"mock-request-id"},
+				},
+				Body:    body,
+				Request: req,
+			}, nil
+		}),
+	})
+	return client.InvokeWithResponseStream(ctx, params)
+}
+
+// mockLambdaHTTPClient answers Lambda API requests without a network.
+type mockLambdaHTTPClient func(*http.Request) (*http.Response, error)
+
+func (f mockLambdaHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,590 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BudgetLedger wraps a Backend and enforces spending limits locally, the
+// way the governor does on the platform. It tracks the estimated cost of
+// every response per budget period and per execution run, rejects calls
+// with a budget_exceeded GovernorError once a limit is reached, and fills
+// in InvokeResponse.BudgetRemaining.
+//
+// A run's budget is InvokeRequest.ExecutionBudgetUsd, or the ledger's
+// default execution budget when the request does not set one. With
+// WithLedgerFile the ledger state is kept in a JSON file, so several
+// processes can share one budget.
+//
+//	ledger := llm.NewBudgetLedger(llm.NewAnthropicBackend(),
+//		llm.WithLedgerPeriodBudget("daily", 5),
+//		llm.WithLedgerFile(".llm-budget.json"))
+//	gov := llm.NewGovernor(llm.WithBackend(ledger))
+type BudgetLedger struct {
+	backend                   Backend
+	period                    string
+	periodBudgetUsd           float64
+	defaultExecutionBudgetUsd float64
+	path                      string
+	executionTTL              time.Duration
+	logger                    *slog.Logger
+	now                       func() time.Time
+
+	mu    sync.Mutex
+	state ledgerState
+
+	// batchRuns maps the custom IDs of batches submitted through this
+	// ledger to their execution runs and reservations, so results are
+	// billed to them.
+	batchMu   sync.Mutex
+	batchRuns map[string]map[string]batchReservation
+}
+
+// ledgerState is the persisted state of a BudgetLedger. Pending amounts
+// are reserved for calls in flight and settled when their cost is known.
+type ledgerState struct {
+	PeriodStart      time.Time                  `json:"periodStart"`
+	PeriodUsedUsd    float64                    `json:"periodUsedUsd"`
+	PeriodPendingUsd float64                    `json:"periodPendingUsd,omitempty"`
+	Executions       map[string]*executionSpend `json:"executions,omitempty"`
+}
+
+type executionSpend struct {
+	BudgetUsd  float64   `json:"budgetUsd,omitempty"`
+	UsedUsd    float64   `json:"usedUsd"`
+	PendingUsd float64   `json:"pendingUsd,omitempty"`
+	LastUsed   time.Time `json:"lastUsed"`
+}
+
+// defaultLedgerExecutionTTL is how long a BudgetLedger remembers an
+// execution run after its last call.
+const defaultLedgerExecutionTTL = 7 * 24 * time.Hour
+
+// batchReservation is the run and reserved cost of a request in a batch
+// submitted through a BudgetLedger.
+type batchReservation struct {
+	executionRunID string
+	reservedUsd    float64
+}
+
+// LedgerOption configures a BudgetLedger.
+type LedgerOption func(*BudgetLedger)
+
+// WithLedgerPeriodBudget limits spend per period: "daily" (reset at UTC
+// midnight), "monthly" (reset on the first of the month, UTC) or "total"
+// (never reset). A budget of zero means no period limit. The default is an
+// unlimited "daily" period.
+func WithLedgerPeriodBudget(period string, usd float64) LedgerOption {
+	return func(l *BudgetLedger) {
+		l.period = period
+		l.periodBudgetUsd = usd
+	}
+}
+
+// WithLedgerExecutionBudget sets the budget of execution runs whose
+// requests do not set ExecutionBudgetUsd. Zero (the default) means such
+// runs are only limited by the period budget.
+func WithLedgerExecutionBudget(usd float64) LedgerOption {
+	return func(l *BudgetLedger) {
+		l.defaultExecutionBudgetUsd = usd
+	}
+}
+
+// WithLedgerFile persists the ledger to a JSON file at path. The file is
+// read before and written after every call under a lock file, so
+// processes sharing the path share the budget.
+func WithLedgerFile(path string) LedgerOption {
+	return func(l *BudgetLedger) {
+		l.path = path
+	}
+}
+
+// WithLedgerExecutionTTL sets how long the spend of an execution run is
+// kept after its last call. Runs idle for longer are forgotten, so a run
+// that resumes afterwards starts with its full budget again. The default
+// is 7 days; zero keeps runs forever.
+func WithLedgerExecutionTTL(ttl time.Duration) LedgerOption {
+	return func(l *BudgetLedger) {
+		l.executionTTL = ttl
+	}
+}
+
+// WithLedgerLogger sets the logger that reports responses whose cost
+// could not be recorded, such as when the ledger file cannot be written.
+// The default is slog.Default. A Governor created with WithLogger and
+// WithBudgetLedger passes its logger to the ledger.
+func WithLedgerLogger(logger *slog.Logger) LedgerOption {
+	return func(l *BudgetLedger) {
+		l.logger = logger
+	}
+}
+
+// NewBudgetLedger wraps backend with local budget enforcement.
+func NewBudgetLedger(backend Backend, opts ...LedgerOption) *BudgetLedger {
+	l := &BudgetLedger{
+		backend:      backend,
+		period:       "daily",
+		executionTTL: defaultLedgerExecutionTTL,
+		logger:       slog.Default(),
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Unwrap returns the wrapped backend.
+func (l *BudgetLedger) Unwrap() Backend {
+	return l.backend
+}
+
+// Invoke checks the budget, invokes the backend and records the cost of
+// the response. A response whose cost cannot be recorded is still
+// returned, since it has been paid for; the failure is logged.
+func (l *BudgetLedger) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	reserved, err := l.reserve(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.backend.Invoke(ctx, req)
+	if err != nil {
+		l.recordResponse(ctx, req.ExecutionRunID, reserved, 0)
+		return nil, err
+	}
+	out := *resp
+	out.BudgetRemaining = l.recordResponse(ctx, req.ExecutionRunID, reserved, resp.Usage.EstimatedCostUsd)
+	return &out, nil
+}
+
+// InvokeStream checks the budget before opening the stream and records
+// the cost of the response when the stream ends, fails or is closed. The
+// cost of an unfinished response is estimated from the output received.
+// As with Invoke, a failure to record the cost is logged.
+func (l *BudgetLedger) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	reserved, err := l.reserve(req)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := l.backend.InvokeStream(ctx, req)
+	if err != nil {
+		l.recordResponse(ctx, req.ExecutionRunID, reserved, 0)
+		return nil, err
+	}
+	return newStream(ctx, func(_ context.Context, emit func(StreamEvent) error) error {
+		defer inner.Close()
+		defer func() { l.recordResponse(ctx, req.ExecutionRunID, reserved, streamCostUsd(req, inner.Response())) }()
+		for inner.Next() {
+			if err := emit(inner.Event()); err != nil {
+				return err
+			}
+		}
+		return inner.Err()
+	}), nil
+}
+
+// CheckBudget reports the ledger's budget state for the run.
+func (l *BudgetLedger) CheckBudget(_ context.Context, executionRunID string) (*CheckBudgetResponse, error) {
+	var info BudgetInfo
+	err := l.update(func(s *ledgerState) error {
+		info = l.budgetInfo(s, executionRunID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := CheckBudgetResponse(info)
+	return &resp, nil
+}
+
+func (l *BudgetLedger) ListModels(ctx context.Context) (*ListModelsResponse, error) {
+	return l.backend.ListModels(ctx)
+}
+
+func (l *BudgetLedger) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	return l.backend.CountTokens(ctx, req)
+}
+
+// SubmitBatch checks the budget of every request's run and reserves its
+// estimated cost before submitting the batch. Spend is recorded as results
+// are read with BatchResults.
+func (l *BudgetLedger) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	bb, err := batchBackend(l.backend)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireBatchRequests(requests); err != nil {
+		return nil, err
+	}
+	runs := make(map[string]batchReservation, len(requests))
+	release := func() {
+		for _, r := range runs {
+			l.recordResponse(ctx, r.executionRunID, r.reservedUsd, 0)
+		}
+	}
+	for _, br := range requests {
+		reserved, err := l.reserve(br.Request)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		runs[br.CustomID] = batchReservation{executionRunID: br.Request.ExecutionRunID, reservedUsd: reserved}
+	}
+	batch, err := bb.SubmitBatch(ctx, requests)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	l.batchMu.Lock()
+	if l.batchRuns == nil {
+		l.batchRuns = map[string]map[string]batchReservation{}
+	}
+	l.batchRuns[batch.ID] = runs
+	l.batchMu.Unlock()
+	return batch, nil
+}
+
+func (l *BudgetLedger) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	bb, err := batchBackend(l.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.BatchStatus(ctx, batchID)
+}
+
+// BatchResults records the cost of each response as it is read and fills
+// in its BudgetRemaining. Results of batches submitted by another process
+// are billed to the period only. Once every result has been read, the
+// reservations of requests without a response are released and the
+// batch is forgotten.
+func (l *BudgetLedger) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	bb, err := batchBackend(l.backend)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := bb.BatchResults(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	return newBatchResults(ctx, func(_ context.Context, emit func(BatchResult) error) error {
+		defer inner.Close()
+		for inner.Next() {
+			res := inner.cur
+			r := l.takeReservation(batchID, res.CustomID)
+			if res.Response != nil {
+				resp := *res.Response
+				resp.BudgetRemaining = l.recordResponse(ctx, r.executionRunID, r.reservedUsd, res.Response.Usage.EstimatedCostUsd)
+				res.Response = &resp
+			} else if r.reservedUsd > 0 {
+				l.recordResponse(ctx, r.executionRunID, r.reservedUsd, 0)
+			}
+			if err := emit(res); err != nil {
+				return err
+			}
+		}
+		if err := inner.Err(); err != nil {
+			return err
+		}
+		l.batchMu.Lock()
+		runs := l.batchRuns[batchID]
+		delete(l.batchRuns, batchID)
+		l.batchMu.Unlock()
+		for _, r := range runs {
+			l.recordResponse(ctx, r.executionRunID, r.reservedUsd, 0)
+		}
+		return nil
+	}), nil
+}
+
+// takeReservation removes and returns the reservation of a request in a
+// batch submitted through l. Requests of other batches have none.
+func (l *BudgetLedger) takeReservation(batchID, customID string) batchReservation {
+	l.batchMu.Lock()
+	defer l.batchMu.Unlock()
+	r := l.batchRuns[batchID][customID]
+	delete(l.batchRuns[batchID], customID)
+	return r
+}
+
+// reserve records the run's budget and fails if the period or run budget
+// is spent or has less left, after the reservations of calls in flight,
+// than the request's estimated worst-case cost (see
+// Governor.EstimateCost). Otherwise it reserves that cost, which record
+// settles, and returns it.
+func (l *BudgetLedger) reserve(req *InvokeRequest) (float64, error) {
+	var cost float64
+	if est, ok := estimateRequestCost(req); ok {
+		cost = est.MaxCostUsd
+	}
+	err := l.update(func(s *ledgerState) error {
+		if req.ExecutionRunID != "" {
+			e := l.execution(s, req.ExecutionRunID)
+			if req.ExecutionBudgetUsd > 0 {
+				e.BudgetUsd = req.ExecutionBudgetUsd
+			}
+		}
+		info := l.budgetInfo(s, req.ExecutionRunID)
+		periodLeft := info.PeriodRemainingUsd - s.PeriodPendingUsd
+		executionLeft := info.ExecutionRemainingUsd
+		if e, ok := s.Executions[req.ExecutionRunID]; ok {
+			executionLeft -= e.PendingUsd
+		}
+		switch {
+		case l.periodBudgetUsd > 0 && info.PeriodRemainingUsd <= 0:
+			return &GovernorError{
+				Code:            "budget_exceeded",
+				Msg:             fmt.Sprintf("%s budget of $%.2f exhausted", l.period, l.periodBudgetUsd),
+				BudgetRemaining: &info,
+			}
+		case l.periodBudgetUsd > 0 && periodLeft < cost:
+			return &GovernorError{
+				Code:            "budget_exceeded",
+				Msg:             fmt.Sprintf("estimated cost $%.4f exceeds the $%.4f left of the %s budget", cost, max(periodLeft, 0), l.period),
+				BudgetRemaining: &info,
+			}
+		case info.ExecutionBudgetUsd > 0 && info.ExecutionRemainingUsd <= 0:
+			return &GovernorError{
+				Code:            "budget_exceeded",
+				Msg:             fmt.Sprintf("execution budget of $%.2f exhausted for run %s", info.ExecutionBudgetUsd, req.ExecutionRunID),
+				BudgetRemaining: &info,
+			}
+		case info.ExecutionBudgetUsd > 0 && executionLeft < cost:
+			return &GovernorError{
+				Code:            "budget_exceeded",
+				Msg:             fmt.Sprintf("estimated cost $%.4f exceeds the $%.4f left of the execution budget for run %s", cost, max(executionLeft, 0), req.ExecutionRunID),
+				BudgetRemaining: &info,
+			}
+		}
+		s.PeriodPendingUsd += cost
+		if req.ExecutionRunID != "" {
+			l.execution(s, req.ExecutionRunID).PendingUsd += cost
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return cost, nil
+}
+
+// record releases the amount reserved for a call, adds its cost to the
+// period and run totals and returns the budget remaining afterwards.
+func (l *BudgetLedger) record(executionRunID string, reserved, cost float64) (BudgetInfo, error) {
+	var info BudgetInfo
+	err := l.update(func(s *ledgerState) error {
+		s.PeriodPendingUsd = max(s.PeriodPendingUsd-reserved, 0)
+		s.PeriodUsedUsd += cost
+		if executionRunID != "" {
+			e := l.execution(s, executionRunID)
+			e.PendingUsd = max(e.PendingUsd-reserved, 0)
+			e.UsedUsd += cost
+		}
+		info = l.budgetInfo(s, executionRunID)
+		return nil
+	})
+	return info, err
+}
+
+// recordResponse settles the reservation of a call that has already been
+// paid for, if it was sent at all. If that fails, the failure is logged
+// rather than returned, so that the response is not lost, and the budget
+// is reported from the state last seen.
+func (l *BudgetLedger) recordResponse(ctx context.Context, executionRunID string, reserved, cost float64) BudgetInfo {
+	info, err := l.record(executionRunID, reserved, cost)
+	if err != nil {
+		l.logger.LogAttrs(ctx, slog.LevelError, "llm budget ledger failed to record cost",
+			slog.String("executionRunId", executionRunID),
+			slog.Float64("costUsd", cost),
+			slog.String("error", err.Error()),
+		)
+		l.mu.Lock()
+		info = l.budgetInfo(&l.state, executionRunID)
+		l.mu.Unlock()
+	}
+	return info
+}
+
+func (l *BudgetLedger) execution(s *ledgerState, executionRunID string) *executionSpend {
+	if s.Executions == nil {
+		s.Executions = map[string]*executionSpend{}
+	}
+	e, ok := s.Executions[executionRunID]
+	if !ok {
+		e = &executionSpend{}
+		s.Executions[executionRunID] = e
+	}
+	e.LastUsed = l.now().UTC()
+	return e
+}
+
+// budgetInfo reports the budget state for a run. A period without a limit
+// has infinite remaining budget.
+func (l *BudgetLedger) budgetInfo(s *ledgerState, executionRunID string) BudgetInfo {
+	info := BudgetInfo{
+		BudgetPeriod:       l.period,
+		PeriodBudgetUsd:    l.periodBudgetUsd,
+		PeriodUsedUsd:      s.PeriodUsedUsd,
+		PeriodRemainingUsd: math.Inf(1),
+	}
+	if l.periodBudgetUsd > 0 {
+		info.PeriodRemainingUsd = math.Max(l.periodBudgetUsd-s.PeriodUsedUsd, 0)
+	}
+
+	budget := l.defaultExecutionBudgetUsd
+	var used float64
+	if e, ok := s.Executions[executionRunID]; ok {
+		used = e.UsedUsd
+		if e.BudgetUsd > 0 {
+			budget = e.BudgetUsd
+		}
+	}
+	if budget > 0 {
+		info.ExecutionBudgetUsd = budget
+		info.ExecutionUsedUsd = used
+		info.ExecutionRemainingUsd = math.Max(budget-used, 0)
+	}
+	return info
+}
+
+// update applies fn to the current state, starting a new period first if
+// the current one has ended and forgetting runs idle for longer than the
+// execution TTL. With a ledger file, the state is loaded
+// before fn and saved after it while holding the file lock.
+func (l *BudgetLedger) update(fn func(s *ledgerState) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.path != "" {
+		unlock, err := lockFile(l.path + ".lock")
+		if err != nil {
+			return fmt.Errorf("llm: budget ledger: %w", err)
+		}
+		defer unlock()
+		if err := l.load(); err != nil {
+			return fmt.Errorf("llm: budget ledger: %w", err)
+		}
+	}
+
+	if start := l.periodStart(); !start.Equal(l.state.PeriodStart) {
+		l.state = ledgerState{PeriodStart: start, Executions: l.state.Executions}
+	}
+	l.expireExecutions(&l.state)
+	fnErr := fn(&l.state)
+
+	if l.path != "" {
+		if err := l.save(); err != nil {
+			return fmt.Errorf("llm: budget ledger: %w", err)
+		}
+	}
+	return fnErr
+}
+
+// expireExecutions removes runs whose last call is older than the
+// execution TTL. Runs recorded before LastUsed was kept are treated as
+// used now.
+func (l *BudgetLedger) expireExecutions(s *ledgerState) {
+	if l.executionTTL <= 0 {
+		return
+	}
+	now := l.now().UTC()
+	for id, e := range s.Executions {
+		switch {
+		case e.LastUsed.IsZero():
+			e.LastUsed = now
+		case now.Sub(e.LastUsed) > l.executionTTL:
+			delete(s.Executions, id)
+		}
+	}
+}
+
+// periodStart returns the start of the current budget period.
+func (l *BudgetLedger) periodStart() time.Time {
+	now := l.now().UTC()
+	switch l.period {
+	case "daily":
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	case "monthly":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Time{}
+	}
+}
+
+func (l *BudgetLedger) load() error {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		l.state = ledgerState{}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state ledgerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("corrupt ledger file %s: %w", l.path, err)
+	}
+	l.state = state
+	return nil
+}
+
+// save writes the state to a temporary file and renames it over the
+// ledger file, so readers never see a partial write.
+func (l *BudgetLedger) save() error {
+	data, err := json.MarshalIndent(l.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+// Lock file timing: how long to wait for a lock, and when a lock left
+// behind by a crashed process is considered stale.
+const (
+	lockTimeout = 10 * time.Second
+	lockStale   = time.Minute
+)
+
+// lockFile acquires an exclusive lock by creating path, waiting while
+// another process holds it. It returns a function that releases the lock.
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,358 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func costingMock(cost float64) *MockBackend {
+	mock := NewMockBackend()
+	mock.SetResponse(&InvokeResponse{
+		Content:    []ResponseContent{{Type: "text", Text: "ok"}},
+		StopReason: "end_turn",
+		Usage:      UsageInfo{InputTokens: 10, OutputTokens: 2, EstimatedCostUsd: cost},
+	})
+	return mock
+}
+
+func ledgerRequest(runID string) *InvokeRequest {
+	return &InvokeRequest{
+		Model:          ModelHaiku45,
+		ExecutionRunID: runID,
+		Messages:       []Message{UserMessage(TextBlock("hi"))},
+	}
+}
+
+func TestBudgetLedger_PeriodBudget(t *testing.T) {
+	mock := costingMock(0.4)
+	ledger := NewBudgetLedger(mock, WithLedgerPeriodBudget("daily", 1))
+	ctx := context.Background()
+
+	resp, err := ledger.Invoke(ctx, ledgerRequest("run-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.BudgetRemaining.PeriodUsedUsd != 0.4 || math.Abs(resp.BudgetRemaining.PeriodRemainingUsd-0.6) > 1e-9 {
+		t.Errorf("unexpected budget remaining: %+v", resp.BudgetRemaining)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+
+	_, err = ledger.Invoke(ctx, ledgerRequest("run-2"))
+	ge, ok := IsGovernorError(err)
+	if !ok || !ge.IsBudgetExceeded() {
+		t.Fatalf("expected budget_exceeded, got %v", err)
+	}
+	if ge.BudgetRemaining == nil || ge.BudgetRemaining.PeriodRemainingUsd != 0 {
+		t.Errorf("expected exhausted budget details, got %+v", ge.BudgetRemaining)
+	}
+	if len(mock.Calls()) != 3 {
+		t.Errorf("expected the rejected call not to reach the backend, got %d calls", len(mock.Calls()))
+	}
+}
+
+func TestBudgetLedger_ExecutionBudget(t *testing.T) {
+	ledger := NewBudgetLedger(costingMock(0.3), WithLedgerExecutionBudget(0.5))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); !isBudgetExceeded(err) {
+		t.Fatalf("expected run-1 to be out of budget, got %v", err)
+	}
+
+	req := ledgerRequest("run-2")
+	req.ExecutionBudgetUsd = 2
+	for i := 0; i < 3; i++ {
+		if _, err := ledger.Invoke(ctx, req); err != nil {
+			t.Fatalf("expected the request's budget to override the default, call %d: %v", i, err)
+		}
+	}
+
+	budget, err := ledger.CheckBudget(ctx, "run-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.ExecutionBudgetUsd != 2 || math.Abs(budget.ExecutionUsedUsd-0.9) > 1e-9 {
+		t.Errorf("unexpected run-2 budget: %+v", budget)
+	}
+	if !math.IsInf(budget.PeriodRemainingUsd, 1) {
+		t.Errorf("expected unlimited period budget, got %v", budget.PeriodRemainingUsd)
+	}
+}
+
+func isBudgetExceeded(err error) bool {
+	ge, ok := IsGovernorError(err)
+	return ok && ge.IsBudgetExceeded()
+}
+
+func TestBudgetLedger_PeriodRollover(t *testing.T) {
+	now := time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC)
+	ledger := NewBudgetLedger(costingMock(1), WithLedgerPeriodBudget("daily", 1))
+	ledger.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); !isBudgetExceeded(err) {
+		t.Fatalf("expected budget_exceeded, got %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+		t.Errorf("expected a new day to reset the period budget, got %v", err)
+	}
+}
+
+func TestBudgetLedger_ExpiresIdleExecutions(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	ledger := NewBudgetLedger(costingMock(1), WithLedgerPeriodBudget("total", 0),
+		WithLedgerExecutionBudget(1), WithLedgerExecutionTTL(time.Hour))
+	ledger.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for _, run := range []string{"run-1", "run-2"} {
+		if _, err := ledger.Invoke(ctx, ledgerRequest(run)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(45 * time.Minute)
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); !isBudgetExceeded(err) {
+		t.Fatalf("expected run-1 to be remembered within the TTL, got %v", err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if _, err := ledger.CheckBudget(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ledger.state.Executions["run-2"]; ok {
+		t.Error("expected run-2 to be forgotten after the TTL")
+	}
+	if _, ok := ledger.state.Executions["run-1"]; !ok {
+		t.Error("expected run-1 to be kept, it was used 30 minutes ago")
+	}
+}
+
+func TestBudgetLedger_FilePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	ctx := context.Background()
+
+	first := NewBudgetLedger(costingMock(0.25), WithLedgerPeriodBudget("total", 1), WithLedgerFile(path))
+	if _, err := first.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+		t.Fatal(err)
+	}
+
+	second := NewBudgetLedger(costingMock(0.25), WithLedgerPeriodBudget("total", 1), WithLedgerFile(path))
+	resp, err := second.Invoke(ctx, ledgerRequest("run-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.BudgetRemaining.PeriodUsedUsd != 0.5 || resp.BudgetRemaining.ExecutionUsedUsd != 0 {
+		t.Errorf("expected spend shared through the file, got %+v", resp.BudgetRemaining)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state ledgerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatalf("ledger file is not valid JSON: %v", err)
+	}
+	if state.Executions["run-1"].UsedUsd != 0.5 {
+		t.Errorf("unexpected persisted state: %s", data)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Error("expected the lock file to be released")
+	}
+}
+
+func TestBudgetLedger_ConcurrentFileAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	ledgers := []*BudgetLedger{
+		NewBudgetLedger(costingMock(0.01), WithLedgerFile(path)),
+		NewBudgetLedger(costingMock(0.01), WithLedgerFile(path)),
+	}
+
+	var wg sync.WaitGroup
+	for _, l := range ledgers {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(l *BudgetLedger) {
+				defer wg.Done()
+				if _, err := l.Invoke(context.Background(), ledgerRequest("run-1")); err != nil {
+					t.Error(err)
+				}
+			}(l)
+		}
+	}
+	wg.Wait()
+
+	budget, err := ledgers[0].CheckBudget(context.Background(), "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(budget.PeriodUsedUsd-0.2) > 1e-9 {
+		t.Errorf("expected $0.20 recorded across both ledgers, got %v", budget.PeriodUsedUsd)
+	}
+}
+
+func TestBudgetLedger_Stream(t *testing.T) {
+	ledger := NewBudgetLedger(costingMock(0.5), WithLedgerPeriodBudget("daily", 0.5))
+	ctx := context.Background()
+
+	s, err := ledger.InvokeStream(ctx, ledgerRequest("run-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectStream(t, s)
+	if _, err := ledger.InvokeStream(ctx, ledgerRequest("run-1")); !isBudgetExceeded(err) {
+		t.Errorf("expected streamed spend to be recorded, got %v", err)
+	}
+}
+
+func TestBudgetLedger_StreamClosedEarly(t *testing.T) {
+	ledger := NewBudgetLedger(costingMock(0.5))
+	ctx := context.Background()
+
+	s, err := ledger.InvokeStream(ctx, ledgerRequest("run-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Next() {
+		t.Fatalf("expected an event, got %v", s.Err())
+	}
+	s.Close()
+
+	budget, err := ledger.CheckBudget(ctx, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.PeriodUsedUsd <= 0 {
+		t.Errorf("expected the closed stream's spend to be recorded, got %+v", budget)
+	}
+}
+
+func TestBudgetLedger_RejectsCostAboveRemaining(t *testing.T) {
+	mock := costingMock(0.45)
+	ledger := NewBudgetLedger(mock, WithLedgerExecutionBudget(0.5))
+	ctx := context.Background()
+
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+		t.Fatal(err)
+	}
+	large := ledgerRequest("run-1")
+	large.MaxTokens = 64000
+	if _, err := ledger.Invoke(ctx, large); !isBudgetExceeded(err) {
+		t.Fatalf("expected a request costing more than the $0.05 left to be rejected, got %v", err)
+	}
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+		t.Errorf("expected a cheaper request to fit the remaining budget, got %v", err)
+	}
+	if len(mock.Calls()) != 2 {
+		t.Errorf("expected the rejected call not to reach the backend, got %d calls", len(mock.Calls()))
+	}
+}
+
+// blockingBackend holds each Invoke until release is closed.
+type blockingBackend struct {
+	*MockBackend
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return b.MockBackend.Invoke(ctx, req)
+}
+
+func TestBudgetLedger_ReservesCallsInFlight(t *testing.T) {
+	est, _ := estimateRequestCost(ledgerRequest("run-1"))
+	backend := &blockingBackend{MockBackend: costingMock(0), started: make(chan struct{}, 3), release: make(chan struct{})}
+	ledger := NewBudgetLedger(backend, WithLedgerPeriodBudget("daily", 2.5*est.MaxCostUsd))
+	ctx := context.Background()
+
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() {
+			_, err := ledger.Invoke(ctx, ledgerRequest("run-1"))
+			errs <- err
+		}()
+	}
+	if err := <-errs; !isBudgetExceeded(err) {
+		t.Fatalf("expected the call beyond the reserved budget to be rejected, got %v", err)
+	}
+	<-backend.started
+	<-backend.started
+	close(backend.release)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Errorf("expected the reserved calls to succeed, got %v", err)
+		}
+	}
+
+	if _, err := ledger.Invoke(ctx, ledgerRequest("run-1")); err != nil {
+		t.Errorf("expected the settled reservations to be available again, got %v", err)
+	}
+}
+
+// sabotagingBackend makes the ledger file unreadable while a call is in
+// flight, so that recording its cost fails.
+type sabotagingBackend struct {
+	*MockBackend
+	path string
+}
+
+func (b *sabotagingBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	os.Remove(b.path)
+	os.Mkdir(b.path, 0o755)
+	return b.MockBackend.Invoke(ctx, req)
+}
+
+func TestBudgetLedger_RecordFailureKeepsResponse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	logger, records := logRecords(t, slog.LevelError)
+	ledger := NewBudgetLedger(&sabotagingBackend{MockBackend: costingMock(0.1), path: path},
+		WithLedgerFile(path), WithLedgerLogger(logger))
+
+	resp, err := ledger.Invoke(context.Background(), ledgerRequest("run-1"))
+	if err != nil {
+		t.Fatalf("expected the paid-for response despite the record failure, got %v", err)
+	}
+	if resp.Text() != "ok" {
+		t.Errorf("unexpected response %q", resp.Text())
+	}
+	if got := messages(records()); len(got) != 1 || got[0] != "ERROR llm budget ledger failed to record cost" {
+		t.Errorf("expected the record failure to be logged, got %v", got)
+	}
+}
+
+func TestGovernor_WithBudgetLedger(t *testing.T) {
+	mock := costingMock(2)
+	g := NewGovernor(WithBackend(mock), WithBudgetLedger(WithLedgerExecutionBudget(1)), WithExecutionRunID("run-1"))
+
+	if _, err := g.Ask(context.Background(), ModelHaiku45, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Ask(context.Background(), ModelHaiku45, "hi"); !isBudgetExceeded(err) {
+		t.Errorf("expected budget_exceeded, got %v", err)
+	}
+	if g.Available() {
+		t.Error("expected the ledger to still report a mock backend")
+	}
+}
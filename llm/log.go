@@ -0,0 +1,293 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LogLevels sets the level of each kind of record a Governor logs.
+type LogLevels struct {
+	// Request is the level of request start and finish records.
+	Request slog.Level
+
+	// Retry is the level of records of retried calls.
+	Retry slog.Level
+
+	// Backend is the level of the record of the backend NewGovernor
+	// selects.
+	Backend slog.Level
+
+	// Error is the level of records of failed requests.
+	Error slog.Level
+}
+
+// DefaultLogLevels logs request start and finish at debug level, backend
+// selection at info, retries at warn and failures at error.
+func DefaultLogLevels() LogLevels {
+	return LogLevels{
+		Request: slog.LevelDebug,
+		Retry:   slog.LevelWarn,
+		Backend: slog.LevelInfo,
+		Error:   slog.LevelError,
+	}
+}
+
+// WithLogger logs structured records of the backend selected by
+// NewGovernor, the start and finish of each Invoke, InvokeStream,
+// CheckBudget, ListModels and CountTokens call, retries, and failures,
+// including the code of every GovernorError. Nothing is logged without a
+// logger.
+//
+// Request records are made after interceptors have run, so they show the
+// request as sent to the backend.
+func WithLogger(logger *slog.Logger) GovernorOption {
+	return func(g *Governor) {
+		g.logger = logger
+	}
+}
+
+// WithLogLevels sets the levels of the records logged by WithLogger,
+// replacing DefaultLogLevels.
+func WithLogLevels(levels LogLevels) GovernorOption {
+	return func(g *Governor) {
+		g.logLevels = &levels
+	}
+}
+
+// WithLogBodies adds prompts and responses to request records, each
+// truncated to maxLen bytes. Base64 image and document data and encrypted
+// thinking are replaced by their size. Bodies can contain sensitive data,
+// so this is off by default.
+func WithLogBodies(maxLen int) GovernorOption {
+	return func(g *Governor) {
+		g.logBodies = maxLen
+	}
+}
+
+// initLogging sets up logging once NewGovernor has selected the backend
+// described by source.
+func (g *Governor) initLogging(source string) {
+	if g.logger == nil {
+		return
+	}
+	if g.logLevels == nil {
+		levels := DefaultLogLevels()
+		g.logLevels = &levels
+	}
+
+	attrs := []slog.Attr{
+		slog.String("backend", fmt.Sprintf("%T", unwrapBackend(g.backend))),
+		slog.String("source", source),
+	}
+	if lb, ok := unwrapBackend(g.backend).(*LambdaBackend); ok {
+		attrs = append(attrs, slog.String("function", lb.functionName))
+	}
+	var wrappers []string
+	for b := g.backend; ; {
+		u, ok := b.(interface{ Unwrap() Backend })
+		if !ok {
+			break
+		}
+		wrappers = append(wrappers, fmt.Sprintf("%T", b))
+		b = u.Unwrap()
+	}
+	if len(wrappers) > 0 {
+		attrs = append(attrs, slog.Any("wrappers", wrappers))
+	}
+	g.logger.LogAttrs(context.Background(), g.logLevels.Backend, "llm backend selected", attrs...)
+
+	// Log innermost, after any interceptors have changed the request.
+	g.interceptors = append(g.interceptors, g.logRequest)
+}
+
+// logRetries adds retry logging to policy's OnRetry callback.
+func (g *Governor) logRetries(policy RetryPolicy) RetryPolicy {
+	if g.logger == nil {
+		return policy
+	}
+	onRetry := policy.OnRetry
+	policy.OnRetry = func(attempt int, delay time.Duration, err error) {
+		attrs := append([]slog.Attr{
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+		}, errorAttrs(err)...)
+		g.logger.LogAttrs(context.Background(), g.logLevels.Retry, "llm call retrying", attrs...)
+		if onRetry != nil {
+			onRetry(attempt, delay, err)
+		}
+	}
+	return policy
+}
+
+// logRequest is the Interceptor that logs requests when a logger is set.
+func (g *Governor) logRequest(ctx context.Context, req *InvokeRequest, next InvokeHandler) (*InvokeResponse, error) {
+	start := g.logStart(ctx, req)
+	resp, err := next(ctx, req)
+	g.logFinish(ctx, req, start, resp, err)
+	return resp, err
+}
+
+// logStream logs the start of the stream opened by open and, when it ends
+// or is closed, its finish.
+func (g *Governor) logStream(ctx context.Context, req *InvokeRequest, open func() (*Stream, error)) (*Stream, error) {
+	if g.logger == nil {
+		return open()
+	}
+	start := g.logStart(ctx, req)
+	inner, err := open()
+	if err != nil {
+		g.logFinish(ctx, req, start, nil, err)
+		return nil, err
+	}
+	return newStream(ctx, func(_ context.Context, emit func(StreamEvent) error) error {
+		defer inner.Close()
+		for inner.Next() {
+			if err := emit(inner.Event()); err != nil {
+				g.logFinish(ctx, req, start, inner.Response(), nil, slog.Bool("closedEarly", true))
+				return err
+			}
+		}
+		if err := inner.Err(); err != nil {
+			g.logFinish(ctx, req, start, nil, err)
+			return err
+		}
+		g.logFinish(ctx, req, start, inner.Response(), nil)
+		return nil
+	}), nil
+}
+
+func (g *Governor) logStart(ctx context.Context, req *InvokeRequest) time.Time {
+	if g.logger.Enabled(ctx, g.logLevels.Request) {
+		attrs := requestAttrs(req)
+		if g.logBodies > 0 && len(req.Messages) > 0 {
+			if req.System != "" {
+				attrs = append(attrs, slog.String("system", truncate(req.System, g.logBodies)))
+			}
+			attrs = append(attrs, slog.String("messages", truncate(marshalElided(elideMessages(req.Messages)), g.logBodies)))
+		}
+		g.logger.LogAttrs(ctx, g.logLevels.Request, "llm request started", attrs...)
+	}
+	return time.Now()
+}
+
+func (g *Governor) logFinish(ctx context.Context, req *InvokeRequest, start time.Time, resp *InvokeResponse, err error, extra ...slog.Attr) {
+	attrs := append(requestAttrs(req), slog.Duration("duration", time.Since(start)))
+	attrs = append(attrs, extra...)
+	if err != nil {
+		attrs = append(attrs, errorAttrs(err)...)
+		g.logger.LogAttrs(ctx, g.logLevels.Error, "llm request failed", attrs...)
+		return
+	}
+	if !g.logger.Enabled(ctx, g.logLevels.Request) {
+		return
+	}
+	switch {
+	case resp == nil || req.Action == "check-budget" || req.Action == "list-models":
+	case resp.TokenCount != nil:
+		attrs = append(attrs, slog.Int64("inputTokens", resp.TokenCount.InputTokens))
+	default:
+		attrs = append(attrs,
+			slog.String("responseModel", resp.Model),
+			slog.String("stopReason", resp.StopReason),
+			slog.Int64("inputTokens", resp.Usage.InputTokens),
+			slog.Int64("outputTokens", resp.Usage.OutputTokens),
+			slog.Float64("estimatedCostUsd", resp.Usage.EstimatedCostUsd),
+		)
+		if g.logBodies > 0 {
+			attrs = append(attrs, slog.String("content", truncate(marshalElided(elideContent(resp.Content)), g.logBodies)))
+		}
+	}
+	g.logger.LogAttrs(ctx, g.logLevels.Request, "llm request finished", attrs...)
+}
+
+func requestAttrs(req *InvokeRequest) []slog.Attr {
+	attrs := []slog.Attr{slog.String("action", req.Action)}
+	if req.Model != "" {
+		attrs = append(attrs, slog.String("model", req.Model))
+	}
+	if req.ExecutionRunID != "" {
+		attrs = append(attrs, slog.String("executionRunId", req.ExecutionRunID))
+	}
+	return attrs
+}
+
+// errorAttrs describes err, with the code and details of a GovernorError.
+func errorAttrs(err error) []slog.Attr {
+	attrs := []slog.Attr{slog.String("error", err.Error())}
+	if ge, ok := IsGovernorError(err); ok {
+		attrs = append(attrs, slog.String("code", ge.Code))
+		if ge.RetryAfterSec > 0 {
+			attrs = append(attrs, slog.Int("retryAfterSec", ge.RetryAfterSec))
+		}
+	}
+	return attrs
+}
+
+// elideMessages returns a copy of messages with Data fields replaced by
+// their size.
+func elideMessages(messages []Message) []Message {
+	out := make([]Message, len(messages))
+	for i, m := range messages {
+		out[i] = Message{Role: m.Role, Content: elideBlocks(m.Content)}
+	}
+	return out
+}
+
+func elideBlocks(blocks []ContentBlock) []ContentBlock {
+	if blocks == nil {
+		return nil
+	}
+	out := make([]ContentBlock, len(blocks))
+	for i, b := range blocks {
+		if b.Data != "" {
+			b.Data = elided(b.Data)
+		}
+		b.Content = elideBlocks(b.Content)
+		out[i] = b
+	}
+	return out
+}
+
+func elideContent(content []ResponseContent) []ResponseContent {
+	out := make([]ResponseContent, len(content))
+	for i, c := range content {
+		if c.Data != "" {
+			c.Data = elided(c.Data)
+		}
+		out[i] = c
+	}
+	return out
+}
+
+func elided(data string) string {
+	return fmt.Sprintf("<%d bytes elided>", len(data))
+}
+
+// marshalElided encodes v as JSON without escaping the elision markers.
+func marshalElided(v any) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// truncate shortens s to at most maxLen bytes, on a rune boundary, noting
+// how much was cut.
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	n := maxLen
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s…(%d more bytes)", s[:n], len(s)-n)
+}
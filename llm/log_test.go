@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logRecords returns a logger writing JSON records at or above level, and
+// a function returning the records written so far.
+func logRecords(t *testing.T, level slog.Level) (*slog.Logger, func() []map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+	return logger, func() []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var r map[string]any
+			if err := json.Unmarshal([]byte(line), &r); err != nil {
+				t.Fatal(err)
+			}
+			records = append(records, r)
+		}
+		return records
+	}
+}
+
+func messages(records []map[string]any) []string {
+	var msgs []string
+	for _, r := range records {
+		msgs = append(msgs, r["level"].(string)+" "+r["msg"].(string))
+	}
+	return msgs
+}
+
+func TestGovernor_LoggerRecordsRequests(t *testing.T) {
+	logger, records := logRecords(t, slog.LevelDebug)
+	g := NewGovernor(WithBackend(NewMockBackend()), WithExecutionRunID("run-1"), WithLogger(logger))
+
+	if _, err := g.Ask(context.Background(), ModelHaiku45, "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := records()
+	want := []string{"INFO llm backend selected", "DEBUG llm request started", "DEBUG llm request finished"}
+	if got := messages(recs); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected records %v, got %v", want, got)
+	}
+	if recs[0]["backend"] != "*llm.MockBackend" || recs[0]["source"] != "WithBackend" {
+		t.Errorf("unexpected backend record %v", recs[0])
+	}
+	finished := recs[2]
+	if finished["action"] != "invoke" || finished["model"] != ModelHaiku45 || finished["executionRunId"] != "run-1" {
+		t.Errorf("unexpected finish record %v", finished)
+	}
+	if _, ok := finished["outputTokens"]; !ok {
+		t.Errorf("expected usage in the finish record, got %v", finished)
+	}
+	if _, ok := finished["messages"]; ok {
+		t.Error("expected no bodies without WithLogBodies")
+	}
+}
+
+func TestGovernor_LoggerRecordsRetriesAndErrors(t *testing.T) {
+	logger, records := logRecords(t, slog.LevelInfo)
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "bedrock_throttled"}, &GovernorError{Code: "budget_exceeded"})
+	policy := noJitterPolicy()
+	policy.InitialBackoff = time.Millisecond
+	retried := 0
+	policy.OnRetry = func(int, time.Duration, error) { retried++ }
+	g := NewGovernor(WithBackend(mock), WithRetryPolicy(policy), WithLogger(logger),
+		WithLogLevels(LogLevels{Request: slog.LevelDebug, Retry: slog.LevelInfo, Backend: slog.LevelDebug, Error: slog.LevelWarn}))
+
+	if _, err := g.Ask(context.Background(), ModelHaiku45, "hi"); err == nil {
+		t.Fatal("expected budget_exceeded")
+	}
+	if retried != 1 {
+		t.Errorf("expected the policy's OnRetry to still be called, got %d calls", retried)
+	}
+
+	recs := records()
+	want := []string{"INFO llm call retrying", "WARN llm request failed"}
+	if got := messages(recs); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected records %v, got %v", want, got)
+	}
+	if recs[0]["code"] != "bedrock_throttled" || recs[0]["attempt"] != 1.0 {
+		t.Errorf("unexpected retry record %v", recs[0])
+	}
+	if recs[1]["code"] != "budget_exceeded" {
+		t.Errorf("expected the failure's code, got %v", recs[1])
+	}
+}
+
+func TestGovernor_LoggerRecordsStreams(t *testing.T) {
+	logger, records := logRecords(t, slog.LevelDebug)
+	g := NewGovernor(WithBackend(NewMockBackend()), WithLogger(logger))
+
+	stream, err := g.AskStream(context.Background(), ModelHaiku45, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectStream(t, stream)
+
+	recs := records()
+	if len(recs) != 3 || recs[2]["msg"] != "llm request finished" || recs[2]["action"] != "invoke-stream" {
+		t.Fatalf("unexpected records %v", recs)
+	}
+	if recs[2]["stopReason"] != "end_turn" {
+		t.Errorf("expected the streamed response in the finish record, got %v", recs[2])
+	}
+}
+
+func TestGovernor_LogBodies(t *testing.T) {
+	logger, records := logRecords(t, slog.LevelDebug)
+	g := NewGovernor(WithBackend(NewMockBackend()), WithLogger(logger), WithLogBodies(151))
+	image := strings.Repeat("QUJD", 1000)
+
+	if _, err := g.Invoke(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		System:   strings.Repeat("s", 200),
+		Messages: []Message{UserMessage(ImageBlock("png", image), TextBlock(strings.Repeat("é", 100)))},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	started := records()[1]
+	msgs := started["messages"].(string)
+	if strings.Contains(msgs, "QUJD") || !strings.Contains(msgs, "<4000 bytes elided>") {
+		t.Errorf("expected image data to be elided, got %s", msgs)
+	}
+	if !strings.Contains(msgs, "more bytes)") || strings.ContainsRune(msgs, '�') {
+		t.Errorf("expected messages truncated on a rune boundary, got %s", msgs)
+	}
+	if system := started["system"].(string); !strings.HasPrefix(system, strings.Repeat("s", 151)+"…(49 more bytes)") {
+		t.Errorf("expected a truncated system prompt, got %s", system)
+	}
+	if content, ok := records()[2]["content"].(string); !ok || !strings.Contains(content, "[mock]") {
+		t.Errorf("expected the response body in the finish record, got %v", records()[2])
+	}
+}
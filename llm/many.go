@@ -0,0 +1,223 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"sync"
+)
+
+// defaultConcurrency is the number of requests InvokeMany runs at once
+// unless WithConcurrency is given.
+const defaultConcurrency = 8
+
+// InvokeResult is the outcome of one request sent by InvokeMany.
+type InvokeResult struct {
+	Response *InvokeResponse
+	Err      error
+}
+
+// AskResult is the outcome of one prompt sent by AskMany.
+type AskResult struct {
+	Text string
+	Err  error
+}
+
+// InvokeManyOption configures InvokeMany, InvokeSeq and AskMany.
+type InvokeManyOption func(*invokeManyConfig)
+
+type invokeManyConfig struct {
+	concurrency int
+}
+
+// WithConcurrency sets the maximum number of requests in flight at once.
+// The default is 8.
+func WithConcurrency(n int) InvokeManyOption {
+	return func(c *invokeManyConfig) {
+		c.concurrency = n
+	}
+}
+
+// InvokeMany sends requests on a bounded pool of workers and returns
+// their results in input order, one per request.
+//
+// Concurrency starts at the WithConcurrency limit. Each throttled or
+// overloaded response halves it, and it grows back by one after a run of
+// successful responses. Combine InvokeMany with WithRetryPolicy so that
+// throttled requests are retried rather than reported as failures.
+//
+// InvokeMany stops starting new requests once a request fails with
+// budget_exceeded or ctx is done; requests in flight are allowed to
+// finish. Requests that were never started report the error that stopped
+// the pool, which is also returned. The returned error is nil if every
+// request was attempted, even if some failed. A nil request is not sent;
+// its result holds a *ValidationError.
+func (g *Governor) InvokeMany(ctx context.Context, requests []*InvokeRequest, opts ...InvokeManyOption) ([]InvokeResult, error) {
+	results, err := g.InvokeSeq(ctx, slices.Values(requests), opts...)
+	for len(results) < len(requests) {
+		results = append(results, InvokeResult{Err: err})
+	}
+	return results, err
+}
+
+// InvokeSeq is like InvokeMany but reads requests from an iterator, which
+// is only advanced as workers become free. It returns one result per
+// request read from seq, including the request read when the call
+// stopped early, whose result holds the error that stopped it; the
+// requests not yet read are left in seq.
+func (g *Governor) InvokeSeq(ctx context.Context, seq iter.Seq[*InvokeRequest], opts ...InvokeManyOption) ([]InvokeResult, error) {
+	cfg := invokeManyConfig{concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	limiter := newAdaptiveLimiter(max(cfg.concurrency, 1))
+
+	var (
+		mu      sync.Mutex
+		results []InvokeResult
+		stopErr error
+		wg      sync.WaitGroup
+	)
+	stopped := func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return stopErr
+	}
+
+	for req := range seq {
+		if req == nil {
+			mu.Lock()
+			results = append(results, InvokeResult{Err: &ValidationError{Problems: []*FieldError{
+				{Path: fmt.Sprintf("requests[%d]", len(results)), Msg: "is nil"},
+			}}})
+			mu.Unlock()
+			continue
+		}
+		if err := limiter.acquire(ctx); err != nil {
+			mu.Lock()
+			if stopErr == nil {
+				stopErr = err
+			}
+			results = append(results, InvokeResult{Err: stopErr})
+			mu.Unlock()
+			break
+		}
+		if err := stopped(); err != nil {
+			limiter.release(false)
+			mu.Lock()
+			results = append(results, InvokeResult{Err: err})
+			mu.Unlock()
+			break
+		}
+
+		mu.Lock()
+		i := len(results)
+		results = append(results, InvokeResult{})
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := g.Invoke(ctx, req)
+			limiter.release(isCapacityError(err))
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = InvokeResult{Response: resp, Err: err}
+			if ge, ok := IsGovernorError(err); ok && ge.IsBudgetExceeded() && stopErr == nil {
+				stopErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	return results, stopErr
+}
+
+// AskMany sends each prompt to model as InvokeMany does and returns the
+// response texts in input order.
+func (g *Governor) AskMany(ctx context.Context, model string, prompts []string, opts ...InvokeManyOption) ([]AskResult, error) {
+	requests := make([]*InvokeRequest, len(prompts))
+	for i, prompt := range prompts {
+		requests[i] = &InvokeRequest{
+			Model:    model,
+			Messages: []Message{UserMessage(TextBlock(prompt))},
+		}
+	}
+
+	results, err := g.InvokeMany(ctx, requests, opts...)
+	answers := make([]AskResult, len(results))
+	for i, r := range results {
+		answers[i].Err = r.Err
+		if r.Response != nil {
+			answers[i].Text = r.Response.Text()
+		}
+	}
+	return answers, err
+}
+
+// isCapacityError reports whether err means the provider is short of
+// capacity, so fewer requests should be sent at once.
+func isCapacityError(err error) bool {
+	ge, ok := IsGovernorError(err)
+	return ok && (ge.IsThrottled() || ge.IsOverloaded())
+}
+
+// adaptiveLimiter bounds the number of requests in flight. Its limit is
+// halved by each capacity error and raised by one after limit successes in
+// a row, up to max.
+type adaptiveLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	max       int
+	active    int
+	successes int
+	changed   chan struct{}
+}
+
+func newAdaptiveLimiter(n int) *adaptiveLimiter {
+	return &adaptiveLimiter{limit: n, max: n, changed: make(chan struct{})}
+}
+
+// acquire waits for a free slot or for ctx to end.
+func (l *adaptiveLimiter) acquire(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		if l.active < l.limit {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees a slot and adjusts the limit.
+func (l *adaptiveLimiter) release(capacityErr bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	if capacityErr {
+		l.limit = max(l.limit/2, 1)
+		l.successes = 0
+	} else if l.limit < l.max {
+		l.successes++
+		if l.successes >= l.limit {
+			l.limit++
+			l.successes = 0
+		}
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
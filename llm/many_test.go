@@ -0,0 +1,248 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowBackend delays each Invoke and records the peak number of calls in
+// flight.
+type slowBackend struct {
+	*MockBackend
+	delay time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (b *slowBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	b.mu.Lock()
+	b.inFlight++
+	b.peak = max(b.peak, b.inFlight)
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.inFlight--
+		b.mu.Unlock()
+	}()
+
+	time.Sleep(b.delay)
+	return b.MockBackend.Invoke(ctx, req)
+}
+
+func TestGovernor_AskManyOrderAndConcurrency(t *testing.T) {
+	backend := &slowBackend{MockBackend: NewMockBackend(), delay: 5 * time.Millisecond}
+	g := NewGovernor(WithBackend(backend))
+
+	prompts := make([]string, 20)
+	for i := range prompts {
+		prompts[i] = fmt.Sprintf("row %d", i)
+	}
+	results, err := g.AskMany(context.Background(), ModelHaiku45, prompts, WithConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(prompts) {
+		t.Fatalf("expected %d results, got %d", len(prompts), len(results))
+	}
+	for i, r := range results {
+		if r.Err != nil || r.Text != "[mock] "+prompts[i] {
+			t.Errorf("result %d: got %q, %v", i, r.Text, r.Err)
+		}
+	}
+	if backend.peak > 3 {
+		t.Errorf("expected at most 3 calls in flight, saw %d", backend.peak)
+	}
+}
+
+func TestGovernor_InvokeManyStopsOnBudgetExceeded(t *testing.T) {
+	mock := NewMockBackend()
+	budgetErr := &GovernorError{Code: "budget_exceeded", Msg: "spent"}
+	mock.QueueErrors(nil, &GovernorError{Code: "invalid_request", Msg: "bad"}, budgetErr)
+	g := NewGovernor(WithBackend(mock))
+
+	requests := make([]*InvokeRequest, 5)
+	for i := range requests {
+		requests[i] = &InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}
+	}
+	results, err := g.InvokeMany(context.Background(), requests, WithConcurrency(1))
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget_exceeded, got %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+	if results[0].Err != nil || !errors.Is(results[1].Err, ErrInvalidRequest) {
+		t.Errorf("unexpected leading results: %+v", results[:2])
+	}
+	for i := 2; i < 5; i++ {
+		if !errors.Is(results[i].Err, ErrBudgetExceeded) {
+			t.Errorf("result %d: expected budget_exceeded, got %v", i, results[i].Err)
+		}
+	}
+	if n := len(mock.Calls()); n != 3 {
+		t.Errorf("expected 3 calls before stopping, got %d", n)
+	}
+}
+
+func TestGovernor_InvokeManyCanceled(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := g.AskMany(ctx, ModelHaiku45, []string{"a", "b"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for i, r := range results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("result %d: expected context.Canceled, got %v", i, r.Err)
+		}
+	}
+	if len(mock.Calls()) != 0 {
+		t.Errorf("expected no calls, got %d", len(mock.Calls()))
+	}
+}
+
+func TestGovernor_InvokeSeqStopsReading(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "budget_exceeded"})
+	g := NewGovernor(WithBackend(mock))
+
+	read := 0
+	seq := func(yield func(*InvokeRequest) bool) {
+		for range 10 {
+			read++
+			if !yield(&InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}) {
+				return
+			}
+		}
+	}
+	results, err := g.InvokeSeq(context.Background(), seq, WithConcurrency(1))
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected budget_exceeded, got %v", err)
+	}
+	if len(results) != read || read != 2 {
+		t.Fatalf("expected a result for each of 2 requests read, got %d results and %d reads", len(results), read)
+	}
+	if !errors.Is(results[1].Err, ErrBudgetExceeded) {
+		t.Errorf("expected the unsent request to report budget_exceeded, got %v", results[1].Err)
+	}
+}
+
+func TestGovernor_InvokeSeqCanceledKeepsReadRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g := NewGovernor(WithBackend(NewMockBackend()))
+
+	read := 0
+	seq := func(yield func(*InvokeRequest) bool) {
+		for range 3 {
+			read++
+			if !yield(&InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}) {
+				return
+			}
+		}
+	}
+	results, err := g.InvokeSeq(ctx, seq)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(results) != read {
+		t.Fatalf("expected a result for each of %d requests read, got %d", read, len(results))
+	}
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", results[0].Err)
+	}
+}
+
+// cancelingBackend cancels a context once it has answered a request.
+type cancelingBackend struct {
+	*MockBackend
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	defer b.cancel()
+	return b.MockBackend.Invoke(ctx, req)
+}
+
+func TestGovernor_InvokeManyCanceledAfterLastRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGovernor(WithBackend(&cancelingBackend{MockBackend: NewMockBackend(), cancel: cancel}))
+
+	results, err := g.AskMany(ctx, ModelHaiku45, []string{"a"})
+	if err != nil {
+		t.Errorf("expected no error once every request was attempted, got %v", err)
+	}
+	if len(results) != 1 || results[0].Err != nil {
+		t.Errorf("expected the request to succeed, got %+v", results)
+	}
+}
+
+func TestGovernor_InvokeManyNilRequest(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock))
+	req := func() *InvokeRequest {
+		return &InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}
+	}
+
+	results, err := g.InvokeMany(context.Background(), []*InvokeRequest{req(), nil, req()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verr *ValidationError
+	if !errors.As(results[1].Err, &verr) || verr.Problems[0].Path != "requests[1]" {
+		t.Errorf("expected a validation error for the nil request, got %v", results[1].Err)
+	}
+	if results[0].Err != nil || results[2].Err != nil || len(mock.Calls()) != 2 {
+		t.Errorf("expected the other requests to be sent, got %+v with %d calls", results, len(mock.Calls()))
+	}
+}
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := newAdaptiveLimiter(8)
+	currentLimit := func() int {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.limit
+	}
+	ctx := context.Background()
+	for range 8 {
+		if err := l.acquire(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.release(true)
+	if got := currentLimit(); got != 4 {
+		t.Fatalf("expected limit 4 after throttling, got %d", got)
+	}
+	l.release(true)
+	l.release(true)
+	l.release(true)
+	if got := currentLimit(); got != 1 {
+		t.Fatalf("expected limit 1, got %d", got)
+	}
+
+	// One success at limit 1 raises it to 2, two more raise it to 3.
+	for range 3 {
+		l.release(false)
+	}
+	if got := currentLimit(); got != 3 {
+		t.Errorf("expected limit 3 after successes, got %d", got)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(timeout); err != nil {
+		t.Fatalf("expected a free slot, got %v", err)
+	}
+}
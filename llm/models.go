@@ -1,5 +1,12 @@
 package llm
 
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
 // Well-known Bedrock inference profile IDs for convenience.
 // Use "us." prefix for US region on-demand inference profiles.
 const (
@@ -7,4 +14,227 @@ const (
 	ModelSonnet45 = "us.anthropic.claude-sonnet-4-5-20250929-v1:0"
 	ModelSonnet46 = "us.anthropic.claude-sonnet-4-6"
 	ModelSonnet4  = "us.anthropic.claude-sonnet-4-20250514-v1:0"
-)
\ No newline at end of file
+)
+
+// ModelSpec describes a model: its identifiers, limits, prices and
+// capabilities.
+type ModelSpec struct {
+	// ID is the Bedrock inference profile ID, e.g. ModelSonnet46. The same
+	// model is also found under each of RegionPrefixes, e.g.
+	// "eu.anthropic.claude-sonnet-4-6".
+	ID string
+
+	// AnthropicName is the model name used by the Anthropic API.
+	AnthropicName string
+
+	// RegionPrefixes lists the cross-region inference profile prefixes the
+	// model is offered under, such as "us", "eu" and "apac".
+	RegionPrefixes []string
+
+	ContextWindow   int64
+	MaxOutputTokens int32
+
+	// Prices in USD per million tokens. Cache prices left at zero are
+	// derived from InputPrice when the model is registered.
+	InputPrice        float64
+	OutputPrice       float64
+	CacheWritePrice   float64 // 5-minute cache writes
+	CacheWrite1hPrice float64 // 1-hour cache writes
+	CacheReadPrice    float64
+
+	SupportsVision    bool
+	SupportsDocuments bool
+	SupportsThinking  bool
+	SupportsTools     bool
+}
+
+// ProfileID returns the inference profile ID of the model in the given
+// region prefix, e.g. ProfileID("eu").
+func (s ModelSpec) ProfileID(region string) string {
+	return region + "." + s.baseID()
+}
+
+// Prompt cache prices relative to the base input price, used when a
+// registered model does not set its cache prices.
+const (
+	cacheWrite5mMultiplier = 1.25
+	cacheWrite1hMultiplier = 2.0
+	cacheReadMultiplier    = 0.1
+)
+
+// defaultRegionPrefixes are the cross-region inference profile prefixes
+// the built-in models are offered under.
+var defaultRegionPrefixes = []string{"us", "eu", "apac"}
+
+var modelRegistry = newModelRegistry(
+	ModelSpec{
+		ID:                ModelHaiku45,
+		AnthropicName:     "claude-haiku-4-5-20251001",
+		RegionPrefixes:    defaultRegionPrefixes,
+		ContextWindow:     200_000,
+		MaxOutputTokens:   64_000,
+		InputPrice:        1,
+		OutputPrice:       5,
+		SupportsVision:    true,
+		SupportsDocuments: true,
+		SupportsThinking:  true,
+		SupportsTools:     true,
+	},
+	ModelSpec{
+		ID:                ModelSonnet45,
+		AnthropicName:     "claude-sonnet-4-5-20250929",
+		RegionPrefixes:    defaultRegionPrefixes,
+		ContextWindow:     200_000,
+		MaxOutputTokens:   64_000,
+		InputPrice:        3,
+		OutputPrice:       15,
+		SupportsVision:    true,
+		SupportsDocuments: true,
+		SupportsThinking:  true,
+		SupportsTools:     true,
+	},
+	ModelSpec{
+		ID:                ModelSonnet46,
+		AnthropicName:     "claude-sonnet-4-6",
+		RegionPrefixes:    defaultRegionPrefixes,
+		ContextWindow:     200_000,
+		MaxOutputTokens:   64_000,
+		InputPrice:        3,
+		OutputPrice:       15,
+		SupportsVision:    true,
+		SupportsDocuments: true,
+		SupportsThinking:  true,
+		SupportsTools:     true,
+	},
+	ModelSpec{
+		ID:                ModelSonnet4,
+		AnthropicName:     "claude-sonnet-4-20250514",
+		RegionPrefixes:    defaultRegionPrefixes,
+		ContextWindow:     200_000,
+		MaxOutputTokens:   64_000,
+		InputPrice:        3,
+		OutputPrice:       15,
+		SupportsVision:    true,
+		SupportsDocuments: true,
+		SupportsThinking:  true,
+		SupportsTools:     true,
+	},
+)
+
+// registry holds model specs in registration order.
+type registry struct {
+	mu    sync.RWMutex
+	specs []ModelSpec
+}
+
+func newModelRegistry(specs ...ModelSpec) *registry {
+	r := &registry{}
+	for _, spec := range specs {
+		r.register(spec)
+	}
+	return r
+}
+
+func (r *registry) register(spec ModelSpec) {
+	if spec.CacheWritePrice == 0 {
+		spec.CacheWritePrice = spec.InputPrice * cacheWrite5mMultiplier
+	}
+	if spec.CacheWrite1hPrice == 0 {
+		spec.CacheWrite1hPrice = spec.InputPrice * cacheWrite1hMultiplier
+	}
+	if spec.CacheReadPrice == 0 {
+		spec.CacheReadPrice = spec.InputPrice * cacheReadMultiplier
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.specs {
+		if existing.baseID() == spec.baseID() {
+			r.specs[i] = spec
+			return
+		}
+	}
+	r.specs = append(r.specs, spec)
+}
+
+func (r *registry) lookup(model string) (ModelSpec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, spec := range r.specs {
+		if spec.matches(model) {
+			return spec, true
+		}
+	}
+	return ModelSpec{}, false
+}
+
+func (r *registry) all() []ModelSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ModelSpec, len(r.specs))
+	copy(out, r.specs)
+	return out
+}
+
+// baseID returns the inference profile ID without its region prefix.
+func (s ModelSpec) baseID() string {
+	for _, prefix := range slices.Concat(s.RegionPrefixes, defaultRegionPrefixes) {
+		if rest, ok := strings.CutPrefix(s.ID, prefix+"."); ok {
+			return rest
+		}
+	}
+	return s.ID
+}
+
+// matches reports whether model names this spec, by inference profile ID
+// in any of its regions, by the ID without a region, or by Anthropic name.
+func (s ModelSpec) matches(model string) bool {
+	if model == s.ID || (s.AnthropicName != "" && model == s.AnthropicName) {
+		return true
+	}
+	base := s.baseID()
+	if model == base {
+		return true
+	}
+	for _, prefix := range s.RegionPrefixes {
+		if model == prefix+"."+base {
+			return true
+		}
+	}
+	return false
+}
+
+// stripRegionPrefix removes a well-known region prefix such as "us." from
+// an inference profile ID.
+func stripRegionPrefix(id string) string {
+	for _, prefix := range defaultRegionPrefixes {
+		if rest, ok := strings.CutPrefix(id, prefix+"."); ok {
+			return rest
+		}
+	}
+	return id
+}
+
+// LookupModel returns the spec of a model given by inference profile ID,
+// in any region (e.g. "eu.anthropic.claude-sonnet-4-6"), or by Anthropic
+// API name.
+func LookupModel(model string) (ModelSpec, bool) {
+	return modelRegistry.lookup(model)
+}
+
+// RegisterModel adds a model to the registry, or replaces the spec of a
+// model with the same inference profile ID. Registered models are listed
+// by the mock and Anthropic backends, mapped by MapModel and priced in
+// usage estimates.
+func RegisterModel(spec ModelSpec) error {
+	if spec.ID == "" {
+		return errors.New("llm: model spec has no ID")
+	}
+	modelRegistry.register(spec)
+	return nil
+}
+
+// Models returns the specs of all registered models, built-in models first.
+func Models() []ModelSpec {
+	return modelRegistry.all()
+}
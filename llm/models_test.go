@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"testing"
+)
+
+// restoreModelRegistry undoes RegisterModel calls made by a test.
+func restoreModelRegistry(t *testing.T) {
+	saved := Models()
+	t.Cleanup(func() {
+		modelRegistry = newModelRegistry(saved...)
+	})
+}
+
+func TestLookupModel(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{ModelSonnet46, ModelSonnet46},
+		{"eu.anthropic.claude-sonnet-4-6", ModelSonnet46},
+		{"apac.anthropic.claude-haiku-4-5-20251001-v1:0", ModelHaiku45},
+		{"anthropic.claude-sonnet-4-20250514-v1:0", ModelSonnet4},
+		{"claude-sonnet-4-5-20250929", ModelSonnet45},
+	}
+	for _, tt := range tests {
+		spec, ok := LookupModel(tt.input)
+		if !ok || spec.ID != tt.want {
+			t.Errorf("LookupModel(%q) = %q, %v; want %q", tt.input, spec.ID, ok, tt.want)
+		}
+	}
+	if _, ok := LookupModel("us.anthropic.claude-future-model"); ok {
+		t.Error("expected unknown model not to be found")
+	}
+}
+
+func TestModelSpec_Details(t *testing.T) {
+	spec, ok := LookupModel(ModelHaiku45)
+	if !ok {
+		t.Fatal("expected Haiku 4.5 to be registered")
+	}
+	if spec.ContextWindow != 200_000 || spec.MaxOutputTokens != 64_000 {
+		t.Errorf("unexpected limits: %+v", spec)
+	}
+	if !spec.SupportsDocuments || !spec.SupportsVision || !spec.SupportsThinking || !spec.SupportsTools {
+		t.Errorf("unexpected capabilities: %+v", spec)
+	}
+	if spec.CacheWritePrice != 1.25 || spec.CacheWrite1hPrice != 2 || spec.CacheReadPrice != 0.1 {
+		t.Errorf("expected derived cache prices, got %+v", spec)
+	}
+	if got := spec.ProfileID("eu"); got != "eu.anthropic.claude-haiku-4-5-20251001-v1:0" {
+		t.Errorf("unexpected eu profile ID %q", got)
+	}
+}
+
+func TestRegisterModel(t *testing.T) {
+	restoreModelRegistry(t)
+
+	err := RegisterModel(ModelSpec{
+		ID:              "us.anthropic.claude-opus-9",
+		AnthropicName:   "claude-opus-9",
+		RegionPrefixes:  []string{"us", "jp"},
+		ContextWindow:   500_000,
+		MaxOutputTokens: 128_000,
+		InputPrice:      10,
+		OutputPrice:     50,
+		CacheReadPrice:  0.5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec, ok := LookupModel("jp.anthropic.claude-opus-9")
+	if !ok || spec.ContextWindow != 500_000 {
+		t.Fatalf("expected registered model, got %+v %v", spec, ok)
+	}
+	if spec.CacheReadPrice != 0.5 || spec.CacheWritePrice != 12.5 {
+		t.Errorf("expected explicit read price and derived write price, got %+v", spec)
+	}
+	if got := MapModel("jp.anthropic.claude-opus-9"); got != "claude-opus-9" {
+		t.Errorf("MapModel = %q, want claude-opus-9", got)
+	}
+	if cost := estimateCostUsd("claude-opus-9", UsageInfo{InputTokens: 1_000_000}, 0); cost != 10 {
+		t.Errorf("expected $10 for a million input tokens, got %v", cost)
+	}
+
+	resp, err := NewMockBackend().ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(resp.Models); n != 5 || resp.Models[n-1].ModelID != "us.anthropic.claude-opus-9" {
+		t.Errorf("expected the new model to be listed last, got %+v", resp.Models)
+	}
+}
+
+func TestRegisterModel_Replaces(t *testing.T) {
+	restoreModelRegistry(t)
+
+	spec, _ := LookupModel(ModelSonnet46)
+	spec.ContextWindow = 1_000_000
+	if err := RegisterModel(spec); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := LookupModel(ModelSonnet46); got.ContextWindow != 1_000_000 {
+		t.Errorf("expected updated context window, got %d", got.ContextWindow)
+	}
+	if len(Models()) != 4 {
+		t.Errorf("expected replacement, not a new entry; got %d models", len(Models()))
+	}
+}
+
+func TestRegisterModel_RequiresID(t *testing.T) {
+	if err := RegisterModel(ModelSpec{AnthropicName: "claude-x"}); err == nil {
+		t.Error("expected error for spec without ID")
+	}
+}
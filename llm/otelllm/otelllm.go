@@ -0,0 +1,287 @@
+// Package otelllm instruments a Governor with OpenTelemetry. It records a
+// client span per Invoke, CheckBudget, ListModels and CountTokens call,
+// with GenAI semantic-convention attributes, and histograms of call
+// latency, token usage and estimated cost:
+//
+//	gov := llm.NewGovernor(llm.WithInterceptors(otelllm.Interceptor()))
+//
+// The instrumentation is an llm.Interceptor, so it sees only the calls
+// interceptors see: InvokeStream and the batch methods are not traced or
+// measured.
+//
+// The trace context of each call is propagated to the LLM Governor Lambda
+// in the payload's traceContext field (see llm.ContextWithTraceContext),
+// so that the governor can join the caller's trace.
+package otelllm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pennsieve/pennsieve-go-llm/llm"
+)
+
+const (
+	scopeName           = "github.com/pennsieve/pennsieve-go-llm/llm/otelllm"
+	defaultProviderName = "aws.bedrock"
+)
+
+// Attributes outside the GenAI semantic conventions.
+const (
+	ExecutionRunIDKey           = attribute.Key("pennsieve.llm.execution_run_id")
+	EstimatedCostKey            = attribute.Key("pennsieve.llm.estimated_cost_usd")
+	PeriodRemainingBudgetKey    = attribute.Key("pennsieve.llm.budget.period_remaining_usd")
+	ExecutionRemainingBudgetKey = attribute.Key("pennsieve.llm.budget.execution_remaining_usd")
+	ModelCountKey               = attribute.Key("pennsieve.llm.models.count")
+)
+
+// Option configures the interceptor.
+type Option func(*config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagators    propagation.TextMapPropagator
+	providerName   string
+}
+
+// WithTracerProvider sets the tracer provider. It defaults to the global
+// provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider. It defaults to the global
+// provider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// WithPropagators sets the propagators used to pass the trace context to
+// the governor. They default to the global propagators, which propagate
+// nothing unless otel.SetTextMapPropagator has been called.
+func WithPropagators(p propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = p
+	}
+}
+
+// WithProviderName sets the gen_ai.provider.name attribute. It defaults to
+// "aws.bedrock", which serves the governor's models; use "anthropic" with
+// llm.AnthropicBackend.
+func WithProviderName(name string) Option {
+	return func(c *config) {
+		c.providerName = name
+	}
+}
+
+// instruments are the histograms an interceptor records.
+type instruments struct {
+	duration metric.Float64Histogram
+	tokens   metric.Int64Histogram
+	cost     metric.Float64Histogram
+}
+
+// Interceptor returns an llm.Interceptor that traces and measures calls.
+// Errors creating instruments are passed to otel.Handle and the affected
+// instruments record nothing.
+func Interceptor(opts ...Option) llm.Interceptor {
+	c := config{providerName: defaultProviderName}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.tracerProvider == nil {
+		c.tracerProvider = otel.GetTracerProvider()
+	}
+	if c.meterProvider == nil {
+		c.meterProvider = otel.GetMeterProvider()
+	}
+	if c.propagators == nil {
+		c.propagators = otel.GetTextMapPropagator()
+	}
+
+	tracer := c.tracerProvider.Tracer(scopeName)
+	inst := newInstruments(c.meterProvider.Meter(scopeName))
+
+	return func(ctx context.Context, req *llm.InvokeRequest, next llm.InvokeHandler) (*llm.InvokeResponse, error) {
+		operation := operationName(req)
+		name := operation
+		if req.Model != "" {
+			name += " " + req.Model
+		}
+		common := []attribute.KeyValue{
+			semconv.GenAIOperationNameKey.String(operation),
+			semconv.GenAIProviderNameKey.String(c.providerName),
+		}
+		if req.Model != "" {
+			common = append(common, semconv.GenAIRequestModelKey.String(req.Model))
+		}
+
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(common...),
+			trace.WithAttributes(requestAttributes(req)...),
+		)
+		defer span.End()
+
+		carrier := propagation.MapCarrier{}
+		c.propagators.Inject(ctx, carrier)
+		if len(carrier) > 0 {
+			ctx = llm.ContextWithTraceContext(ctx, carrier)
+		}
+
+		start := time.Now()
+		resp, err := next(ctx, req)
+		elapsed := time.Since(start).Seconds()
+
+		switch {
+		case err != nil:
+			errType := semconv.ErrorTypeKey.String(errorType(err))
+			span.SetAttributes(errType)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			common = append(common, errType)
+		case resp != nil:
+			span.SetAttributes(responseAttributes(req, resp)...)
+			if resp.Model != "" {
+				common = append(common, semconv.GenAIResponseModelKey.String(resp.Model))
+			}
+		}
+		set := metric.WithAttributeSet(attribute.NewSet(common...))
+		inst.duration.Record(ctx, elapsed, set)
+		if err == nil && resp != nil && req.Action == "invoke" {
+			inst.tokens.Record(ctx, inputTokens(resp.Usage), set, metric.WithAttributes(semconv.GenAITokenTypeInput))
+			inst.tokens.Record(ctx, resp.Usage.OutputTokens, set, metric.WithAttributes(semconv.GenAITokenTypeOutput))
+			inst.cost.Record(ctx, resp.Usage.EstimatedCostUsd, set)
+		}
+		return resp, err
+	}
+}
+
+// newInstruments creates the histograms, using the GenAI
+// semantic-convention names and bucket boundaries where they exist.
+// Instruments that cannot be created record nothing.
+func newInstruments(meter metric.Meter) instruments {
+	duration, err := meter.Float64Histogram("gen_ai.client.operation.duration",
+		metric.WithDescription("Duration of LLM Governor calls."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24, 20.48, 40.96, 81.92),
+	)
+	if err != nil {
+		otel.Handle(err)
+		duration = noop.Float64Histogram{}
+	}
+	tokens, err := meter.Int64Histogram("gen_ai.client.token.usage",
+		metric.WithDescription("Number of input and output tokens used."),
+		metric.WithUnit("{token}"),
+		metric.WithExplicitBucketBoundaries(1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864),
+	)
+	if err != nil {
+		otel.Handle(err)
+		tokens = noop.Int64Histogram{}
+	}
+	cost, err := meter.Float64Histogram("pennsieve.llm.cost",
+		metric.WithDescription("Estimated cost of LLM invocations."),
+		metric.WithUnit("USD"),
+		metric.WithExplicitBucketBoundaries(0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5),
+	)
+	if err != nil {
+		otel.Handle(err)
+		cost = noop.Float64Histogram{}
+	}
+	return instruments{duration: duration, tokens: tokens, cost: cost}
+}
+
+// operationName returns the gen_ai.operation.name for req: "chat" for
+// invocations, otherwise the governor action.
+func operationName(req *llm.InvokeRequest) string {
+	if req.Action == "invoke" {
+		return "chat"
+	}
+	return req.Action
+}
+
+func requestAttributes(req *llm.InvokeRequest) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if req.ExecutionRunID != "" {
+		attrs = append(attrs, ExecutionRunIDKey.String(req.ExecutionRunID))
+	}
+	if req.MaxTokens > 0 {
+		attrs = append(attrs, semconv.GenAIRequestMaxTokensKey.Int(int(req.MaxTokens)))
+	}
+	if req.Temperature != 0 {
+		attrs = append(attrs, semconv.GenAIRequestTemperatureKey.Float64(float64(req.Temperature)))
+	}
+	return attrs
+}
+
+func responseAttributes(req *llm.InvokeRequest, resp *llm.InvokeResponse) []attribute.KeyValue {
+	switch req.Action {
+	case "check-budget":
+		if resp.BudgetRemaining == (llm.BudgetInfo{}) {
+			return nil
+		}
+		return []attribute.KeyValue{
+			PeriodRemainingBudgetKey.Float64(resp.BudgetRemaining.PeriodRemainingUsd),
+			ExecutionRemainingBudgetKey.Float64(resp.BudgetRemaining.ExecutionRemainingUsd),
+		}
+	case "list-models":
+		return []attribute.KeyValue{ModelCountKey.Int(len(resp.Models))}
+	case "count-tokens":
+		if resp.TokenCount == nil {
+			return nil
+		}
+		return []attribute.KeyValue{semconv.GenAIUsageInputTokensKey.Int64(resp.TokenCount.InputTokens)}
+	}
+	if req.Action != "invoke" {
+		return nil
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.GenAIUsageInputTokensKey.Int64(inputTokens(resp.Usage)),
+		semconv.GenAIUsageOutputTokensKey.Int64(resp.Usage.OutputTokens),
+		EstimatedCostKey.Float64(resp.Usage.EstimatedCostUsd),
+	}
+	if resp.Usage.CacheCreationInputTokens > 0 {
+		attrs = append(attrs, semconv.GenAIUsageCacheCreationInputTokensKey.Int64(resp.Usage.CacheCreationInputTokens))
+	}
+	if resp.Usage.CacheReadInputTokens > 0 {
+		attrs = append(attrs, semconv.GenAIUsageCacheReadInputTokensKey.Int64(resp.Usage.CacheReadInputTokens))
+	}
+	if resp.Model != "" {
+		attrs = append(attrs, semconv.GenAIResponseModelKey.String(resp.Model))
+	}
+	if resp.StopReason != "" {
+		attrs = append(attrs, semconv.GenAIResponseFinishReasonsKey.StringSlice([]string{resp.StopReason}))
+	}
+	return attrs
+}
+
+// inputTokens returns all input tokens, including those written to or read
+// from the prompt cache, as gen_ai.usage.input_tokens requires.
+func inputTokens(usage llm.UsageInfo) int64 {
+	return usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens
+}
+
+// errorType returns the error.type of err: the GovernorError code, or the
+// error's Go type.
+func errorType(err error) string {
+	if ge, ok := llm.IsGovernorError(err); ok {
+		return ge.Code
+	}
+	return fmt.Sprintf("%T", err)
+}
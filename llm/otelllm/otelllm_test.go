@@ -0,0 +1,246 @@
+package otelllm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pennsieve/pennsieve-go-llm/llm"
+)
+
+type telemetry struct {
+	spans  *tracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+	client *llm.MockLambdaClient
+	gov    *llm.Governor
+}
+
+func newTelemetry(t *testing.T) *telemetry {
+	t.Helper()
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		mp.Shutdown(context.Background())
+	})
+
+	client := llm.NewMockLambdaClient()
+	gov := llm.NewGovernor(
+		llm.WithBackend(llm.NewLambdaBackend("llm-governor", client)),
+		llm.WithExecutionRunID("run-1"),
+		llm.WithInterceptors(Interceptor(
+			WithTracerProvider(tp),
+			WithMeterProvider(mp),
+			WithPropagators(propagation.TraceContext{}),
+		)),
+	)
+	return &telemetry{spans: spans, reader: reader, client: client, gov: gov}
+}
+
+func (tel *telemetry) onlySpan(t *testing.T) tracetest.SpanStub {
+	t.Helper()
+	spans := tel.spans.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	return spans[0]
+}
+
+func (tel *telemetry) histograms(t *testing.T) map[string]metricdata.Aggregation {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := tel.reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = m.Data
+		}
+	}
+	return out
+}
+
+func attr(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestInterceptor_Invoke(t *testing.T) {
+	tel := newTelemetry(t)
+	tel.client.QueueResponse(&llm.InvokeResponse{
+		Content:    []llm.ResponseContent{{Type: "text", Text: "hello"}},
+		Model:      llm.ModelHaiku45,
+		StopReason: "end_turn",
+		Usage:      llm.UsageInfo{InputTokens: 10, CacheReadInputTokens: 4, OutputTokens: 3, EstimatedCostUsd: 0.002},
+	})
+
+	if _, err := tel.gov.Invoke(context.Background(), &llm.InvokeRequest{
+		Model:     llm.ModelHaiku45,
+		Messages:  []llm.Message{llm.UserMessage(llm.TextBlock("hi"))},
+		MaxTokens: 100,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	span := tel.onlySpan(t)
+	if span.Name != "chat "+llm.ModelHaiku45 || span.SpanKind != trace.SpanKindClient {
+		t.Errorf("unexpected span %q of kind %s", span.Name, span.SpanKind)
+	}
+	want := map[attribute.Key]attribute.Value{
+		"gen_ai.operation.name":                attribute.StringValue("chat"),
+		"gen_ai.provider.name":                 attribute.StringValue("aws.bedrock"),
+		"gen_ai.request.model":                 attribute.StringValue(llm.ModelHaiku45),
+		"gen_ai.request.max_tokens":            attribute.IntValue(100),
+		"gen_ai.response.model":                attribute.StringValue(llm.ModelHaiku45),
+		"gen_ai.response.finish_reasons":       attribute.StringSliceValue([]string{"end_turn"}),
+		"gen_ai.usage.input_tokens":            attribute.Int64Value(14),
+		"gen_ai.usage.cache_read.input_tokens": attribute.Int64Value(4),
+		"gen_ai.usage.output_tokens":           attribute.Int64Value(3),
+		ExecutionRunIDKey:                      attribute.StringValue("run-1"),
+		EstimatedCostKey:                       attribute.Float64Value(0.002),
+	}
+	for key, value := range want {
+		if got, ok := attr(span.Attributes, key); !ok || got != value {
+			t.Errorf("%s: expected %v, got %v", key, value.Emit(), got.Emit())
+		}
+	}
+
+	var sent struct {
+		TraceContext map[string]string `json:"traceContext"`
+	}
+	if err := json.Unmarshal(tel.client.Payloads()[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	traceID := span.SpanContext.TraceID().String()
+	if tp := sent.TraceContext["traceparent"]; !strings.Contains(tp, traceID) {
+		t.Errorf("expected traceparent for trace %s, got %q", traceID, tp)
+	}
+
+	hists := tel.histograms(t)
+	tokens, ok := hists["gen_ai.client.token.usage"].(metricdata.Histogram[int64])
+	if !ok || len(tokens.DataPoints) != 2 {
+		t.Fatalf("expected input and output token data points, got %+v", hists["gen_ai.client.token.usage"])
+	}
+	for _, dp := range tokens.DataPoints {
+		tokenType, _ := dp.Attributes.Value("gen_ai.token.type")
+		if want := map[string]int64{"input": 14, "output": 3}[tokenType.AsString()]; dp.Sum != want {
+			t.Errorf("%s tokens: expected %d, got %d", tokenType.AsString(), want, dp.Sum)
+		}
+	}
+	cost, ok := hists["pennsieve.llm.cost"].(metricdata.Histogram[float64])
+	if !ok || len(cost.DataPoints) != 1 || cost.DataPoints[0].Sum != 0.002 {
+		t.Errorf("unexpected cost histogram %+v", hists["pennsieve.llm.cost"])
+	}
+	duration, ok := hists["gen_ai.client.operation.duration"].(metricdata.Histogram[float64])
+	if !ok || len(duration.DataPoints) != 1 || duration.DataPoints[0].Count != 1 {
+		t.Errorf("unexpected duration histogram %+v", hists["gen_ai.client.operation.duration"])
+	}
+}
+
+func TestInterceptor_GovernorError(t *testing.T) {
+	tel := newTelemetry(t)
+	tel.client.QueueResponse(llm.ErrorResponse{Error: "budget_exceeded", Message: "no budget left"})
+
+	_, err := tel.gov.Ask(context.Background(), llm.ModelHaiku45, "hi")
+	if !errors.Is(err, llm.ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+
+	span := tel.onlySpan(t)
+	if span.Status.Code != codes.Error {
+		t.Errorf("expected error status, got %v", span.Status)
+	}
+	if got, _ := attr(span.Attributes, "error.type"); got.AsString() != "budget_exceeded" {
+		t.Errorf("expected error.type budget_exceeded, got %q", got.AsString())
+	}
+	if len(span.Events) == 0 || span.Events[0].Name != "exception" {
+		t.Errorf("expected the error to be recorded, got events %+v", span.Events)
+	}
+
+	hists := tel.histograms(t)
+	if _, ok := hists["gen_ai.client.token.usage"]; ok {
+		t.Error("expected no token usage for a failed call")
+	}
+	duration := hists["gen_ai.client.operation.duration"].(metricdata.Histogram[float64])
+	if errType, _ := duration.DataPoints[0].Attributes.Value("error.type"); errType.AsString() != "budget_exceeded" {
+		t.Errorf("expected the duration to carry error.type, got %q", errType.AsString())
+	}
+}
+
+func TestInterceptor_CheckBudgetAndListModels(t *testing.T) {
+	tel := newTelemetry(t)
+	tel.client.QueueResponse(llm.CheckBudgetResponse{BudgetPeriod: "daily", PeriodRemainingUsd: 4, ExecutionRemainingUsd: 1.5})
+	tel.client.QueueResponse(llm.ListModelsResponse{Models: []llm.ModelInfo{{ModelID: llm.ModelHaiku45}, {ModelID: llm.ModelSonnet46}}})
+	ctx := context.Background()
+
+	if _, err := tel.gov.CheckBudget(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tel.gov.ListModels(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tel.spans.GetSpans()
+	if len(spans) != 2 || spans[0].Name != "check-budget" || spans[1].Name != "list-models" {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+	if got, _ := attr(spans[0].Attributes, ExecutionRemainingBudgetKey); got.AsFloat64() != 1.5 {
+		t.Errorf("expected execution budget 1.5, got %v", got.Emit())
+	}
+	if got, _ := attr(spans[0].Attributes, ExecutionRunIDKey); got.AsString() != "run-1" {
+		t.Errorf("expected execution run ID run-1, got %q", got.AsString())
+	}
+	if got, _ := attr(spans[1].Attributes, ModelCountKey); got.AsInt64() != 2 {
+		t.Errorf("expected 2 models, got %v", got.Emit())
+	}
+	for i, payload := range tel.client.Payloads() {
+		if !strings.Contains(string(payload), spans[i].SpanContext.TraceID().String()) {
+			t.Errorf("expected payload %d to carry the trace context, got %s", i, payload)
+		}
+	}
+}
+
+func TestResponseAttributes_NoResult(t *testing.T) {
+	for _, action := range []string{"check-budget", "count-tokens"} {
+		attrs := responseAttributes(&llm.InvokeRequest{Action: action}, &llm.InvokeResponse{})
+		if len(attrs) != 0 {
+			t.Errorf("%s: expected no attributes for an empty response, got %v", action, attrs)
+		}
+	}
+}
+
+func TestInterceptor_CountTokens(t *testing.T) {
+	tel := newTelemetry(t)
+	tel.client.QueueResponse(llm.CountTokensResponse{InputTokens: 42, MessageTokens: []int64{42}})
+
+	req := &llm.InvokeRequest{Model: llm.ModelHaiku45, Messages: []llm.Message{llm.UserMessage(llm.TextBlock("hi"))}}
+	if _, err := tel.gov.CountTokens(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	span := tel.onlySpan(t)
+	if span.Name != "count-tokens "+llm.ModelHaiku45 {
+		t.Errorf("unexpected span name %q", span.Name)
+	}
+	if got, _ := attr(span.Attributes, "gen_ai.usage.input_tokens"); got.AsInt64() != 42 {
+		t.Errorf("expected 42 input tokens, got %v", got.Emit())
+	}
+}
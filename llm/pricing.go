@@ -0,0 +1,296 @@
+package llm
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// estimateCostUsd prices token usage for a model using the prices in its
+// ModelSpec. Cache writes are priced at the 5-minute rate except for
+// cacheWrite1hTokens of them, which are priced at the 1-hour rate. Unknown
+// models cost 0.
+func estimateCostUsd(model string, usage UsageInfo, cacheWrite1hTokens int64) float64 {
+	spec, ok := LookupModel(model)
+	if !ok {
+		return 0
+	}
+	cacheWrite5mTokens := usage.CacheCreationInputTokens - cacheWrite1hTokens
+	cost := float64(usage.InputTokens)*spec.InputPrice +
+		float64(usage.OutputTokens)*spec.OutputPrice +
+		float64(cacheWrite5mTokens)*spec.CacheWritePrice +
+		float64(cacheWrite1hTokens)*spec.CacheWrite1hPrice +
+		float64(usage.CacheReadInputTokens)*spec.CacheReadPrice
+	return cost / 1e6
+}
+
+// defaultMaxTokens is the output limit used when a request leaves
+// MaxTokens unset.
+const defaultMaxTokens = 1024
+
+// withEstimatedCost returns resp with Usage.EstimatedCostUsd computed from
+// its token usage if the backend did not provide a cost. resp is copied
+// rather than modified, since canned responses may be shared.
+func withEstimatedCost(resp *InvokeResponse, model string) *InvokeResponse {
+	if resp.Usage.EstimatedCostUsd != 0 {
+		return resp
+	}
+	if resp.Model != "" {
+		model = resp.Model
+	}
+	cost := estimateCostUsd(model, resp.Usage, 0)
+	if cost == 0 {
+		return resp
+	}
+	priced := *resp
+	priced.Usage.EstimatedCostUsd = cost
+	return &priced
+}
+
+// batchPriceFactor is the fraction of the standard price charged for
+// requests processed in a message batch.
+const batchPriceFactor = 0.5
+
+// withBatchCost is like withEstimatedCost but prices usage at the batch
+// rate.
+func withBatchCost(resp *InvokeResponse, model string) *InvokeResponse {
+	if resp.Usage.EstimatedCostUsd != 0 {
+		return resp
+	}
+	priced := withEstimatedCost(resp, model)
+	if priced != resp {
+		priced.Usage.EstimatedCostUsd *= batchPriceFactor
+	}
+	return priced
+}
+
+// CostEstimate is a pre-flight projection of what a request may cost.
+type CostEstimate struct {
+	Model string
+
+	// InputTokens is a local estimate of the prompt size, and
+	// MaxOutputTokens the output limit the request allows (MaxTokens, or
+	// the backend default when unset). Thinking tokens count as output.
+	InputTokens     int64
+	MaxOutputTokens int64
+
+	// InputCostUsd prices the input, at the cache write rate when the
+	// request has cache breakpoints. MaxOutputCostUsd prices a response
+	// that uses every allowed output token, and MaxCostUsd is their sum.
+	InputCostUsd     float64
+	MaxOutputCostUsd float64
+	MaxCostUsd       float64
+}
+
+// EstimateCost projects the worst-case cost of req before it is sent,
+// from an estimate of its input tokens and its MaxTokens. It returns a
+// not_found GovernorError if the model is not in the registry.
+func (g *Governor) EstimateCost(req *InvokeRequest) (*CostEstimate, error) {
+	est, ok := estimateRequestCost(req)
+	if !ok {
+		return nil, &GovernorError{Code: "not_found", Msg: fmt.Sprintf("no pricing for model %q", req.Model)}
+	}
+	return est, nil
+}
+
+// estimateRequestCost projects the worst-case cost of req, reporting false
+// if its model is not in the registry.
+func estimateRequestCost(req *InvokeRequest) (*CostEstimate, bool) {
+	spec, ok := LookupModel(req.Model)
+	if !ok {
+		return nil, false
+	}
+
+	est := &CostEstimate{
+		Model:           spec.ID,
+		InputTokens:     estimateInputTokens(req),
+		MaxOutputTokens: int64(req.MaxTokens),
+	}
+	if est.MaxOutputTokens == 0 {
+		est.MaxOutputTokens = defaultMaxTokens
+	}
+
+	inputPrice := spec.InputPrice
+	switch cacheTTL(req) {
+	case "1h":
+		inputPrice = spec.CacheWrite1hPrice
+	case "5m":
+		inputPrice = spec.CacheWritePrice
+	}
+	est.InputCostUsd = float64(est.InputTokens) * inputPrice / 1e6
+	est.MaxOutputCostUsd = float64(est.MaxOutputTokens) * spec.OutputPrice / 1e6
+	est.MaxCostUsd = est.InputCostUsd + est.MaxOutputCostUsd
+	return est, true
+}
+
+// streamCostUsd returns the cost of a stream's response. A stream closed or
+// failed before its final message_stop event has no usage, so its cost is
+// estimated from the request and the output received so far.
+func streamCostUsd(req *InvokeRequest, resp *InvokeResponse) float64 {
+	if resp.Usage.EstimatedCostUsd != 0 {
+		return resp.Usage.EstimatedCostUsd
+	}
+	return estimateCostUsd(req.Model, streamUsage(req, resp), 0)
+}
+
+// streamUsage returns the usage of a stream's response, estimating the
+// input from the request and the output from the content received when
+// the stream ended before reporting them.
+func streamUsage(req *InvokeRequest, resp *InvokeResponse) UsageInfo {
+	usage := resp.Usage
+	if usage.InputTokens == 0 {
+		usage.InputTokens = estimateInputTokens(req)
+	}
+	if usage.OutputTokens == 0 {
+		for _, c := range resp.Content {
+			usage.OutputTokens += textTokens(c.Text) + textTokens(string(c.Input))
+		}
+	}
+	return usage
+}
+
+// cacheTTL returns the longest cache TTL requested anywhere in req: "1h",
+// "5m", or "" when the request has no cache breakpoints.
+func cacheTTL(req *InvokeRequest) string {
+	ttl := ""
+	mark := func(cc *CacheControl) {
+		switch {
+		case cc == nil:
+		case cc.TTL == "1h":
+			ttl = "1h"
+		case ttl == "":
+			ttl = "5m"
+		}
+	}
+	mark(req.SystemCacheControl)
+	for _, m := range req.Messages {
+		for _, b := range m.Content {
+			mark(b.CacheControl)
+		}
+	}
+	return ttl
+}
+
+// Rough token sizes used by estimateInputTokens.
+const (
+	charsPerToken    = 4
+	tokensPerImage   = 1600
+	tokensPerMsg     = 4
+	bytesPerPDFPage  = 50_000
+	tokensPerPDFPage = 2000
+
+	// tokensPerEFSDocument is the estimate for an efs_document that
+	// cannot be measured locally, about five PDF pages.
+	tokensPerEFSDocument = 5 * tokensPerPDFPage
+)
+
+// estimateInputTokens estimates the prompt size of req without calling the
+// provider, at roughly four characters per token. Images count as a
+// full-size image and PDFs by page estimate, so the result errs high.
+func estimateInputTokens(req *InvokeRequest) int64 {
+	n := textTokens(req.System)
+	for _, tool := range req.Tools {
+		schema, _ := json.Marshal(tool.InputSchema)
+		n += textTokens(tool.Name) + textTokens(tool.Description) + textTokens(string(schema))
+	}
+	for _, m := range req.Messages {
+		n += messageTokens(m)
+	}
+	return n
+}
+
+// estimateTokenCounts estimates the input tokens of req and of each of its
+// messages. The system prompt and tools are counted with the first
+// message.
+func estimateTokenCounts(req *InvokeRequest) *CountTokensResponse {
+	resp := &CountTokensResponse{MessageTokens: make([]int64, len(req.Messages))}
+	for i, m := range req.Messages {
+		resp.MessageTokens[i] = messageTokens(m)
+		resp.InputTokens += resp.MessageTokens[i]
+	}
+	if len(req.Messages) > 0 {
+		overhead := estimateInputTokens(req) - resp.InputTokens
+		resp.MessageTokens[0] += overhead
+		resp.InputTokens += overhead
+	}
+	return resp
+}
+
+// scaleTokenCounts scales estimated counts in proportion so that they sum
+// to total, giving any rounding remainder to the first message.
+func scaleTokenCounts(est *CountTokensResponse, total int64) *CountTokensResponse {
+	resp := &CountTokensResponse{InputTokens: total, MessageTokens: make([]int64, len(est.MessageTokens))}
+	if len(resp.MessageTokens) == 0 {
+		return resp
+	}
+	var sum int64
+	for i, n := range est.MessageTokens {
+		if est.InputTokens > 0 {
+			resp.MessageTokens[i] = n * total / est.InputTokens
+		}
+		sum += resp.MessageTokens[i]
+	}
+	resp.MessageTokens[0] += total - sum
+	return resp
+}
+
+func messageTokens(m Message) int64 {
+	n := int64(tokensPerMsg)
+	for _, b := range m.Content {
+		n += blockTokens(b)
+	}
+	return n
+}
+
+func blockTokens(b ContentBlock) int64 {
+	switch b.Type {
+	case "image":
+		return tokensPerImage
+	case "document":
+		return fileTokens(b.Format, int64(base64.StdEncoding.DecodedLen(len(b.Data))))
+	case "efs_document":
+		return efsDocumentTokens(b.Path)
+	case "tool_use":
+		return textTokens(b.Name) + textTokens(string(b.Input))
+	case "tool_result":
+		var n int64
+		for _, inner := range b.Content {
+			n += blockTokens(inner)
+		}
+		return n
+	default:
+		return textTokens(b.Text)
+	}
+}
+
+// efsDocumentTokens estimates the tokens of an efs_document. A relative
+// path normally names a file on the governor's EFS, which cannot be read
+// here, so it gets a fixed estimate. An absolute path can only name a
+// local file (LambdaBackend rejects it), which is measured if it exists.
+func efsDocumentTokens(path string) int64 {
+	if !filepath.IsAbs(path) {
+		return tokensPerEFSDocument
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return tokensPerEFSDocument
+	}
+	return fileTokens(strings.TrimPrefix(filepath.Ext(path), "."), info.Size())
+}
+
+// fileTokens estimates the tokens of a document of the given size.
+func fileTokens(format string, size int64) int64 {
+	if strings.EqualFold(format, "pdf") {
+		pages := (size + bytesPerPDFPage - 1) / bytesPerPDFPage
+		return pages * tokensPerPDFPage
+	}
+	return (size + charsPerToken - 1) / charsPerToken
+}
+
+func textTokens(s string) int64 {
+	return int64((utf8.RuneCountInString(s) + charsPerToken - 1) / charsPerToken)
+}
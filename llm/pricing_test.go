@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMockBackend_PricesUsage(t *testing.T) {
+	canned := &InvokeResponse{
+		Content: []ResponseContent{{Type: "text", Text: "ok"}},
+		Model:   ModelSonnet46,
+		Usage:   UsageInfo{InputTokens: 1000, OutputTokens: 200},
+	}
+	b := NewMockBackend()
+	b.SetResponse(canned)
+
+	resp, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelSonnet46})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1000 * $3/M + 200 * $15/M
+	if !approxEqual(resp.Usage.EstimatedCostUsd, 0.006) {
+		t.Errorf("expected $0.006, got %v", resp.Usage.EstimatedCostUsd)
+	}
+	if canned.Usage.EstimatedCostUsd != 0 {
+		t.Error("expected the canned response not to be modified")
+	}
+}
+
+func TestMockBackend_KeepsExplicitCost(t *testing.T) {
+	b := NewMockBackend()
+	b.SetResponse(&InvokeResponse{Model: ModelSonnet46, Usage: UsageInfo{InputTokens: 1000, EstimatedCostUsd: 0.5}})
+
+	resp, _ := b.Invoke(context.Background(), &InvokeRequest{Model: ModelSonnet46})
+	if resp.Usage.EstimatedCostUsd != 0.5 {
+		t.Errorf("expected explicit cost to be kept, got %v", resp.Usage.EstimatedCostUsd)
+	}
+}
+
+func TestMockBackend_EchoHasUsage(t *testing.T) {
+	b := NewMockBackend()
+	resp, err := b.Invoke(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock(strings.Repeat("a", 400)))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Usage.InputTokens < 100 || resp.Usage.OutputTokens == 0 || resp.Usage.EstimatedCostUsd == 0 {
+		t.Errorf("expected estimated usage and cost, got %+v", resp.Usage)
+	}
+}
+
+func TestLambdaBackend_PricesUsageWhenMissing(t *testing.T) {
+	client := NewMockLambdaClient()
+	client.QueueResponse(&InvokeResponse{Model: ModelHaiku45, Usage: UsageInfo{InputTokens: 1_000_000}})
+	b := NewLambdaBackend("gov", client)
+
+	resp, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approxEqual(resp.Usage.EstimatedCostUsd, 1) {
+		t.Errorf("expected $1, got %v", resp.Usage.EstimatedCostUsd)
+	}
+}
+
+func TestGovernor_EstimateCost(t *testing.T) {
+	g := NewGovernor(WithBackend(NewMockBackend()))
+	req := &InvokeRequest{
+		Model:     ModelSonnet46,
+		MaxTokens: 2000,
+		Messages:  []Message{UserMessage(TextBlock(strings.Repeat("x", 3996)))},
+	}
+
+	est, err := g.EstimateCost(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 999 text tokens plus per-message overhead.
+	if est.InputTokens != 1003 {
+		t.Errorf("expected 1003 input tokens, got %d", est.InputTokens)
+	}
+	if est.MaxOutputTokens != 2000 {
+		t.Errorf("expected 2000 output tokens, got %d", est.MaxOutputTokens)
+	}
+	if !approxEqual(est.MaxOutputCostUsd, 0.03) || !approxEqual(est.MaxCostUsd, est.InputCostUsd+0.03) {
+		t.Errorf("unexpected cost estimate: %+v", est)
+	}
+
+	req.Messages[0].Content[0] = CachedBlock(req.Messages[0].Content[0], "1h")
+	cached, err := g.EstimateCost(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approxEqual(cached.InputCostUsd, est.InputCostUsd*2) {
+		t.Errorf("expected 1h cache writes to double the input cost, got %v vs %v", cached.InputCostUsd, est.InputCostUsd)
+	}
+}
+
+func TestGovernor_EstimateCostDefaults(t *testing.T) {
+	g := NewGovernor(WithBackend(NewMockBackend()))
+	est, err := g.EstimateCost(&InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("hi"), ImageBlock("png", "aGk="))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.MaxOutputTokens != defaultMaxTokens {
+		t.Errorf("expected default max tokens, got %d", est.MaxOutputTokens)
+	}
+	if est.InputTokens < tokensPerImage {
+		t.Errorf("expected image to be counted, got %d tokens", est.InputTokens)
+	}
+
+	_, err = g.EstimateCost(&InvokeRequest{Model: "unknown-model"})
+	if ge, ok := IsGovernorError(err); !ok || !ge.IsNotFound() {
+		t.Errorf("expected not_found for unknown model, got %v", err)
+	}
+}
+
+func TestFileTokens(t *testing.T) {
+	cases := []struct {
+		format string
+		size   int64
+		want   int64
+	}{
+		{"pdf", 1, tokensPerPDFPage},
+		{"PDF", bytesPerPDFPage + 1, 2 * tokensPerPDFPage},
+		{"csv", 10, 3},
+		{"txt", 0, 0},
+	}
+	for _, c := range cases {
+		if got := fileTokens(c.format, c.size); got != c.want {
+			t.Errorf("fileTokens(%q, %d) = %d, want %d", c.format, c.size, got, c.want)
+		}
+	}
+}
+
+func TestEFSDocumentTokens(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(local, make([]byte, 400), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A relative path names a file on the governor's EFS, even if a local
+	// file such as pricing_test.go has the same name.
+	cases := map[string]int64{
+		"datasets/paper.pdf":                   tokensPerEFSDocument,
+		"pricing_test.go":                      tokensPerEFSDocument,
+		local:                                  100,
+		filepath.Join(t.TempDir(), "gone.pdf"): tokensPerEFSDocument,
+	}
+	for path, want := range cases {
+		if got := blockTokens(FileBlock(path)); got != want {
+			t.Errorf("%s: expected %d tokens, got %d", path, want, got)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimit sets per-minute limits for RateLimitBackend. A zero field
+// means no limit of that kind.
+type RateLimit struct {
+	RequestsPerMinute     int
+	InputTokensPerMinute  int64
+	OutputTokensPerMinute int64
+}
+
+// RateLimitBackend wraps a Backend and paces Invoke and InvokeStream calls
+// to stay within a RateLimit, so that goroutines sharing a backend do not
+// trip the provider's rate limits.
+//
+// Each limit is a token bucket that holds up to a minute's allowance and
+// refills continuously. A call takes one request, its estimated input
+// tokens and its MaxTokens (or the default) of output tokens up front,
+// waiting until all three are available or ctx ends. When the response
+// arrives the buckets are corrected with the actual usage; a failed call
+// gives its tokens back.
+//
+// Other calls, including token counting and batches, which the provider
+// limits separately, are passed through.
+type RateLimitBackend struct {
+	backend Backend
+	now     func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+
+	mu           sync.Mutex
+	requests     *tokenBucket
+	inputTokens  *tokenBucket
+	outputTokens *tokenBucket
+}
+
+// NewRateLimitBackend wraps backend with the given limits.
+func NewRateLimitBackend(backend Backend, limit RateLimit) *RateLimitBackend {
+	return &RateLimitBackend{
+		backend:      backend,
+		now:          time.Now,
+		sleep:        sleepContext,
+		requests:     newTokenBucket(float64(limit.RequestsPerMinute)),
+		inputTokens:  newTokenBucket(float64(limit.InputTokensPerMinute)),
+		outputTokens: newTokenBucket(float64(limit.OutputTokensPerMinute)),
+	}
+}
+
+// Unwrap returns the wrapped backend.
+func (b *RateLimitBackend) Unwrap() Backend {
+	return b.backend
+}
+
+func (b *RateLimitBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	in, out, err := b.acquire(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.backend.Invoke(ctx, req)
+	if err != nil {
+		b.reconcile(in, out, UsageInfo{})
+		return nil, err
+	}
+	b.reconcile(in, out, resp.Usage)
+	return resp, nil
+}
+
+// InvokeStream waits for capacity before opening the stream and corrects
+// the token buckets once the stream ends. A stream closed or failed before
+// it reported its usage is charged the estimated input and the output
+// received so far.
+func (b *RateLimitBackend) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	in, out, err := b.acquire(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := b.backend.InvokeStream(ctx, req)
+	if err != nil {
+		b.reconcile(in, out, UsageInfo{})
+		return nil, err
+	}
+	return newStream(ctx, func(_ context.Context, emit func(StreamEvent) error) error {
+		defer inner.Close()
+		defer func() { b.reconcile(in, out, streamUsage(req, inner.Response())) }()
+		for inner.Next() {
+			if err := emit(inner.Event()); err != nil {
+				return err
+			}
+		}
+		return inner.Err()
+	}), nil
+}
+
+func (b *RateLimitBackend) CheckBudget(ctx context.Context, executionRunID string) (*CheckBudgetResponse, error) {
+	return b.backend.CheckBudget(ctx, executionRunID)
+}
+
+func (b *RateLimitBackend) ListModels(ctx context.Context) (*ListModelsResponse, error) {
+	return b.backend.ListModels(ctx)
+}
+
+func (b *RateLimitBackend) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	return b.backend.CountTokens(ctx, req)
+}
+
+func (b *RateLimitBackend) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.SubmitBatch(ctx, requests)
+}
+
+func (b *RateLimitBackend) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.BatchStatus(ctx, batchID)
+}
+
+func (b *RateLimitBackend) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.BatchResults(ctx, batchID)
+}
+
+// acquire waits until the request's estimated tokens are available in
+// every bucket, takes them, and returns the amounts taken.
+func (b *RateLimitBackend) acquire(ctx context.Context, req *InvokeRequest) (in, out float64, err error) {
+	in = float64(estimateInputTokens(req))
+	out = float64(req.MaxTokens)
+	if out == 0 {
+		out = defaultMaxTokens
+	}
+
+	for {
+		b.mu.Lock()
+		now := b.now()
+		wait := max(
+			b.requests.wait(1, now),
+			b.inputTokens.wait(in, now),
+			b.outputTokens.wait(out, now),
+		)
+		if wait == 0 {
+			b.requests.add(-1)
+			b.inputTokens.add(-in)
+			b.outputTokens.add(-out)
+			b.mu.Unlock()
+			return in, out, nil
+		}
+		b.mu.Unlock()
+
+		if err := b.sleep(ctx, wait); err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// reconcile replaces the estimated tokens taken by acquire with the
+// actual usage. Cache reads do not count towards input token limits.
+func (b *RateLimitBackend) reconcile(in, out float64, usage UsageInfo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inputTokens.add(in - float64(usage.InputTokens+usage.CacheCreationInputTokens))
+	b.outputTokens.add(out - float64(usage.OutputTokens))
+}
+
+// tokenBucket is a token bucket holding up to a minute's allowance. A nil
+// bucket is unlimited. The balance may go negative when actual usage
+// exceeds the estimate, delaying later calls until it is repaid.
+type tokenBucket struct {
+	capacity float64
+	perSec   float64
+	tokens   float64
+	last     time.Time
+}
+
+// newTokenBucket returns a full bucket for perMinute, or nil if perMinute
+// is not positive.
+func newTokenBucket(perMinute float64) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{capacity: perMinute, perSec: perMinute / 60, tokens: perMinute}
+}
+
+// wait returns how long until n tokens are available. Requests larger than
+// the bucket only wait for it to be full.
+func (t *tokenBucket) wait(n float64, now time.Time) time.Duration {
+	if t == nil {
+		return 0
+	}
+	if !t.last.IsZero() {
+		t.tokens = math.Min(t.capacity, t.tokens+now.Sub(t.last).Seconds()*t.perSec)
+	}
+	t.last = now
+
+	n = math.Min(n, t.capacity)
+	if t.tokens >= n {
+		return 0
+	}
+	return time.Duration(math.Ceil((n - t.tokens) / t.perSec * float64(time.Second)))
+}
+
+// add adds n tokens, which may be negative, up to the bucket's capacity.
+func (t *tokenBucket) add(n float64) {
+	if t == nil {
+		return
+	}
+	t.tokens = math.Min(t.capacity, t.tokens+n)
+}
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestRateLimitBackend returns a RateLimitBackend on a fake clock that
+// advances when the backend sleeps, recording the total time slept.
+func newTestRateLimitBackend(backend Backend, limit RateLimit) (*RateLimitBackend, *time.Duration) {
+	b := NewRateLimitBackend(backend, limit)
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	b.now = func() time.Time { return now }
+	b.sleep = func(ctx context.Context, d time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+	return b, &slept
+}
+
+func rateLimitRequest(maxTokens int32) *InvokeRequest {
+	return &InvokeRequest{
+		Model:     ModelHaiku45,
+		MaxTokens: maxTokens,
+		Messages:  []Message{UserMessage(TextBlock("hi"))},
+	}
+}
+
+func TestRateLimitBackend_RequestsPerMinute(t *testing.T) {
+	b, slept := newTestRateLimitBackend(NewMockBackend(), RateLimit{RequestsPerMinute: 2})
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := b.Invoke(ctx, rateLimitRequest(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *slept != 0 {
+		t.Fatalf("expected the first two requests to run immediately, slept %v", *slept)
+	}
+	if _, err := b.Invoke(ctx, rateLimitRequest(10)); err != nil {
+		t.Fatal(err)
+	}
+	if *slept != 30*time.Second {
+		t.Errorf("expected to wait 30s for the third request, slept %v", *slept)
+	}
+}
+
+func TestRateLimitBackend_ReconcilesOutputTokens(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(&InvokeResponse{
+		Content: []ResponseContent{{Type: "text", Text: "ok"}},
+		Usage:   UsageInfo{InputTokens: 10, OutputTokens: 100},
+	})
+	b, slept := newTestRateLimitBackend(mock, RateLimit{OutputTokensPerMinute: 1000})
+	ctx := context.Background()
+
+	// Each call reserves 600 tokens but only uses 100, so the second call
+	// need not wait for the first reservation to refill.
+	for range 2 {
+		if _, err := b.Invoke(ctx, rateLimitRequest(600)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if *slept != 0 {
+		t.Errorf("expected no wait after reconciliation, slept %v", *slept)
+	}
+
+	// 800 tokens are left; a 900 token reservation waits for 100 tokens,
+	// which refill in 6s.
+	if _, err := b.Invoke(ctx, rateLimitRequest(900)); err != nil {
+		t.Fatal(err)
+	}
+	if *slept != 6*time.Second {
+		t.Errorf("expected to wait 6s, slept %v", *slept)
+	}
+}
+
+func TestRateLimitBackend_InputTokensAndRefund(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "bedrock_throttled"})
+	b, slept := newTestRateLimitBackend(mock, RateLimit{InputTokensPerMinute: 60})
+	ctx := context.Background()
+
+	req := rateLimitRequest(10)
+	req.System = string(make([]byte, 200)) // about 50 tokens
+	if _, err := b.Invoke(ctx, req); err == nil {
+		t.Fatal("expected the queued error")
+	}
+	if _, err := b.Invoke(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if *slept != 0 {
+		t.Errorf("expected the failed call's tokens to be refunded, slept %v", *slept)
+	}
+}
+
+func TestRateLimitBackend_StreamClosedEarly(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(&InvokeResponse{
+		Content: []ResponseContent{{Type: "text", Text: "a response that is never read to the end"}},
+		Usage:   UsageInfo{InputTokens: 10, OutputTokens: 100},
+	})
+	b, _ := newTestRateLimitBackend(mock, RateLimit{InputTokensPerMinute: 1000, OutputTokensPerMinute: 1000})
+	req := rateLimitRequest(600)
+
+	s, err := b.InvokeStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Next() {
+		t.Fatalf("expected an event, got %v", s.Err())
+	}
+	s.Close()
+
+	if want := 1000 - float64(estimateInputTokens(req)); b.inputTokens.tokens != want {
+		t.Errorf("expected the closed stream to be charged its estimated input, %g tokens left, got %g", want, b.inputTokens.tokens)
+	}
+	if b.outputTokens.tokens <= 400 || b.outputTokens.tokens > 1000 {
+		t.Errorf("expected the unused output reservation to be refunded, got %g tokens left", b.outputTokens.tokens)
+	}
+}
+
+func TestRateLimitBackend_ContextCanceled(t *testing.T) {
+	b, _ := newTestRateLimitBackend(NewMockBackend(), RateLimit{RequestsPerMinute: 1})
+	if _, err := b.Invoke(context.Background(), rateLimitRequest(10)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Invoke(ctx, rateLimitRequest(10)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled while waiting, got %v", err)
+	}
+}
+
+func TestGovernor_WithRateLimit(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock), WithRateLimit(RateLimit{RequestsPerMinute: 60}), WithRetryPolicy(DefaultRetryPolicy()))
+
+	retry, ok := g.WrappedBackend().(*RetryBackend)
+	if !ok {
+		t.Fatalf("expected RetryBackend outermost, got %T", g.Backend())
+	}
+	if _, ok := retry.Unwrap().(*RateLimitBackend); !ok {
+		t.Errorf("expected RateLimitBackend inside RetryBackend, got %T", retry.Unwrap())
+	}
+	if g.Available() {
+		t.Error("expected the mock to be detected through the decorators")
+	}
+}
@@ -0,0 +1,249 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// retryableCodes are the GovernorError codes that indicate a transient
+// condition worth retrying. Budget, permission and validation failures are
+// deterministic and never retried.
+var retryableCodes = map[string]bool{
+	"bedrock_throttled": true,
+	"model_overloaded":  true,
+	"provider_error":    true,
+}
+
+// IsRetryable reports whether err is a transient failure that may succeed
+// if the request is sent again.
+func IsRetryable(err error) bool {
+	if fe, ok := IsLambdaFunctionError(err); ok {
+		return fe.Transient()
+	}
+	ge, ok := IsGovernorError(err)
+	return ok && retryableCodes[ge.Code]
+}
+
+// RetryPolicy controls how RetryBackend retries failed calls.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. Each later delay
+	// is multiplied by Multiplier, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter randomly shortens each delay by up to this fraction (0–1) so
+	// that concurrent callers do not retry in lockstep.
+	Jitter float64
+
+	// MaxElapsed stops retrying once the next attempt would start more
+	// than this long after the first. Zero means no limit.
+	MaxElapsed time.Duration
+
+	// Retryable classifies errors. It defaults to IsRetryable.
+	Retryable func(error) bool
+
+	// OnRetry, if set, is called before waiting delay to retry after
+	// attempt number attempt failed with err.
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+// DefaultRetryPolicy returns a policy of 4 attempts with exponential
+// backoff from 1s to 30s and 20% jitter.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// backoff returns the delay before retry number n (starting at 1).
+// A server-provided retry-after delay takes precedence over the computed
+// backoff when it is longer.
+func (p RetryPolicy) backoff(n int, err error) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(n-1))
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	delay := time.Duration(d)
+
+	if ge, ok := IsGovernorError(err); ok && ge.RetryAfterSec > 0 {
+		if after := time.Duration(ge.RetryAfterSec) * time.Second; after > delay {
+			delay = after
+		}
+	}
+	return delay
+}
+
+// RetryBackend wraps a Backend and retries calls that fail with a
+// retryable error, sleeping between attempts according to a RetryPolicy.
+//
+// InvokeStream only retries failures to open the stream; errors reported
+// after events have started flowing are returned to the caller.
+type RetryBackend struct {
+	backend Backend
+	policy  RetryPolicy
+	sleep   func(ctx context.Context, d time.Duration) error
+	now     func() time.Time
+}
+
+// NewRetryBackend wraps backend with the given retry policy.
+func NewRetryBackend(backend Backend, policy RetryPolicy) *RetryBackend {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 1
+	}
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+	return &RetryBackend{
+		backend: backend,
+		policy:  policy,
+		sleep:   sleepContext,
+		now:     time.Now,
+	}
+}
+
+// Unwrap returns the wrapped backend.
+func (b *RetryBackend) Unwrap() Backend {
+	return b.backend
+}
+
+func (b *RetryBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	var resp *InvokeResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.Invoke(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+func (b *RetryBackend) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	var stream *Stream
+	err := b.do(ctx, func() error {
+		var err error
+		stream, err = b.backend.InvokeStream(ctx, req)
+		return err
+	})
+	return stream, err
+}
+
+func (b *RetryBackend) CheckBudget(ctx context.Context, executionRunID string) (*CheckBudgetResponse, error) {
+	var resp *CheckBudgetResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.CheckBudget(ctx, executionRunID)
+		return err
+	})
+	return resp, err
+}
+
+func (b *RetryBackend) ListModels(ctx context.Context) (*ListModelsResponse, error) {
+	var resp *ListModelsResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.ListModels(ctx)
+		return err
+	})
+	return resp, err
+}
+
+func (b *RetryBackend) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	var resp *CountTokensResponse
+	err := b.do(ctx, func() error {
+		var err error
+		resp, err = b.backend.CountTokens(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// SubmitBatch is not retried: a submission that failed after the batch
+// was created, such as one that timed out, would create a second batch.
+func (b *RetryBackend) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	return bb.SubmitBatch(ctx, requests)
+}
+
+func (b *RetryBackend) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	var batch *Batch
+	err = b.do(ctx, func() error {
+		var err error
+		batch, err = bb.BatchStatus(ctx, batchID)
+		return err
+	})
+	return batch, err
+}
+
+// BatchResults only retries failures to start reading the results.
+func (b *RetryBackend) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	bb, err := batchBackend(b.backend)
+	if err != nil {
+		return nil, err
+	}
+	var results *BatchResults
+	err = b.do(ctx, func() error {
+		var err error
+		results, err = bb.BatchResults(ctx, batchID)
+		return err
+	})
+	return results, err
+}
+
+// do runs call until it succeeds, fails with a non-retryable error, or
+// the policy's attempt or time limits are reached. The last error is
+// returned unchanged so callers can still inspect it, unless ctx ends
+// while waiting to retry.
+func (b *RetryBackend) do(ctx context.Context, call func() error) error {
+	start := b.now()
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= b.policy.MaxAttempts || !b.policy.Retryable(err) {
+			return err
+		}
+
+		delay := b.policy.backoff(attempt, err)
+		if b.policy.MaxElapsed > 0 && b.now().Add(delay).Sub(start) > b.policy.MaxElapsed {
+			return err
+		}
+		if b.policy.OnRetry != nil {
+			b.policy.OnRetry(attempt, delay, err)
+		}
+		if err := b.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext sleeps for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRetryBackend returns a RetryBackend that records sleeps instead
+// of waiting, advancing a fake clock by each delay.
+func newTestRetryBackend(backend Backend, policy RetryPolicy) (*RetryBackend, *[]time.Duration) {
+	b := NewRetryBackend(backend, policy)
+	var slept []time.Duration
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+	b.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		now = now.Add(d)
+		return ctx.Err()
+	}
+	return b, &slept
+}
+
+func noJitterPolicy() RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.Jitter = 0
+	return p
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&GovernorError{Code: "bedrock_throttled"}, true},
+		{&GovernorError{Code: "model_overloaded"}, true},
+		{&GovernorError{Code: "budget_exceeded"}, false},
+		{&GovernorError{Code: "model_not_allowed"}, false},
+		{&GovernorError{Code: "model_not_enabled"}, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryBackend_RetriesThrottledWithBackoff(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(
+		&GovernorError{Code: "bedrock_throttled"},
+		&GovernorError{Code: "bedrock_throttled"},
+	)
+	b, slept := newTestRetryBackend(mock, noJitterPolicy())
+
+	resp, err := b.Invoke(context.Background(), &InvokeRequest{Messages: []Message{UserMessage(TextBlock("hi"))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "[mock] hi" {
+		t.Errorf("unexpected response %q", resp.Text())
+	}
+	if len(mock.Calls()) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(mock.Calls()))
+	}
+	want := []time.Duration{time.Second, 2 * time.Second}
+	if len(*slept) != 2 || (*slept)[0] != want[0] || (*slept)[1] != want[1] {
+		t.Errorf("expected backoff %v, got %v", want, *slept)
+	}
+}
+
+func TestRetryBackend_HonoursRetryAfter(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "bedrock_throttled", RetryAfterSec: 7})
+	b, slept := newTestRetryBackend(mock, noJitterPolicy())
+
+	if _, err := b.Invoke(context.Background(), &InvokeRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(*slept) != 1 || (*slept)[0] != 7*time.Second {
+		t.Errorf("expected a 7s wait, got %v", *slept)
+	}
+}
+
+func TestRetryBackend_DoesNotRetryBudgetExceeded(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "budget_exceeded"})
+	b, slept := newTestRetryBackend(mock, noJitterPolicy())
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{})
+	ge, ok := IsGovernorError(err)
+	if !ok || !ge.IsBudgetExceeded() {
+		t.Fatalf("expected budget_exceeded, got %v", err)
+	}
+	if len(mock.Calls()) != 1 || len(*slept) != 0 {
+		t.Errorf("expected no retries, got %d calls and sleeps %v", len(mock.Calls()), *slept)
+	}
+}
+
+func TestRetryBackend_MaxAttempts(t *testing.T) {
+	mock := NewMockBackend()
+	throttled := &GovernorError{Code: "bedrock_throttled"}
+	mock.QueueErrors(throttled, throttled, throttled, throttled, throttled)
+	b, _ := newTestRetryBackend(mock, noJitterPolicy())
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{})
+	if err != throttled {
+		t.Fatalf("expected the last throttled error, got %v", err)
+	}
+	if len(mock.Calls()) != 4 {
+		t.Errorf("expected 4 attempts, got %d", len(mock.Calls()))
+	}
+}
+
+func TestRetryBackend_MaxElapsed(t *testing.T) {
+	mock := NewMockBackend()
+	throttled := &GovernorError{Code: "bedrock_throttled"}
+	mock.QueueErrors(throttled, throttled, throttled)
+	policy := noJitterPolicy()
+	policy.MaxElapsed = 2 * time.Second
+	b, slept := newTestRetryBackend(mock, policy)
+
+	if _, err := b.Invoke(context.Background(), &InvokeRequest{}); err != throttled {
+		t.Fatalf("expected throttled error, got %v", err)
+	}
+	// 1s fits in the window; the following 2s backoff would end at 3s.
+	if len(mock.Calls()) != 2 || len(*slept) != 1 {
+		t.Errorf("expected 2 attempts and 1 sleep, got %d and %v", len(mock.Calls()), *slept)
+	}
+}
+
+func TestRetryBackend_ContextCancelledWhileWaiting(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "bedrock_throttled", RetryAfterSec: 60})
+	b := NewRetryBackend(mock, DefaultRetryPolicy())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := b.Invoke(ctx, &InvokeRequest{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected retry wait to stop when the context ended")
+	}
+}
+
+func TestRetryPolicy_BackoffJitterAndCap(t *testing.T) {
+	p := DefaultRetryPolicy()
+	for n := 1; n <= 10; n++ {
+		d := p.backoff(n, nil)
+		base := min(time.Duration(float64(time.Second)*float64(int(1)<<(n-1))), 30*time.Second)
+		if d > base || d < time.Duration(float64(base)*0.8) {
+			t.Errorf("retry %d: delay %v outside [0.8, 1.0] x %v", n, d, base)
+		}
+	}
+}
+
+func TestGovernor_WithRetryPolicy(t *testing.T) {
+	mock := NewMockBackend()
+	mock.QueueErrors(&GovernorError{Code: "bedrock_throttled"})
+	policy := noJitterPolicy()
+	policy.InitialBackoff = time.Millisecond
+	g := NewGovernor(WithBackend(mock), WithRetryPolicy(policy))
+
+	if _, ok := g.WrappedBackend().(*RetryBackend); !ok {
+		t.Fatalf("expected RetryBackend, got %T", g.WrappedBackend())
+	}
+	if g.Available() {
+		t.Error("expected Available() to see through the retry wrapper to the mock")
+	}
+	text, err := g.Ask(context.Background(), ModelHaiku45, "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "[mock] Hello" || len(mock.Calls()) != 2 {
+		t.Errorf("expected retried success, got %q after %d calls", text, len(mock.Calls()))
+	}
+}
+
+func TestAnthropicBackend_RateLimitIsThrottled(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"12"}},
+			Body:       io.NopCloser(strings.NewReader(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)),
+		}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+	_, err := b.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	ge, ok := IsGovernorError(err)
+	if !ok || !ge.IsThrottled() {
+		t.Fatalf("expected throttled GovernorError, got %v", err)
+	}
+	if ge.RetryAfterSec != 12 || ge.Msg != "slow down" {
+		t.Errorf("unexpected error fields: %+v", ge)
+	}
+	if !IsRetryable(err) {
+		t.Error("expected rate limit to be retryable")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("5"); got != 5 {
+		t.Errorf("expected 5, got %d", got)
+	}
+	if got := parseRetryAfter(""); got != 0 {
+		t.Errorf("expected 0, got %d", got)
+	}
+	date := time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 88 || got > 91 {
+		t.Errorf("expected ~90 for HTTP date, got %d", got)
+	}
+}
@@ -0,0 +1,338 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"sync"
+)
+
+// RouteMatcher decides whether a route applies to a request.
+type RouteMatcher func(req *InvokeRequest) bool
+
+// MatchModel matches requests whose model ID matches pattern, using
+// path.Match syntax, e.g. "*haiku*".
+func MatchModel(pattern string) RouteMatcher {
+	return func(req *InvokeRequest) bool {
+		ok, _ := path.Match(pattern, req.Model)
+		return ok
+	}
+}
+
+// MatchDocuments matches requests containing efs_document blocks.
+func MatchDocuments() RouteMatcher {
+	return func(req *InvokeRequest) bool {
+		for _, m := range req.Messages {
+			for _, b := range m.Content {
+				if b.Type == "efs_document" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// MatchMinInputTokens matches requests whose estimated input is at least
+// n tokens. The estimate is the same heuristic used by EstimateCost.
+func MatchMinInputTokens(n int64) RouteMatcher {
+	return func(req *InvokeRequest) bool {
+		return estimateInputTokens(req) >= n
+	}
+}
+
+// MatchMaxInputTokens matches requests whose estimated input is at most
+// n tokens.
+func MatchMaxInputTokens(n int64) RouteMatcher {
+	return func(req *InvokeRequest) bool {
+		return estimateInputTokens(req) <= n
+	}
+}
+
+// MatchTag matches requests whose Tags map key to value.
+func MatchTag(key, value string) RouteMatcher {
+	return func(req *InvokeRequest) bool {
+		v, ok := req.Tags[key]
+		return ok && v == value
+	}
+}
+
+// route is a backend and the matchers a request must satisfy to use it.
+type route struct {
+	backend  Backend
+	matchers []RouteMatcher
+}
+
+func (r route) matches(req *InvokeRequest) bool {
+	for _, m := range r.matchers {
+		if !m(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// RouterBackend dispatches each request to one of several backends. Routes
+// are tried in the order they were added and the first whose matchers all
+// match the request is used; requests no route matches go to the default
+// backend.
+//
+//	router := llm.NewRouterBackend(
+//		llm.WithRoute(lambdaBackend, llm.MatchDocuments()),
+//		llm.WithRoute(anthropicBackend, llm.MatchModel("*haiku*"), llm.MatchMaxInputTokens(2000)),
+//		llm.WithDefaultRoute(lambdaBackend),
+//	)
+//	gov := llm.NewGovernor(llm.WithBackend(router))
+//
+// CheckBudget reports the tightest budget of any backend, and ListModels
+// lists the models of every backend.
+type RouterBackend struct {
+	routes   []route
+	fallback Backend
+
+	mu      sync.Mutex
+	batches map[string]BatchBackend
+}
+
+// RouterOption configures a RouterBackend.
+type RouterOption func(*RouterBackend)
+
+// WithRoute sends requests matching all of matchers to backend. A route
+// without matchers matches every request.
+func WithRoute(backend Backend, matchers ...RouteMatcher) RouterOption {
+	return func(r *RouterBackend) {
+		r.routes = append(r.routes, route{backend: backend, matchers: matchers})
+	}
+}
+
+// WithDefaultRoute sends requests that no route matches to backend.
+// Without a default, such requests fail.
+func WithDefaultRoute(backend Backend) RouterOption {
+	return func(r *RouterBackend) {
+		r.fallback = backend
+	}
+}
+
+// NewRouterBackend creates a router with the given routes.
+func NewRouterBackend(opts ...RouterOption) *RouterBackend {
+	r := &RouterBackend{batches: map[string]BatchBackend{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Route returns the backend that serves req.
+func (r *RouterBackend) Route(req *InvokeRequest) (Backend, error) {
+	for _, rt := range r.routes {
+		if rt.matches(req) {
+			return rt.backend, nil
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, fmt.Errorf("llm: no route for request to model %s", req.Model)
+}
+
+// backends returns each distinct backend once, in route order.
+func (r *RouterBackend) backends() []Backend {
+	var out []Backend
+	seen := map[Backend]bool{}
+	for _, rt := range r.routes {
+		if !seen[rt.backend] {
+			seen[rt.backend] = true
+			out = append(out, rt.backend)
+		}
+	}
+	if r.fallback != nil && !seen[r.fallback] {
+		out = append(out, r.fallback)
+	}
+	return out
+}
+
+func (r *RouterBackend) Invoke(ctx context.Context, req *InvokeRequest) (*InvokeResponse, error) {
+	b, err := r.Route(req)
+	if err != nil {
+		return nil, err
+	}
+	return b.Invoke(ctx, req)
+}
+
+func (r *RouterBackend) InvokeStream(ctx context.Context, req *InvokeRequest) (*Stream, error) {
+	b, err := r.Route(req)
+	if err != nil {
+		return nil, err
+	}
+	return b.InvokeStream(ctx, req)
+}
+
+func (r *RouterBackend) CountTokens(ctx context.Context, req *InvokeRequest) (*CountTokensResponse, error) {
+	b, err := r.Route(req)
+	if err != nil {
+		return nil, err
+	}
+	return b.CountTokens(ctx, req)
+}
+
+// CheckBudget reports the tightest budget of any backend, since a request
+// may be routed to whichever backend has the least left. The period fields
+// come from the backend with the least period budget remaining and the
+// execution fields from the one with the least execution budget remaining.
+// Backends without a budget are skipped; if none has one, the budget is
+// unlimited.
+func (r *RouterBackend) CheckBudget(ctx context.Context, executionRunID string) (*CheckBudgetResponse, error) {
+	tightest := &CheckBudgetResponse{PeriodRemainingUsd: math.Inf(1)}
+	hasPeriod, hasExecution := false, false
+	for _, b := range r.backends() {
+		resp, err := b.CheckBudget(ctx, executionRunID)
+		if err != nil {
+			return nil, err
+		}
+		if resp.PeriodBudgetUsd > 0 && !math.IsInf(resp.PeriodRemainingUsd, 1) &&
+			(!hasPeriod || resp.PeriodRemainingUsd < tightest.PeriodRemainingUsd) {
+			hasPeriod = true
+			tightest.BudgetPeriod = resp.BudgetPeriod
+			tightest.PeriodBudgetUsd = resp.PeriodBudgetUsd
+			tightest.PeriodUsedUsd = resp.PeriodUsedUsd
+			tightest.PeriodRemainingUsd = resp.PeriodRemainingUsd
+		}
+		if resp.ExecutionBudgetUsd > 0 &&
+			(!hasExecution || resp.ExecutionRemainingUsd < tightest.ExecutionRemainingUsd) {
+			hasExecution = true
+			tightest.ExecutionBudgetUsd = resp.ExecutionBudgetUsd
+			tightest.ExecutionUsedUsd = resp.ExecutionUsedUsd
+			tightest.ExecutionRemainingUsd = resp.ExecutionRemainingUsd
+		}
+	}
+	return tightest, nil
+}
+
+// ListModels lists each model once, in the order first seen. A model is
+// reported available if any backend has it available.
+func (r *RouterBackend) ListModels(ctx context.Context) (*ListModelsResponse, error) {
+	var models []ModelInfo
+	index := map[string]int{}
+	for _, b := range r.backends() {
+		resp, err := b.ListModels(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range resp.Models {
+			i, ok := index[m.ModelID]
+			switch {
+			case !ok:
+				index[m.ModelID] = len(models)
+				models = append(models, m)
+			case models[i].Status != "available" && m.Status == "available":
+				models[i] = m
+			}
+		}
+	}
+	return &ListModelsResponse{Models: models}, nil
+}
+
+// SubmitBatch submits the batch to the backend its requests route to. All
+// requests in a batch must route to the same backend.
+func (r *RouterBackend) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	if err := requireBatchRequests(requests); err != nil {
+		return nil, err
+	}
+	var target Backend
+	for _, br := range requests {
+		b, err := r.Route(br.Request)
+		if err != nil {
+			return nil, err
+		}
+		if target != nil && b != target {
+			return nil, fmt.Errorf("llm: batch request %q routes to a different backend than the rest of the batch", br.CustomID)
+		}
+		target = b
+	}
+	if target == nil {
+		return nil, fmt.Errorf("llm: no route for an empty batch")
+	}
+
+	bb, err := batchBackend(target)
+	if err != nil {
+		return nil, err
+	}
+	batch, err := bb.SubmitBatch(ctx, requests)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.batches[batch.ID] = bb
+	r.mu.Unlock()
+	return batch, nil
+}
+
+func (r *RouterBackend) BatchStatus(ctx context.Context, batchID string) (*Batch, error) {
+	var batch *Batch
+	err := r.batchCall(batchID, func(b BatchBackend) error {
+		var err error
+		batch, err = b.BatchStatus(ctx, batchID)
+		return err
+	})
+	return batch, err
+}
+
+// BatchResults reads the results from the backend the batch was submitted
+// to. Once every result has been read, the router forgets the batch.
+func (r *RouterBackend) BatchResults(ctx context.Context, batchID string) (*BatchResults, error) {
+	var inner *BatchResults
+	err := r.batchCall(batchID, func(b BatchBackend) error {
+		var err error
+		inner, err = b.BatchResults(ctx, batchID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newBatchResults(ctx, func(_ context.Context, emit func(BatchResult) error) error {
+		defer inner.Close()
+		for inner.Next() {
+			if err := emit(inner.cur); err != nil {
+				return err
+			}
+		}
+		if err := inner.Err(); err != nil {
+			return err
+		}
+		r.mu.Lock()
+		delete(r.batches, batchID)
+		r.mu.Unlock()
+		return nil
+	}), nil
+}
+
+// batchCall calls the backend a batch was submitted to. Batches submitted
+// by another router are looked up on each batch backend in turn until one
+// does not report not_found.
+func (r *RouterBackend) batchCall(batchID string, call func(BatchBackend) error) error {
+	r.mu.Lock()
+	b, ok := r.batches[batchID]
+	r.mu.Unlock()
+	if ok {
+		return call(b)
+	}
+
+	err := error(&GovernorError{Code: "not_found", Msg: fmt.Sprintf("batch %s not found", batchID)})
+	for _, backend := range r.backends() {
+		b, ok := backend.(BatchBackend)
+		if !ok {
+			continue
+		}
+		if err = call(b); err == nil {
+			r.mu.Lock()
+			r.batches[batchID] = b
+			r.mu.Unlock()
+			return nil
+		}
+		if ge, ok := IsGovernorError(err); !ok || !ge.IsNotFound() {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestRouterBackend_Routes(t *testing.T) {
+	docs, haiku, tagged, big, fallback := NewMockBackend(), NewMockBackend(), NewMockBackend(), NewMockBackend(), NewMockBackend()
+	router := NewRouterBackend(
+		WithRoute(docs, MatchDocuments()),
+		WithRoute(tagged, MatchTag("tier", "bulk")),
+		WithRoute(big, MatchMinInputTokens(1000)),
+		WithRoute(haiku, MatchModel("*haiku*"), MatchMaxInputTokens(1000)),
+		WithDefaultRoute(fallback),
+	)
+	g := NewGovernor(WithBackend(router))
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		req  *InvokeRequest
+		want *MockBackend
+	}{
+		{"documents", &InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("read"), FileBlock("a.pdf"))}}, docs},
+		{"tag", &InvokeRequest{Model: ModelSonnet46, Messages: []Message{UserMessage(TextBlock("hi"))}, Tags: map[string]string{"tier": "bulk"}}, tagged},
+		{"size", &InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock(strings.Repeat("x", 8000)))}}, big},
+		{"model", &InvokeRequest{Model: ModelHaiku45, Messages: []Message{UserMessage(TextBlock("hi"))}}, haiku},
+		{"default", &InvokeRequest{Model: ModelSonnet46, Messages: []Message{UserMessage(TextBlock("hi"))}}, fallback},
+	}
+	for _, c := range cases {
+		before := len(c.want.Calls())
+		if _, err := g.Invoke(ctx, c.req); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(c.want.Calls()) != before+1 {
+			t.Errorf("%s: request was not routed to the expected backend", c.name)
+		}
+	}
+	for _, b := range []*MockBackend{docs, haiku, tagged, big, fallback} {
+		if n := len(b.Calls()); n != 1 {
+			t.Errorf("expected each backend to serve one request, one served %d", n)
+		}
+	}
+}
+
+func TestRouterBackend_NoRoute(t *testing.T) {
+	router := NewRouterBackend(WithRoute(NewMockBackend(), MatchModel("*haiku*")))
+	_, err := router.Invoke(context.Background(), &InvokeRequest{Model: ModelSonnet46})
+	if err == nil || !strings.Contains(err.Error(), "no route") {
+		t.Errorf("expected a no route error, got %v", err)
+	}
+}
+
+func TestRouterBackend_Aggregates(t *testing.T) {
+	mock := NewMockBackend()
+	ledger := NewBudgetLedger(NewMockBackend(), WithLedgerPeriodBudget("daily", 5))
+	router := NewRouterBackend(
+		WithRoute(mock, MatchModel("*haiku*")),
+		WithRoute(ledger, MatchDocuments()),
+		WithDefaultRoute(mock),
+	)
+	ctx := context.Background()
+
+	budget, err := router.CheckBudget(ctx, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget.BudgetPeriod != "daily" || budget.PeriodBudgetUsd != 5 || math.Abs(budget.PeriodRemainingUsd-5) > 1e-9 {
+		t.Errorf("expected the ledger's tighter period budget, got %+v", budget)
+	}
+	if budget.ExecutionBudgetUsd != 10 || budget.ExecutionRemainingUsd != 10 {
+		t.Errorf("expected the mock's execution budget, got %+v", budget)
+	}
+
+	models, err := router.ListModels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models.Models) != len(Models()) {
+		t.Errorf("expected each model once, got %d models", len(models.Models))
+	}
+}
+
+func TestRouterBackend_Batches(t *testing.T) {
+	haiku, other := NewMockBackend(), NewMockBackend()
+	router := NewRouterBackend(WithRoute(haiku, MatchModel("*haiku*")), WithDefaultRoute(other))
+	g := NewGovernor(WithBackend(router))
+	ctx := context.Background()
+
+	batch, err := g.SubmitBatch(ctx, batchOf("a", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.BatchStatus(ctx, batch.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(haiku.Calls()); n != 2 {
+		t.Errorf("expected the batch to run on the haiku backend, got %d calls", n)
+	}
+	results, err := g.BatchResults(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collectBatchResults(t, results); len(got) != 2 {
+		t.Errorf("expected 2 results, got %+v", got)
+	}
+	if len(router.batches) != 0 {
+		t.Errorf("expected the router to forget the read batch, got %v", router.batches)
+	}
+
+	// A fresh router finds the batch by asking each backend.
+	fresh := NewRouterBackend(WithRoute(other), WithRoute(haiku))
+	if _, err := fresh.BatchStatus(ctx, batch.ID); err != nil {
+		t.Errorf("expected the batch to be found, got %v", err)
+	}
+
+	requests := batchOf("c", "d")
+	requests[1].Request.Model = ModelSonnet46
+	if _, err := g.SubmitBatch(ctx, requests); err == nil {
+		t.Error("expected an error for a batch spanning backends")
+	}
+}
+
+func TestRouterBackend_CheckBudgetUnlimited(t *testing.T) {
+	ledger := NewBudgetLedger(NewMockBackend())
+	router := NewRouterBackend(
+		WithRoute(ledger, MatchModel("*haiku*")),
+		WithDefaultRoute(ledger),
+	)
+	budget, err := router.CheckBudget(context.Background(), "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(budget.PeriodRemainingUsd, 1) || budget.PeriodBudgetUsd != 0 || budget.ExecutionBudgetUsd != 0 {
+		t.Errorf("expected an unlimited budget, got %+v", budget)
+	}
+}
@@ -0,0 +1,264 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// SchemaFor returns a JSON schema object describing T, derived from its
+// encoding/json field names.
+//
+// Struct fields without omitempty are required. A `description:"..."` tag
+// adds a field description and an `enum:"a,b,c"` tag restricts a string
+// field to the listed values. Interface and json.RawMessage fields accept
+// any value.
+func SchemaFor[T any]() map[string]interface{} {
+	return schemaForType(reflect.TypeOf((*T)(nil)).Elem(), map[reflect.Type]bool{})
+}
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json marshals []byte as a base64 string, but byte
+			// arrays as arrays of numbers.
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaForType(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			// Recursive type: accept any object rather than recursing forever.
+			return map[string]interface{}{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		addStructFields(t, properties, &required, visiting)
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// addStructFields adds the JSON-visible fields of t to properties,
+// flattening embedded structs the way encoding/json does.
+func addStructFields(t reflect.Type, properties map[string]interface{}, required *[]string, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructFields(ft, properties, required, visiting)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := schemaForType(f.Type, visiting)
+		if desc := f.Tag.Get("description"); desc != "" {
+			prop["description"] = desc
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			prop["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// validateSchema checks value, as decoded by encoding/json into
+// interface{}, against schema. It supports the subset of JSON schema
+// produced by SchemaFor: type, properties, required, additionalProperties,
+// items and enum. Each problem is reported with the path of the offending
+// value, e.g. "authors[1].name".
+func validateSchema(value interface{}, schema map[string]interface{}) []string {
+	var problems []string
+	validateValue(value, schema, "", &problems)
+	return problems
+}
+
+func validateValue(value interface{}, schema map[string]interface{}, path string, problems *[]string) {
+	report := func(format string, args ...interface{}) {
+		where := path
+		if where == "" {
+			where = "value"
+		}
+		*problems = append(*problems, where+": "+fmt.Sprintf(format, args...))
+	}
+
+	if enum := stringList(schema["enum"]); enum != nil {
+		s, ok := value.(string)
+		if !ok || !slices.Contains(enum, s) {
+			report("must be one of %s", strings.Join(enum, ", "))
+			return
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "string":
+		if _, ok := value.(string); !ok {
+			report("expected string, got %s", jsonTypeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report("expected boolean, got %s", jsonTypeName(value))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			report("expected number, got %s", jsonTypeName(value))
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			report("expected integer, got %s", jsonTypeName(value))
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			report("expected array, got %s", jsonTypeName(value))
+			return
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range arr {
+				validateValue(item, items, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			report("expected object, got %s", jsonTypeName(value))
+			return
+		}
+		required := stringList(schema["required"])
+		for _, name := range required {
+			if _, ok := obj[name]; !ok {
+				report("missing required field %q", name)
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if obj[k] == nil && !slices.Contains(required, k) {
+				// Optional fields may be sent as null.
+				continue
+			}
+			child := joinPath(path, k)
+			if prop, ok := props[k].(map[string]interface{}); ok {
+				validateValue(obj[k], prop, child, problems)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					report("unexpected field %q", k)
+				}
+			case map[string]interface{}:
+				validateValue(obj[k], extra, child, problems)
+			}
+		}
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// stringList reads a schema keyword holding a list of strings, whether it
+// was built in Go ([]string) or decoded from JSON ([]interface{}).
+func stringList(v interface{}) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaNode struct {
+	Name     string        `json:"name"`
+	Children []*schemaNode `json:"children,omitempty"`
+}
+
+type schemaEmbedded struct {
+	ID int `json:"id"`
+}
+
+type schemaSample struct {
+	schemaEmbedded
+	Title   string         `json:"title" description:"Document title"`
+	Kind    string         `json:"kind" enum:"paper,poster"`
+	Score   float64        `json:"score,omitempty"`
+	Tags    []string       `json:"tags"`
+	Meta    map[string]int `json:"meta,omitempty"`
+	When    time.Time      `json:"when"`
+	Data    []byte         `json:"data,omitempty"`
+	Digest  [4]byte        `json:"digest"`
+	Tree    *schemaNode    `json:"tree,omitempty"`
+	Skipped string         `json:"-"`
+	Any     interface{}    `json:"any,omitempty"`
+	hidden  string
+}
+
+func TestSchemaFor_Struct(t *testing.T) {
+	s := SchemaFor[schemaSample]()
+	if s["type"] != "object" || s["additionalProperties"] != false {
+		t.Fatalf("unexpected root schema: %v", s)
+	}
+	props := s["properties"].(map[string]interface{})
+
+	wantTypes := map[string]string{
+		"id": "integer", "title": "string", "kind": "string", "score": "number",
+		"tags": "array", "meta": "object", "when": "string", "tree": "object",
+		"data": "string", "digest": "array",
+	}
+	for name, typ := range wantTypes {
+		prop, ok := props[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("missing property %q", name)
+			continue
+		}
+		if prop["type"] != typ {
+			t.Errorf("property %q: expected type %q, got %v", name, typ, prop["type"])
+		}
+	}
+	for _, name := range []string{"Skipped", "hidden"} {
+		if _, ok := props[name]; ok {
+			t.Errorf("unexpected property %q", name)
+		}
+	}
+
+	title := props["title"].(map[string]interface{})
+	if title["description"] != "Document title" {
+		t.Errorf("expected description, got %v", title["description"])
+	}
+	kind := props["kind"].(map[string]interface{})
+	if !reflect.DeepEqual(kind["enum"], []string{"paper", "poster"}) {
+		t.Errorf("expected enum, got %v", kind["enum"])
+	}
+
+	required := s["required"].([]string)
+	want := []string{"id", "title", "kind", "tags", "when", "digest"}
+	if !reflect.DeepEqual(required, want) {
+		t.Errorf("expected required %v, got %v", want, required)
+	}
+
+	digest := props["digest"].(map[string]interface{})
+	if items := digest["items"].(map[string]interface{}); items["type"] != "integer" {
+		t.Errorf("expected a byte array to hold integers, got %v", digest)
+	}
+}
+
+func TestSchemaFor_RecursiveType(t *testing.T) {
+	s := SchemaFor[schemaNode]()
+	children := s["properties"].(map[string]interface{})["children"].(map[string]interface{})
+	items := children["items"].(map[string]interface{})
+	if items["type"] != "object" {
+		t.Errorf("expected recursive items to be an object, got %v", items)
+	}
+	if _, ok := items["properties"]; ok {
+		t.Error("expected recursion to stop at the repeated type")
+	}
+}
@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// StreamEvent is a single incremental update from a streaming invocation.
+type StreamEvent struct {
+	// Type is "text_delta" for an incremental piece of text, "tool_use"
+	// for a complete tool call, "thinking_delta", "signature_delta" or
+	// "redacted_thinking" for extended thinking, or "message_stop" once the
+	// response is complete.
+	Type string `json:"type"`
+
+	// Text delta (for type "text_delta" or "thinking_delta").
+	Text string `json:"text,omitempty"`
+
+	// Thinking signature (for type "signature_delta") or encrypted
+	// thinking (for type "redacted_thinking").
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// Tool call ID, tool name and JSON input (for type "tool_use").
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// Model, stop reason and final usage (for type "message_stop").
+	// FallbackUsed and FallbackModel are set when a fallback model
+	// answered, as in InvokeResponse.
+	Model         string     `json:"model,omitempty"`
+	StopReason    string     `json:"stopReason,omitempty"`
+	Usage         *UsageInfo `json:"usage,omitempty"`
+	FallbackUsed  bool       `json:"fallbackUsed,omitempty"`
+	FallbackModel string     `json:"fallbackModel,omitempty"`
+}
+
+// Stream is an in-progress streaming response.
+//
+// Call Next until it returns false, then check Err. Response returns the
+// response accumulated from the events read so far. Close must be called if
+// the stream is abandoned before it is fully consumed.
+//
+//	stream, err := gov.AskStream(ctx, llm.ModelSonnet46, "Summarize ...")
+//	if err != nil { ... }
+//	defer stream.Close()
+//	for stream.Next() {
+//		fmt.Print(stream.Event().Text)
+//	}
+//	if err := stream.Err(); err != nil { ... }
+type Stream struct {
+	events chan StreamEvent
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	prodErr error
+
+	cur  StreamEvent
+	err  error
+	resp InvokeResponse
+}
+
+// newStream starts produce in a goroutine and returns a Stream reading the
+// events it emits. emit blocks until the consumer reads the event and
+// returns an error once the stream has been closed or ctx is cancelled.
+func newStream(ctx context.Context, produce func(ctx context.Context, emit func(StreamEvent) error) error) *Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{
+		events: make(chan StreamEvent),
+		cancel: cancel,
+	}
+
+	emit := func(ev StreamEvent) error {
+		select {
+		case s.events <- ev:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(s.events)
+		err := produce(ctx, emit)
+		s.mu.Lock()
+		s.prodErr = err
+		s.mu.Unlock()
+	}()
+
+	return s
+}
+
+// Next advances to the next event. It returns false when the stream is
+// finished or an error occurred.
+func (s *Stream) Next() bool {
+	ev, ok := <-s.events
+	if !ok {
+		s.mu.Lock()
+		s.err = s.prodErr
+		s.mu.Unlock()
+		s.cancel()
+		return false
+	}
+	s.cur = ev
+	s.accumulate(ev)
+	return true
+}
+
+// Event returns the event read by the last call to Next.
+func (s *Stream) Event() StreamEvent {
+	return s.cur
+}
+
+// Err returns the error that ended the stream, if any. It is only
+// meaningful after Next has returned false.
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// Response returns the response accumulated from the events read so far.
+// After Next returns false it holds the complete text, stop reason and usage.
+func (s *Stream) Response() *InvokeResponse {
+	return &s.resp
+}
+
+// Close stops the stream and releases its resources. It is safe to call
+// Close after the stream has been fully consumed.
+func (s *Stream) Close() error {
+	s.cancel()
+	for range s.events {
+	}
+	return nil
+}
+
+// accumulate folds an event into the buffered response.
+func (s *Stream) accumulate(ev StreamEvent) {
+	switch ev.Type {
+	case "text_delta":
+		s.appendText("text", ev.Text)
+	case "thinking_delta":
+		s.appendText("thinking", ev.Text)
+	case "signature_delta":
+		if n := len(s.resp.Content); n > 0 && s.resp.Content[n-1].Type == "thinking" {
+			s.resp.Content[n-1].Signature += ev.Signature
+		}
+	case "redacted_thinking":
+		s.resp.Content = append(s.resp.Content, ResponseContent{Type: "redacted_thinking", Data: ev.Data})
+	case "tool_use":
+		s.resp.Content = append(s.resp.Content, ResponseContent{Type: "tool_use", ID: ev.ID, Name: ev.Name, Input: ev.Input})
+	case "message_stop":
+		if ev.Model != "" {
+			s.resp.Model = ev.Model
+		}
+		s.resp.StopReason = ev.StopReason
+		s.resp.FallbackUsed = ev.FallbackUsed
+		s.resp.FallbackModel = ev.FallbackModel
+		if ev.Usage != nil {
+			s.resp.Usage = *ev.Usage
+		}
+	}
+}
+
+// appendText extends the last content block if it has the given type, or
+// starts a new one.
+func (s *Stream) appendText(typ, text string) {
+	n := len(s.resp.Content)
+	if n > 0 && s.resp.Content[n-1].Type == typ {
+		s.resp.Content[n-1].Text += text
+		return
+	}
+	s.resp.Content = append(s.resp.Content, ResponseContent{Type: typ, Text: text})
+}
+
+// streamResponse emits a buffered response as a sequence of text and
+// thinking deltas of at most chunkSize runes and tool calls, followed by a
+// message_stop event.
+func streamResponse(resp *InvokeResponse, chunkSize int, emit func(StreamEvent) error) error {
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+	emitChunks := func(typ, text string) error {
+		runes := []rune(text)
+		for start := 0; start < len(runes); start += chunkSize {
+			end := min(start+chunkSize, len(runes))
+			if err := emit(StreamEvent{Type: typ, Text: string(runes[start:end])}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, c := range resp.Content {
+		switch c.Type {
+		case "text":
+			if err := emitChunks("text_delta", c.Text); err != nil {
+				return err
+			}
+		case "thinking":
+			if err := emitChunks("thinking_delta", c.Text); err != nil {
+				return err
+			}
+			if err := emit(StreamEvent{Type: "signature_delta", Signature: c.Signature}); err != nil {
+				return err
+			}
+		case "redacted_thinking":
+			if err := emit(StreamEvent{Type: "redacted_thinking", Data: c.Data}); err != nil {
+				return err
+			}
+		case "tool_use":
+			if err := emit(StreamEvent{Type: "tool_use", ID: c.ID, Name: c.Name, Input: c.Input}); err != nil {
+				return err
+			}
+		}
+	}
+	usage := resp.Usage
+	return emit(StreamEvent{
+		Type:       "message_stop",
+		Model:      resp.Model,
+		StopReason: resp.StopReason,
+		Usage:      &usage,
+	})
+}
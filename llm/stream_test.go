@@ -0,0 +1,310 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper for test clients.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func collectStream(t *testing.T, s *Stream) []StreamEvent {
+	t.Helper()
+	defer s.Close()
+	var events []StreamEvent
+	for s.Next() {
+		events = append(events, s.Event())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	return events
+}
+
+func TestMockBackend_InvokeStreamChunks(t *testing.T) {
+	b := NewMockBackend()
+	b.SetStreamChunkSize(4)
+	b.SetResponse(&InvokeResponse{
+		Content:    []ResponseContent{{Type: "text", Text: "Hello, world"}},
+		Model:      ModelHaiku45,
+		StopReason: "end_turn",
+		Usage:      UsageInfo{InputTokens: 5, OutputTokens: 3},
+	})
+
+	s, err := b.InvokeStream(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := collectStream(t, s)
+
+	if len(events) != 4 {
+		t.Fatalf("expected 3 deltas and a stop event, got %d events", len(events))
+	}
+	if events[0].Type != "text_delta" || events[0].Text != "Hell" {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	stop := events[3]
+	if stop.Type != "message_stop" || stop.StopReason != "end_turn" {
+		t.Errorf("unexpected stop event: %+v", stop)
+	}
+
+	resp := s.Response()
+	if resp.Text() != "Hello, world" {
+		t.Errorf("expected accumulated text 'Hello, world', got %q", resp.Text())
+	}
+	if resp.Usage.OutputTokens != 3 {
+		t.Errorf("expected 3 output tokens, got %d", resp.Usage.OutputTokens)
+	}
+	if resp.Model != ModelHaiku45 {
+		t.Errorf("expected model %q, got %q", ModelHaiku45, resp.Model)
+	}
+}
+
+func TestGovernor_AskStream(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock), WithExecutionRunID("run-1"))
+
+	s, err := g.AskWithSystemStream(context.Background(), ModelHaiku45, "be brief", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectStream(t, s)
+	if s.Response().Text() != "[mock] Hello" {
+		t.Errorf("expected '[mock] Hello', got %q", s.Response().Text())
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(calls))
+	}
+	if calls[0].Action != "invoke-stream" {
+		t.Errorf("expected action 'invoke-stream', got %q", calls[0].Action)
+	}
+	if calls[0].ExecutionRunID != "run-1" || calls[0].System != "be brief" {
+		t.Errorf("unexpected request: %+v", calls[0])
+	}
+}
+
+func TestStream_CloseBeforeDrained(t *testing.T) {
+	b := NewMockBackend()
+	b.SetStreamChunkSize(1)
+	b.SetResponse(&InvokeResponse{Content: []ResponseContent{{Type: "text", Text: strings.Repeat("x", 100)}}})
+
+	s, err := b.InvokeStream(context.Background(), &InvokeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Next() {
+		t.Fatal("expected at least one event")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+	if s.Next() {
+		t.Error("expected no events after Close")
+	}
+}
+
+const anthropicSSE = `event: message_start
+data: {"type":"message_start","message":{"model":"claude-haiku-4-5-20251001","usage":{"input_tokens":12}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" there"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestAnthropicBackend_InvokeStream(t *testing.T) {
+	var gotBody string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		gotBody = string(body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(anthropicSSE)),
+		}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+	s, err := b.InvokeStream(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := collectStream(t, s)
+
+	if !strings.Contains(gotBody, `"stream":true`) {
+		t.Errorf("expected stream flag in request body, got %s", gotBody)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	resp := s.Response()
+	if resp.Text() != "Hello there" {
+		t.Errorf("expected 'Hello there', got %q", resp.Text())
+	}
+	if resp.StopReason != "end_turn" {
+		t.Errorf("expected stop reason 'end_turn', got %q", resp.StopReason)
+	}
+	if resp.Usage.InputTokens != 12 || resp.Usage.OutputTokens != 7 {
+		t.Errorf("unexpected usage: %+v", resp.Usage)
+	}
+	if resp.Model != "claude-haiku-4-5-20251001" {
+		t.Errorf("unexpected model %q", resp.Model)
+	}
+}
+
+func TestAnthropicBackend_CloseStalledStream(t *testing.T) {
+	body, w := io.Pipe()
+	defer w.Close()
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		go io.WriteString(w, "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hel\"}}\n\n")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       body,
+		}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+	s, err := b.InvokeStream(context.Background(), &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("hi"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Next() {
+		t.Fatalf("expected a first event, got error %v", s.Err())
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		s.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked on a stalled connection")
+	}
+}
+
+func TestReadAnthropicStream_ErrorEvent(t *testing.T) {
+	body := "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+	err := readAnthropicStream(strings.NewReader(body), ModelHaiku45, func(StreamEvent) error { return nil })
+	ge, ok := IsGovernorError(err)
+	if !ok || !ge.IsOverloaded() {
+		t.Errorf("expected overloaded GovernorError, got %v", err)
+	}
+}
+
+func TestReadAnthropicStream_Truncated(t *testing.T) {
+	body := "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"a\"}}\n"
+	err := readAnthropicStream(strings.NewReader(body), ModelHaiku45, func(StreamEvent) error { return nil })
+	if err == nil {
+		t.Error("expected error for stream without message_stop")
+	}
+}
+
+func lambdaChunk(s string) types.InvokeWithResponseStreamResponseEvent {
+	return &types.InvokeWithResponseStreamResponseEventMemberPayloadChunk{
+		Value: types.InvokeResponseStreamUpdate{Payload: []byte(s)},
+	}
+}
+
+func TestReadLambdaStream_SplitChunks(t *testing.T) {
+	events := make(chan types.InvokeWithResponseStreamResponseEvent, 4)
+	events <- lambdaChunk(`{"type":"text_delta","text":"Hel`)
+	events <- lambdaChunk("lo\"}\n{\"type\":\"text_delta\",\"text\":\" world\"}\n")
+	events <- lambdaChunk(`{"type":"message_stop","stopReason":"end_turn","usage":{"inputTokens":3,"outputTokens":2,"estimatedCostUsd":0.01}}`)
+	events <- &types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{}
+	close(events)
+
+	var got []StreamEvent
+	err := readLambdaStream(events, func(ev StreamEvent) error {
+		got = append(got, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(got))
+	}
+	if got[0].Text != "Hello" || got[1].Text != " world" {
+		t.Errorf("unexpected deltas: %+v", got[:2])
+	}
+	if got[2].Usage == nil || got[2].Usage.EstimatedCostUsd != 0.01 {
+		t.Errorf("unexpected stop event: %+v", got[2])
+	}
+}
+
+func TestReadLambdaStream_GovernorError(t *testing.T) {
+	events := make(chan types.InvokeWithResponseStreamResponseEvent, 1)
+	events <- lambdaChunk(`{"error":"budget_exceeded","message":"no budget left"}` + "\n")
+	close(events)
+
+	err := readLambdaStream(events, func(StreamEvent) error { return nil })
+	ge, ok := IsGovernorError(err)
+	if !ok {
+		t.Fatalf("expected GovernorError, got %v", err)
+	}
+	if !ge.IsBudgetExceeded() {
+		t.Errorf("expected budget_exceeded, got %q", ge.Code)
+	}
+}
+
+func TestReadLambdaStream_InvokeCompleteError(t *testing.T) {
+	events := make(chan types.InvokeWithResponseStreamResponseEvent, 1)
+	events <- &types.InvokeWithResponseStreamResponseEventMemberInvokeComplete{
+		Value: types.InvokeWithResponseStreamCompleteEvent{
+			ErrorCode:    aws.String("Runtime.ExitError"),
+			ErrorDetails: aws.String("process exited"),
+		},
+	}
+	close(events)
+
+	err := readLambdaStream(events, func(StreamEvent) error { return nil })
+	fe, ok := IsLambdaFunctionError(err)
+	if !ok {
+		t.Fatalf("expected LambdaFunctionError, got %v", err)
+	}
+	if fe.ErrorType != "Runtime.ExitError" || fe.ErrorMessage != "process exited" || !fe.Transient() {
+		t.Errorf("unexpected function error: %+v", fe)
+	}
+}
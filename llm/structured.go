@@ -0,0 +1,212 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONValidationError is returned by AskJSON and InvokeJSON when the
+// model's reply still does not match the schema after all retries.
+type JSONValidationError struct {
+	// Raw is the model's last reply, as text or tool input.
+	Raw string
+
+	// Problems lists every validation failure in the last reply.
+	Problems []string
+
+	// Attempts is the number of model invocations made.
+	Attempts int
+}
+
+func (e *JSONValidationError) Error() string {
+	return fmt.Sprintf("model reply did not match schema after %d attempts: %s",
+		e.Attempts, strings.Join(e.Problems, "; "))
+}
+
+// JSONOption configures AskJSON and InvokeJSON.
+type JSONOption func(*jsonConfig)
+
+type jsonConfig struct {
+	retries  int
+	toolMode bool
+	schema   map[string]interface{}
+}
+
+// WithJSONRetries sets how many times the model is re-prompted with the
+// validation errors after an invalid reply. The default is 2.
+func WithJSONRetries(n int) JSONOption {
+	return func(c *jsonConfig) {
+		c.retries = n
+	}
+}
+
+// WithJSONToolMode asks for the result through a forced tool call instead
+// of a system prompt instruction. Tool input must be a JSON object, so the
+// result type must be a struct or map; InvokeJSON returns an
+// ErrInvalidRequest error for other types before sending anything.
+func WithJSONToolMode() JSONOption {
+	return func(c *jsonConfig) {
+		c.toolMode = true
+	}
+}
+
+// WithJSONSchema overrides the schema derived from the result type.
+func WithJSONSchema(schema map[string]interface{}) JSONOption {
+	return func(c *jsonConfig) {
+		c.schema = schema
+	}
+}
+
+// jsonToolName is the forced tool used by WithJSONToolMode.
+const jsonToolName = "respond"
+
+// AskJSON sends a prompt and decodes the model's reply into a T. See
+// InvokeJSON.
+func AskJSON[T any](ctx context.Context, g *Governor, model, prompt string, opts ...JSONOption) (T, error) {
+	out, _, err := InvokeJSON[T](ctx, g, &InvokeRequest{
+		Model:    model,
+		Messages: []Message{UserMessage(TextBlock(prompt))},
+	}, opts...)
+	return out, err
+}
+
+// InvokeJSON sends req and decodes the model's reply into a T.
+//
+// The schema of T (see SchemaFor) is added to the system prompt, or sent as
+// a forced tool with WithJSONToolMode. Code fences and surrounding prose
+// are stripped from text replies. If the reply does not match the schema,
+// the model is shown the problems and asked again; once the retries are
+// used up a *JSONValidationError is returned. The last model response is
+// returned alongside the result.
+func InvokeJSON[T any](ctx context.Context, g *Governor, req *InvokeRequest, opts ...JSONOption) (T, *InvokeResponse, error) {
+	var zero T
+	cfg := &jsonConfig{retries: 2}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.schema == nil {
+		cfg.schema = SchemaFor[T]()
+	}
+
+	if cfg.toolMode && cfg.schema["type"] != "object" {
+		return zero, nil, &ValidationError{Problems: []*FieldError{{
+			Path: "tools[" + jsonToolName + "].inputSchema",
+			Msg:  fmt.Sprintf("tool mode needs an object schema, so the result type must be a struct or map, not %T", zero),
+		}}}
+	}
+
+	turn := *req
+	turn.Messages = append([]Message(nil), req.Messages...)
+	if cfg.toolMode {
+		turn.Tools = append(append([]Tool(nil), req.Tools...), Tool{
+			Name:        jsonToolName,
+			Description: "Return the result as structured data.",
+			InputSchema: cfg.schema,
+		})
+		turn.ToolChoice = &ToolChoice{Type: "tool", Name: jsonToolName}
+	} else {
+		schemaJSON, err := json.Marshal(cfg.schema)
+		if err != nil {
+			return zero, nil, fmt.Errorf("failed to marshal schema: %w", err)
+		}
+		instruction := "Respond with only a JSON value that matches this JSON schema, with no prose and no code fences:\n" + string(schemaJSON)
+		if turn.System != "" {
+			turn.System += "\n\n" + instruction
+		} else {
+			turn.System = instruction
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		call := turn
+		resp, err := g.Invoke(ctx, &call)
+		if err != nil {
+			return zero, resp, err
+		}
+
+		raw, toolUseID := jsonReply(resp, cfg.toolMode)
+		out, problems := decodeJSONReply[T](raw, cfg.schema)
+		if len(problems) == 0 {
+			return out, resp, nil
+		}
+		if attempt > cfg.retries {
+			return zero, resp, &JSONValidationError{Raw: raw, Problems: problems, Attempts: attempt}
+		}
+
+		feedback := "Your reply did not match the schema:\n- " + strings.Join(problems, "\n- ")
+		turn.Messages = append(turn.Messages, resp.Message())
+		if toolUseID != "" {
+			turn.Messages = append(turn.Messages, UserMessage(ToolErrorBlock(toolUseID, feedback+"\nCall the tool again with corrected input.")))
+		} else {
+			turn.Messages = append(turn.Messages, UserMessage(TextBlock(feedback+"\nReply again with only the corrected JSON.")))
+		}
+	}
+}
+
+// jsonReply extracts the raw JSON candidate from a response, along with the
+// ID of the tool call it came from in tool mode.
+func jsonReply(resp *InvokeResponse, toolMode bool) (raw, toolUseID string) {
+	if toolMode {
+		for _, call := range resp.ToolCalls() {
+			if call.Name == jsonToolName {
+				return string(call.Input), call.ID
+			}
+		}
+		return resp.Text(), ""
+	}
+	return extractJSON(resp.Text()), ""
+}
+
+// decodeJSONReply validates raw against schema and decodes it into a T.
+func decodeJSONReply[T any](raw string, schema map[string]interface{}) (T, []string) {
+	var out T
+	if strings.TrimSpace(raw) == "" {
+		return out, []string{"reply contained no JSON"}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return out, []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if problems := validateSchema(value, schema); len(problems) > 0 {
+		return out, problems
+	}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return out, []string{fmt.Sprintf("cannot decode into %T: %v", out, err)}
+	}
+	return out, nil
+}
+
+// extractJSON strips Markdown code fences and surrounding prose from a
+// model reply, returning the outermost JSON object or array.
+func extractJSON(text string) string {
+	text = strings.TrimSpace(text)
+
+	if start := strings.Index(text, "```"); start >= 0 {
+		body := text[start+3:]
+		// Skip the language tag, e.g. ```json.
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			body = body[:end]
+		}
+		text = strings.TrimSpace(body)
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := byte('}')
+	if text[start] == '[' {
+		closing = ']'
+	}
+	end := strings.LastIndexByte(text, closing)
+	if end < start {
+		return text[start:]
+	}
+	return text[start : end+1]
+}
@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type geneList struct {
+	Genes []struct {
+		Symbol string `json:"symbol"`
+		Count  int    `json:"count"`
+	} `json:"genes"`
+	Organism string `json:"organism" enum:"human,mouse"`
+}
+
+func textResponse(text string) *InvokeResponse {
+	return &InvokeResponse{Content: []ResponseContent{{Type: "text", Text: text}}, StopReason: "end_turn"}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"bare", `{"a":1}`, `{"a":1}`},
+		{"fenced", "```json\n{\"a\":1}\n```", `{"a":1}`},
+		{"prose", "Here you go:\n{\"a\":{\"b\":2}}\nHope that helps!", `{"a":{"b":2}}`},
+		{"fenced with prose", "Sure.\n```\n[1, 2]\n```\nDone.", `[1, 2]`},
+		{"array", `Result: [{"a":1}]`, `[{"a":1}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractJSON(tt.in); got != tt.want {
+				t.Errorf("extractJSON(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := SchemaFor[geneList]()
+	var value interface{} = map[string]interface{}{
+		"genes": []interface{}{
+			map[string]interface{}{"symbol": "BRCA1", "count": 2.0},
+			map[string]interface{}{"symbol": 7.0, "count": 1.5, "extra": true},
+		},
+		"organism": "yeast",
+	}
+	problems := validateSchema(value, schema)
+	want := []string{
+		`genes[1].count: expected integer, got number`,
+		`genes[1]: unexpected field "extra"`,
+		`genes[1].symbol: expected string, got number`,
+		`organism: must be one of human, mouse`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected problems:\n%s\nwant:\n%s", strings.Join(problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestAskJSON_StripsFences(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(textResponse("```json\n{\"genes\":[{\"symbol\":\"TP53\",\"count\":3}],\"organism\":\"human\"}\n```"))
+	g := NewGovernor(WithBackend(mock))
+
+	out, err := AskJSON[geneList](context.Background(), g, ModelHaiku45, "List genes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Genes) != 1 || out.Genes[0].Symbol != "TP53" || out.Genes[0].Count != 3 {
+		t.Errorf("unexpected result: %+v", out)
+	}
+
+	system := mock.Calls()[0].System
+	if !strings.Contains(system, "JSON schema") || !strings.Contains(system, `"organism"`) {
+		t.Errorf("expected schema instruction in system prompt, got %q", system)
+	}
+}
+
+func TestAskJSON_RetriesWithValidationErrors(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponses([]*InvokeResponse{
+		textResponse(`{"genes":[],"organism":"yeast"}`),
+		textResponse(`{"genes":[],"organism":"mouse"}`),
+	})
+	g := NewGovernor(WithBackend(mock))
+
+	out, err := AskJSON[geneList](context.Background(), g, ModelHaiku45, "List genes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Organism != "mouse" {
+		t.Errorf("expected corrected organism, got %q", out.Organism)
+	}
+
+	calls := mock.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	retry := calls[1].Messages
+	if len(retry) != 3 || retry[1].Role != "assistant" {
+		t.Fatalf("expected the invalid reply to be replayed, got %+v", retry)
+	}
+	if !strings.Contains(retry[2].Content[0].Text, "organism: must be one of human, mouse") {
+		t.Errorf("expected validation feedback, got %q", retry[2].Content[0].Text)
+	}
+}
+
+func TestAskJSON_ValidationError(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponse(textResponse("I cannot help with that."))
+	g := NewGovernor(WithBackend(mock))
+
+	_, err := AskJSON[geneList](context.Background(), g, ModelHaiku45, "List genes", WithJSONRetries(1))
+	var verr *JSONValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected JSONValidationError, got %v", err)
+	}
+	if verr.Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", verr.Attempts)
+	}
+	if verr.Raw != "I cannot help with that." {
+		t.Errorf("expected raw reply, got %q", verr.Raw)
+	}
+	if len(mock.Calls()) != 2 {
+		t.Errorf("expected 2 calls, got %d", len(mock.Calls()))
+	}
+}
+
+func TestInvokeJSON_ToolMode(t *testing.T) {
+	mock := NewMockBackend()
+	mock.SetResponses([]*InvokeResponse{
+		MockToolCall("toolu_1", "respond", map[string]interface{}{"genes": []interface{}{}}),
+		MockToolCall("toolu_2", "respond", map[string]interface{}{"genes": []interface{}{}, "organism": "human"}),
+	})
+	g := NewGovernor(WithBackend(mock))
+
+	out, resp, err := InvokeJSON[geneList](context.Background(), g, &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("List genes"))},
+	}, WithJSONToolMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Organism != "human" {
+		t.Errorf("expected organism 'human', got %q", out.Organism)
+	}
+	if resp.ToolCalls()[0].ID != "toolu_2" {
+		t.Errorf("expected last response, got %+v", resp)
+	}
+
+	calls := mock.Calls()
+	first := calls[0]
+	if first.ToolChoice == nil || first.ToolChoice.Name != "respond" || len(first.Tools) != 1 {
+		t.Errorf("expected forced respond tool, got %+v / %+v", first.ToolChoice, first.Tools)
+	}
+	feedback := calls[1].Messages[2].Content[0]
+	if feedback.Type != "tool_result" || feedback.ToolUseID != "toolu_1" || !feedback.IsError {
+		t.Errorf("expected tool error feedback, got %+v", feedback)
+	}
+}
+
+func TestInvokeJSON_ToolModeRejectsNonObjects(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock))
+	ctx := context.Background()
+
+	if _, err := AskJSON[string](ctx, g, ModelHaiku45, "name a gene", WithJSONToolMode()); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("string: expected ErrInvalidRequest, got %v", err)
+	}
+	if _, err := AskJSON[[]geneList](ctx, g, ModelHaiku45, "list genes", WithJSONToolMode()); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("slice: expected ErrInvalidRequest, got %v", err)
+	}
+	if len(mock.Calls()) != 0 {
+		t.Errorf("expected nothing to be sent, got %d calls", len(mock.Calls()))
+	}
+}
@@ -0,0 +1,25 @@
+package llm
+
+// minThinkingBudget is the smallest thinking budget the API accepts.
+const minThinkingBudget = 1024
+
+// validateThinking reports extended thinking settings the API would refuse.
+func validateThinking(req *InvokeRequest, v *requestValidator) {
+	if req.Thinking == nil {
+		return
+	}
+
+	budget := req.Thinking.BudgetTokens
+	if budget < minThinkingBudget {
+		v.report("thinking.budgetTokens", "must be at least %d tokens, got %d", minThinkingBudget, budget)
+	}
+	if req.MaxTokens <= budget {
+		v.report("maxTokens", "must be greater than the thinking budget (%d), got %d", budget, req.MaxTokens)
+	}
+	if req.Temperature != 0 && req.Temperature != 1 {
+		v.report("temperature", "cannot be changed when thinking is enabled")
+	}
+	if req.ToolChoice != nil && (req.ToolChoice.Type == "any" || req.ToolChoice.Type == "tool") {
+		v.report("toolChoice.type", "tool choice %q cannot be forced when thinking is enabled", req.ToolChoice.Type)
+	}
+}
@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidate_Thinking(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     InvokeRequest
+		wantErr string
+	}{
+		{"disabled", InvokeRequest{Temperature: 0.2}, ""},
+		{"valid", InvokeRequest{MaxTokens: 4096, Thinking: &ThinkingConfig{BudgetTokens: 2048}}, ""},
+		{"temperature one", InvokeRequest{MaxTokens: 4096, Temperature: 1, Thinking: &ThinkingConfig{BudgetTokens: 2048}}, ""},
+		{"budget too small", InvokeRequest{MaxTokens: 4096, Thinking: &ThinkingConfig{BudgetTokens: 500}}, "at least 1024"},
+		{"max tokens too small", InvokeRequest{MaxTokens: 2048, Thinking: &ThinkingConfig{BudgetTokens: 2048}}, "must be greater than the thinking budget"},
+		{"temperature", InvokeRequest{MaxTokens: 4096, Temperature: 0.3, Thinking: &ThinkingConfig{BudgetTokens: 2048}}, "temperature"},
+		{"forced tool", InvokeRequest{MaxTokens: 4096, ToolChoice: &ToolChoice{Type: "any"}, Thinking: &ThinkingConfig{BudgetTokens: 2048}}, "tool choice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.Model = ModelSonnet46
+			req.Messages = []Message{UserMessage(TextBlock("hi"))}
+			err := req.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidRequest) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected invalid_request containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGovernor_RejectsTemperatureWithThinking(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock))
+	_, err := g.Invoke(context.Background(), &InvokeRequest{
+		Model:       ModelSonnet46,
+		MaxTokens:   8000,
+		Temperature: 0.5,
+		Thinking:    &ThinkingConfig{BudgetTokens: 4000},
+		Messages:    []Message{UserMessage(TextBlock("hi"))},
+	})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected invalid_request, got %v", err)
+	}
+	if len(mock.Calls()) != 0 {
+		t.Error("expected the request not to reach the backend")
+	}
+}
+
+func TestAnthropicBackend_Thinking(t *testing.T) {
+	var apiReq map[string]interface{}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &apiReq); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`{
+				"model": "claude-sonnet-4-6",
+				"stop_reason": "end_turn",
+				"content": [
+					{"type": "thinking", "thinking": "Let me count.", "signature": "sig123"},
+					{"type": "redacted_thinking", "data": "encrypted"},
+					{"type": "text", "text": "Three."}
+				],
+				"usage": {"input_tokens": 10, "output_tokens": 50}
+			}`)),
+		}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+	resp, err := b.Invoke(context.Background(), &InvokeRequest{
+		Model:     ModelSonnet46,
+		MaxTokens: 4096,
+		Thinking:  &ThinkingConfig{BudgetTokens: 2048},
+		Messages:  []Message{UserMessage(TextBlock("How many r's in strawberry?"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thinking := apiReq["thinking"].(map[string]interface{})
+	if thinking["type"] != "enabled" || thinking["budget_tokens"] != 2048.0 {
+		t.Errorf("unexpected thinking config: %v", thinking)
+	}
+	if resp.Thinking() != "Let me count." {
+		t.Errorf("expected thinking text, got %q", resp.Thinking())
+	}
+	if resp.Text() != "Three." {
+		t.Errorf("expected text 'Three.', got %q", resp.Text())
+	}
+
+	replay := convertMessages([]Message{resp.Message()})[0]["content"].([]interface{})
+	if len(replay) != 3 {
+		t.Fatalf("expected 3 replayed blocks, got %d", len(replay))
+	}
+	first := replay[0].(map[string]interface{})
+	if first["type"] != "thinking" || first["thinking"] != "Let me count." || first["signature"] != "sig123" {
+		t.Errorf("unexpected replayed thinking block: %v", first)
+	}
+	second := replay[1].(map[string]interface{})
+	if second["type"] != "redacted_thinking" || second["data"] != "encrypted" {
+		t.Errorf("unexpected replayed redacted block: %v", second)
+	}
+}
+
+func TestReadAnthropicStream_Thinking(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"type":"message_start","message":{"model":"m","usage":{"input_tokens":1}}}`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Hmm, "}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"three."}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+		`data: {"type":"content_block_stop","index":0}`,
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"3"}}`,
+		`data: {"type":"content_block_stop","index":1}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+		`data: {"type":"message_stop"}`,
+	}, "\n\n")
+
+	s := newStream(context.Background(), func(_ context.Context, emit func(StreamEvent) error) error {
+		return readAnthropicStream(strings.NewReader(body), ModelSonnet46, emit)
+	})
+	collectStream(t, s)
+
+	resp := s.Response()
+	if len(resp.Content) != 2 {
+		t.Fatalf("expected thinking and text blocks, got %+v", resp.Content)
+	}
+	if resp.Thinking() != "Hmm, three." || resp.Content[0].Signature != "sig" {
+		t.Errorf("unexpected thinking block: %+v", resp.Content[0])
+	}
+	if resp.Text() != "3" {
+		t.Errorf("expected text '3', got %q", resp.Text())
+	}
+}
+
+func TestMockBackend_StreamsThinking(t *testing.T) {
+	b := NewMockBackend()
+	b.SetResponse(&InvokeResponse{
+		Content: []ResponseContent{
+			{Type: "thinking", Text: "Considering the question carefully.", Signature: "sig"},
+			{Type: "text", Text: "Answer."},
+		},
+		StopReason: "end_turn",
+	})
+	s, err := b.InvokeStream(context.Background(), &InvokeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collectStream(t, s)
+
+	resp := s.Response()
+	if resp.Thinking() != "Considering the question carefully." || resp.Content[0].Signature != "sig" {
+		t.Errorf("unexpected thinking: %+v", resp.Content[0])
+	}
+	if resp.Text() != "Answer." {
+		t.Errorf("unexpected text %q", resp.Text())
+	}
+}
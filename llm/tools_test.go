@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var weatherTool = Tool{
+	Name:        "get_weather",
+	Description: "Get the weather for a city",
+	InputSchema: map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"city": map[string]interface{}{"type": "string"},
+		},
+		"required": []string{"city"},
+	},
+}
+
+func TestConvertMessages_ToolUseAndResult(t *testing.T) {
+	msgs := []Message{
+		AssistantMessage(ToolUseBlock("toolu_1", "get_weather", json.RawMessage(`{"city":"Paris"}`))),
+		UserMessage(ToolErrorBlock("toolu_1", "service unavailable")),
+	}
+	result := convertMessages(msgs)
+
+	use := result[0]["content"].([]interface{})[0].(map[string]interface{})
+	if use["type"] != "tool_use" || use["id"] != "toolu_1" || use["name"] != "get_weather" {
+		t.Errorf("unexpected tool_use block: %v", use)
+	}
+	if string(use["input"].(json.RawMessage)) != `{"city":"Paris"}` {
+		t.Errorf("unexpected input: %s", use["input"])
+	}
+
+	res := result[1]["content"].([]interface{})[0].(map[string]interface{})
+	if res["type"] != "tool_result" || res["tool_use_id"] != "toolu_1" {
+		t.Errorf("unexpected tool_result block: %v", res)
+	}
+	if res["is_error"] != true {
+		t.Errorf("expected is_error true, got %v", res["is_error"])
+	}
+	inner := res["content"].([]interface{})[0].(map[string]interface{})
+	if inner["text"] != "service unavailable" {
+		t.Errorf("unexpected tool_result content: %v", inner)
+	}
+}
+
+func TestConvertMessages_ToolUseEmptyInput(t *testing.T) {
+	result := convertMessages([]Message{AssistantMessage(ToolUseBlock("toolu_1", "now", nil))})
+	use := result[0]["content"].([]interface{})[0].(map[string]interface{})
+	if string(use["input"].(json.RawMessage)) != "{}" {
+		t.Errorf("expected empty object input, got %s", use["input"])
+	}
+}
+
+func TestAnthropicBackend_InvokeWithTools(t *testing.T) {
+	var apiReq map[string]interface{}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &apiReq); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`{
+				"model": "claude-haiku-4-5-20251001",
+				"stop_reason": "tool_use",
+				"content": [
+					{"type": "text", "text": "Checking."},
+					{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Paris"}}
+				],
+				"usage": {"input_tokens": 10, "output_tokens": 5}
+			}`)),
+		}, nil
+	})}
+	b := NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))
+
+	resp, err := b.Invoke(context.Background(), &InvokeRequest{
+		Model:      ModelHaiku45,
+		Messages:   []Message{UserMessage(TextBlock("Weather in Paris?"))},
+		Tools:      []Tool{weatherTool},
+		ToolChoice: &ToolChoice{Type: "tool", Name: "get_weather"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tools := apiReq["tools"].([]interface{})
+	tool := tools[0].(map[string]interface{})
+	if tool["name"] != "get_weather" || tool["input_schema"] == nil {
+		t.Errorf("unexpected tool definition: %v", tool)
+	}
+	choice := apiReq["tool_choice"].(map[string]interface{})
+	if choice["type"] != "tool" || choice["name"] != "get_weather" {
+		t.Errorf("unexpected tool_choice: %v", choice)
+	}
+
+	calls := resp.ToolCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 tool call, got %d", len(calls))
+	}
+	if calls[0].ID != "toolu_1" || calls[0].Name != "get_weather" {
+		t.Errorf("unexpected tool call: %+v", calls[0])
+	}
+	var input struct{ City string }
+	if err := json.Unmarshal(calls[0].Input, &input); err != nil || input.City != "Paris" {
+		t.Errorf("unexpected tool input %s: %v", calls[0].Input, err)
+	}
+	if resp.Text() != "Checking." {
+		t.Errorf("expected text 'Checking.', got %q", resp.Text())
+	}
+}
+
+func TestReadAnthropicStream_ToolUse(t *testing.T) {
+	body := strings.Join([]string{
+		`data: {"type":"message_start","message":{"model":"m","usage":{"input_tokens":1}}}`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\": "}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`data: {"type":"content_block_stop","index":0}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":4}}`,
+		`data: {"type":"message_stop"}`,
+	}, "\n\n")
+
+	s := newStream(context.Background(), func(_ context.Context, emit func(StreamEvent) error) error {
+		return readAnthropicStream(strings.NewReader(body), ModelHaiku45, emit)
+	})
+	collectStream(t, s)
+
+	calls := s.Response().ToolCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 tool call, got %d", len(calls))
+	}
+	if string(calls[0].Input) != `{"city": "Paris"}` {
+		t.Errorf("unexpected input %s", calls[0].Input)
+	}
+	if s.Response().StopReason != "tool_use" {
+		t.Errorf("expected stop reason tool_use, got %q", s.Response().StopReason)
+	}
+}
+
+func TestMockBackend_ScriptedToolCall(t *testing.T) {
+	b := NewMockBackend()
+	b.SetResponses([]*InvokeResponse{
+		MockToolCall("toolu_1", "get_weather", map[string]string{"city": "Paris"}),
+		{Content: []ResponseContent{{Type: "text", Text: "Sunny"}}, StopReason: "end_turn"},
+	})
+	g := NewGovernor(WithBackend(b))
+
+	req := &InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("Weather in Paris?"))},
+		Tools:    []Tool{weatherTool},
+	}
+	resp, err := g.Invoke(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopReason != "tool_use" || len(resp.ToolCalls()) != 1 {
+		t.Fatalf("expected scripted tool call, got %+v", resp)
+	}
+
+	req.Messages = append(req.Messages,
+		resp.Message(),
+		UserMessage(ToolResultBlock(resp.ToolCalls()[0].ID, "22C and sunny")),
+	)
+	resp, err = g.Invoke(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text() != "Sunny" {
+		t.Errorf("expected 'Sunny', got %q", resp.Text())
+	}
+
+	replay := req.Messages[1]
+	if replay.Role != "assistant" || replay.Content[0].Type != "tool_use" || replay.Content[0].Name != "get_weather" {
+		t.Errorf("unexpected replayed assistant message: %+v", replay)
+	}
+}
+
+func TestInvokeRequest_ToolSerialization(t *testing.T) {
+	req := &InvokeRequest{
+		Action: "invoke",
+		Model:  ModelHaiku45,
+		Messages: []Message{
+			AssistantMessage(ToolUseBlock("toolu_1", "get_weather", json.RawMessage(`{"city":"Paris"}`))),
+			UserMessage(ToolResultBlock("toolu_1", "sunny")),
+		},
+		Tools:      []Tool{weatherTool},
+		ToolChoice: &ToolChoice{Type: "any", DisableParallelToolUse: true},
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{`"tools"`, `"inputSchema"`, `"toolChoice"`, `"toolUseId"`, `"disableParallelToolUse"`} {
+		if !strings.Contains(string(data), key) {
+			t.Errorf("expected %s in payload: %s", key, data)
+		}
+	}
+
+	var parsed InvokeRequest
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Tools[0].Name != "get_weather" || parsed.ToolChoice.Type != "any" {
+		t.Errorf("tools did not round-trip: %+v", parsed)
+	}
+	use := parsed.Messages[0].Content[0]
+	if use.ID != "toolu_1" || string(use.Input) != `{"city":"Paris"}` {
+		t.Errorf("tool_use did not round-trip: %+v", use)
+	}
+	result := parsed.Messages[1].Content[0]
+	if result.ToolUseID != "toolu_1" || result.Content[0].Text != "sunny" {
+		t.Errorf("tool_result did not round-trip: %+v", result)
+	}
+}
@@ -1,5 +1,10 @@
 package llm
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // InvokeRequest is the request payload for an LLM invocation.
 type InvokeRequest struct {
 	Action             string    `json:"action"`
@@ -10,6 +15,53 @@ type InvokeRequest struct {
 	Temperature        float32   `json:"temperature,omitempty"`
 	ExecutionRunID     string    `json:"executionRunId"`
 	ExecutionBudgetUsd float64   `json:"executionBudgetUsd,omitempty"`
+
+	// SystemCacheControl marks the system prompt as cacheable.
+	SystemCacheControl *CacheControl `json:"systemCacheControl,omitempty"`
+
+	// Tools the model may call, and how it should choose among them.
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"toolChoice,omitempty"`
+
+	// Thinking enables extended thinking.
+	Thinking *ThinkingConfig `json:"thinking,omitempty"`
+
+	// Tags label the request for client-side routing (see MatchTag). They
+	// are not sent to the backend.
+	Tags map[string]string `json:"-"`
+
+	// FallbackModels overrides the Governor's WithFallbackModels list for
+	// this request. A non-nil empty list disables fallback.
+	FallbackModels []string `json:"-"`
+}
+
+// ThinkingConfig enables extended thinking with a token budget.
+type ThinkingConfig struct {
+	// BudgetTokens is the maximum number of tokens the model may spend
+	// thinking. It must be at least 1024 and less than MaxTokens.
+	BudgetTokens int32 `json:"budgetTokens"`
+}
+
+// Tool describes a function the model may call.
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+
+	// InputSchema is a JSON schema object describing the tool input.
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// ToolChoice controls how the model uses the tools in a request.
+type ToolChoice struct {
+	// Type is "auto" (model decides), "any" (must call some tool),
+	// "tool" (must call the tool given by Name), or "none".
+	Type string `json:"type"`
+
+	// Tool name (for type "tool").
+	Name string `json:"name,omitempty"`
+
+	// DisableParallelToolUse limits the model to at most one tool call.
+	DisableParallelToolUse bool `json:"disableParallelToolUse,omitempty"`
 }
 
 // Message represents a conversation message with one or more content blocks.
@@ -20,26 +72,59 @@ type Message struct {
 
 // ContentBlock represents a single content block within a message.
 type ContentBlock struct {
-	// Type is the block type: "text", "efs_document", "image", or "document".
+	// Type is the block type: "text", "efs_document", "image", "document",
+	// "tool_use", "tool_result", "thinking", or "redacted_thinking".
 	Type string `json:"type"`
 
-	// Text content (for type "text").
+	// Text content (for type "text") or thinking text (for type "thinking").
 	Text string `json:"text,omitempty"`
 
+	// Thinking signature (for type "thinking").
+	Signature string `json:"signature,omitempty"`
+
 	// EFS file path (for type "efs_document"). Relative to compute node data dir.
 	Path string `json:"path,omitempty"`
 
 	// Format hint (for type "efs_document", "image", "document").
 	Format string `json:"format,omitempty"`
 
-	// Base64-encoded data (for type "image" or "document").
+	// Base64-encoded data (for type "image" or "document"), or encrypted
+	// thinking (for type "redacted_thinking").
 	Data string `json:"data,omitempty"`
 
 	// Media type (for type "image" or "document").
 	MediaType string `json:"mediaType,omitempty"`
 
-	// Document name (for type "document").
+	// Document name (for type "document") or tool name (for type "tool_use").
 	Name string `json:"name,omitempty"`
+
+	// Tool call ID (for type "tool_use").
+	ID string `json:"id,omitempty"`
+
+	// Tool input as a JSON object (for type "tool_use").
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// ID of the tool call being answered (for type "tool_result").
+	ToolUseID string `json:"toolUseId,omitempty"`
+
+	// Result content (for type "tool_result").
+	Content []ContentBlock `json:"content,omitempty"`
+
+	// Whether the tool call failed (for type "tool_result").
+	IsError bool `json:"isError,omitempty"`
+
+	// CacheControl marks the prompt up to and including this block as
+	// cacheable (any type).
+	CacheControl *CacheControl `json:"cacheControl,omitempty"`
+}
+
+// CacheControl marks content as a prompt cache breakpoint.
+type CacheControl struct {
+	// Type is always "ephemeral".
+	Type string `json:"type"`
+
+	// TTL is "5m" (the default when empty) or "1h".
+	TTL string `json:"ttl,omitempty"`
 }
 
 // InvokeResponse is the response from a successful LLM invocation.
@@ -49,19 +134,48 @@ type InvokeResponse struct {
 	Usage           UsageInfo         `json:"usage"`
 	BudgetRemaining BudgetInfo        `json:"budgetRemaining"`
 	StopReason      string            `json:"stopReason,omitempty"`
+
+	// FallbackUsed is set by the Governor when a fallback model answered
+	// instead of the requested one, and FallbackModel is then the ID of
+	// that fallback model as configured. Model is the model the provider
+	// reported, or the configured ID if it reported none.
+	FallbackUsed  bool   `json:"fallbackUsed,omitempty"`
+	FallbackModel string `json:"fallbackModel,omitempty"`
+
+	// TokenCount and Models are for interceptors only: they hold the
+	// result of a count-tokens or list-models call as the interceptor
+	// chain sees it. Invoke clears them, so its callers never see them.
+	TokenCount *CountTokensResponse `json:"-"`
+	Models     []ModelInfo          `json:"-"`
 }
 
 // ResponseContent represents a content block in the model's response.
+// Type is "text", "tool_use", "thinking", or "redacted_thinking".
 type ResponseContent struct {
 	Type string `json:"type"`
+
+	// Text (for type "text") or thinking text (for type "thinking").
 	Text string `json:"text"`
+
+	// Thinking signature (for type "thinking") or encrypted thinking (for
+	// type "redacted_thinking"). Both must be passed back unchanged.
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	// Tool call ID, tool name and JSON input (for type "tool_use").
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
 }
 
 // UsageInfo holds token usage and cost information.
+// InputTokens excludes tokens written to or read from the prompt cache.
 type UsageInfo struct {
-	InputTokens      int64   `json:"inputTokens"`
-	OutputTokens     int64   `json:"outputTokens"`
-	EstimatedCostUsd float64 `json:"estimatedCostUsd"`
+	InputTokens              int64   `json:"inputTokens"`
+	OutputTokens             int64   `json:"outputTokens"`
+	CacheCreationInputTokens int64   `json:"cacheCreationInputTokens,omitempty"`
+	CacheReadInputTokens     int64   `json:"cacheReadInputTokens,omitempty"`
+	EstimatedCostUsd         float64 `json:"estimatedCostUsd"`
 }
 
 // BudgetInfo holds remaining budget information.
@@ -98,6 +212,77 @@ type ListModelsResponse struct {
 	Models []ModelInfo `json:"models"`
 }
 
+// CountTokensResponse is the response from a count-tokens action.
+type CountTokensResponse struct {
+	// InputTokens is the total number of input tokens in the request.
+	InputTokens int64 `json:"inputTokens"`
+
+	// MessageTokens holds the tokens contributed by each message, in
+	// order. The first entry also includes the system prompt and tool
+	// definitions, so the entries sum to InputTokens. AnthropicBackend
+	// can only count whole requests, so it apportions InputTokens by the
+	// local estimate of each message.
+	MessageTokens []int64 `json:"messageTokens"`
+}
+
+// BatchRequest is one request in a message batch. CustomID identifies
+// the request's result and must be unique within the batch.
+type BatchRequest struct {
+	CustomID string         `json:"customId"`
+	Request  *InvokeRequest `json:"request"`
+}
+
+// SubmitBatchRequest is the payload of a submit-batch action.
+type SubmitBatchRequest struct {
+	Action         string         `json:"action"`
+	ExecutionRunID string         `json:"executionRunId"`
+	Requests       []BatchRequest `json:"requests"`
+}
+
+// Batch describes a submitted message batch and its progress.
+type Batch struct {
+	ID string `json:"batchId"`
+
+	// Status is "in_progress" while requests are being processed,
+	// "canceling" while a cancellation is in progress, and "ended" once
+	// every request has a result.
+	Status string      `json:"status"`
+	Counts BatchCounts `json:"counts"`
+
+	CreatedAt time.Time  `json:"createdAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+}
+
+// Ended reports whether every request in the batch has a result.
+func (b *Batch) Ended() bool {
+	return b.Status == "ended"
+}
+
+// BatchCounts tallies the requests of a batch by state.
+type BatchCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// BatchResult is the outcome of one request in a batch: a response, or
+// an error in the governor's error format.
+type BatchResult struct {
+	CustomID string          `json:"customId"`
+	Response *InvokeResponse `json:"response,omitempty"`
+	Error    *ErrorResponse  `json:"error,omitempty"`
+}
+
+// BatchResultsResponse is one page of a batch-results action. NextToken
+// is set when more results remain.
+type BatchResultsResponse struct {
+	Results   []BatchResult `json:"results"`
+	NextToken string        `json:"nextToken,omitempty"`
+}
+
 // ErrorResponse is returned by the governor on errors.
 type ErrorResponse struct {
 	Error           string      `json:"error"`
@@ -107,4 +292,4 @@ type ErrorResponse struct {
 	MaxSizeBytes    int64       `json:"maxSizeBytes,omitempty"`
 	RetryAfterSec   int         `json:"retryAfterSeconds,omitempty"`
 	Model           string      `json:"model,omitempty"`
-}
\ No newline at end of file
+}
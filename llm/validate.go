@@ -0,0 +1,254 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FieldError is a single problem found by InvokeRequest.Validate.
+type FieldError struct {
+	// Path locates the offending field, e.g. "messages[2].content[0].format".
+	Path string
+	Msg  string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// ValidationError lists every problem found in an InvokeRequest. It
+// matches ErrInvalidRequest with errors.Is, and each problem is available
+// as a *FieldError through errors.As or the Problems field.
+type ValidationError struct {
+	Problems []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrInvalidRequest.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+// Unwrap returns the individual problems.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Problems))
+	for i, p := range e.Problems {
+		errs[i] = p
+	}
+	return errs
+}
+
+// requestValidator collects problems found while walking a request.
+type requestValidator struct {
+	problems []*FieldError
+}
+
+func (v *requestValidator) report(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, &FieldError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the request for problems the governor or provider would
+// reject, such as an empty conversation, messages that do not alternate
+// between user and assistant, incomplete content blocks or out-of-range
+// settings. It returns a *ValidationError listing every problem, or nil.
+//
+// Validate does not check efs_document paths, whose meaning depends on the
+// backend: LambdaBackend rejects paths that are absolute or leave the
+// governor's data directory, while AnthropicBackend reads any local path.
+//
+// Governor.Invoke, Governor.InvokeStream, Governor.CountTokens and
+// Governor.SubmitBatch call Validate before sending a request unless the
+// Governor was created with WithRequestValidation(false).
+func (r *InvokeRequest) Validate() error {
+	v := &requestValidator{}
+
+	if r.Model == "" {
+		v.report("model", "is required")
+	}
+	if r.MaxTokens < 0 {
+		v.report("maxTokens", "must not be negative")
+	}
+	if r.Temperature < 0 || r.Temperature > 1 {
+		v.report("temperature", "must be between 0 and 1, got %g", r.Temperature)
+	}
+	if r.ExecutionBudgetUsd < 0 {
+		v.report("executionBudgetUsd", "must not be negative")
+	}
+	validateMessages(r.Messages, v)
+	validateTools(r, v)
+	validateThinking(r, v)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func validateMessages(messages []Message, v *requestValidator) {
+	if len(messages) == 0 {
+		v.report("messages", "must not be empty")
+		return
+	}
+	for i, m := range messages {
+		p := fmt.Sprintf("messages[%d]", i)
+		switch {
+		case m.Role != "user" && m.Role != "assistant":
+			v.report(p+".role", "must be \"user\" or \"assistant\", got %q", m.Role)
+		case i == 0 && m.Role != "user":
+			v.report(p+".role", "the first message must be from the user")
+		case i > 0 && m.Role == messages[i-1].Role:
+			v.report(p+".role", "must alternate with the previous message, both are %q", m.Role)
+		}
+		if len(m.Content) == 0 {
+			v.report(p+".content", "must not be empty")
+		}
+		for j, block := range m.Content {
+			validateBlock(block, fmt.Sprintf("%s.content[%d]", p, j), v)
+		}
+	}
+}
+
+func validateBlock(b ContentBlock, p string, v *requestValidator) {
+	switch b.Type {
+	case "text":
+		if b.Text == "" {
+			v.report(p+".text", "must not be empty")
+		}
+	case "image":
+		if b.Format == "" && b.MediaType == "" {
+			v.report(p+".format", "image requires a format or mediaType")
+		}
+		if b.Data == "" {
+			v.report(p+".data", "is required")
+		}
+	case "document":
+		if b.Format == "" && b.MediaType == "" {
+			v.report(p+".format", "document requires a format or mediaType")
+		}
+		if b.Data == "" {
+			v.report(p+".data", "is required")
+		}
+	case "efs_document":
+		if b.Path == "" {
+			v.report(p+".path", "is required")
+		}
+	case "tool_use":
+		if b.ID == "" {
+			v.report(p+".id", "is required")
+		}
+		if b.Name == "" {
+			v.report(p+".name", "is required")
+		}
+		if len(b.Input) > 0 && !json.Valid(b.Input) {
+			v.report(p+".input", "is not valid JSON")
+		}
+	case "tool_result":
+		if b.ToolUseID == "" {
+			v.report(p+".toolUseId", "is required")
+		}
+		for k, inner := range b.Content {
+			validateBlock(inner, fmt.Sprintf("%s.content[%d]", p, k), v)
+		}
+	case "thinking":
+		if b.Signature == "" {
+			v.report(p+".signature", "is required when replaying thinking")
+		}
+	case "redacted_thinking":
+		if b.Data == "" {
+			v.report(p+".data", "is required")
+		}
+	case "":
+		v.report(p+".type", "is required")
+	default:
+		v.report(p+".type", "unsupported content block type %q", b.Type)
+	}
+	if b.CacheControl != nil {
+		validateCacheControl(b.CacheControl, p+".cacheControl", v)
+	}
+}
+
+func validateCacheControl(cc *CacheControl, p string, v *requestValidator) {
+	if cc.Type != "ephemeral" {
+		v.report(p+".type", "must be \"ephemeral\", got %q", cc.Type)
+	}
+	if cc.TTL != "" && cc.TTL != "5m" && cc.TTL != "1h" {
+		v.report(p+".ttl", "must be \"5m\" or \"1h\", got %q", cc.TTL)
+	}
+}
+
+func validateTools(r *InvokeRequest, v *requestValidator) {
+	names := map[string]bool{}
+	for i, tool := range r.Tools {
+		p := fmt.Sprintf("tools[%d]", i)
+		switch {
+		case tool.Name == "":
+			v.report(p+".name", "is required")
+		case names[tool.Name]:
+			v.report(p+".name", "duplicate tool name %q", tool.Name)
+		}
+		names[tool.Name] = true
+		if tool.InputSchema == nil {
+			v.report(p+".inputSchema", "is required")
+		}
+	}
+	if r.SystemCacheControl != nil {
+		validateCacheControl(r.SystemCacheControl, "systemCacheControl", v)
+	}
+
+	tc := r.ToolChoice
+	if tc == nil {
+		return
+	}
+	switch tc.Type {
+	case "auto", "any", "none":
+	case "tool":
+		if !names[tc.Name] {
+			v.report("toolChoice.name", "must name one of the request's tools, got %q", tc.Name)
+		}
+	default:
+		v.report("toolChoice.type", "must be \"auto\", \"any\", \"tool\" or \"none\", got %q", tc.Type)
+	}
+	if tc.Type != "none" && tc.Type != "auto" && len(r.Tools) == 0 {
+		v.report("tools", "must not be empty when tool choice is %q", tc.Type)
+	}
+}
+
+// validateEFSPaths checks that the efs_document paths in messages are
+// relative to the governor's data directory and stay inside it. Only the
+// governor reads paths that way; AnthropicBackend reads local files, so
+// LambdaBackend checks them rather than Validate.
+func validateEFSPaths(messages []Message, prefix string) error {
+	v := &requestValidator{}
+	for i, m := range messages {
+		for j, block := range m.Content {
+			checkEFSPath(block, fmt.Sprintf("%smessages[%d].content[%d]", prefix, i, j), v)
+		}
+	}
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+func checkEFSPath(b ContentBlock, p string, v *requestValidator) {
+	if b.Type == "efs_document" && b.Path != "" {
+		switch {
+		case strings.HasPrefix(b.Path, "/"):
+			v.report(p+".path", "must be relative to the data directory, got %q", b.Path)
+		case path.Clean(b.Path) == ".." || strings.HasPrefix(path.Clean(b.Path), "../"):
+			v.report(p+".path", "must not escape the data directory, got %q", b.Path)
+		}
+	}
+	for k, inner := range b.Content {
+		checkEFSPath(inner, fmt.Sprintf("%s.content[%d]", p, k), v)
+	}
+}
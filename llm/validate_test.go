@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func validRequest() InvokeRequest {
+	return InvokeRequest{
+		Model:    ModelHaiku45,
+		Messages: []Message{UserMessage(TextBlock("hi"))},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *InvokeRequest)
+		paths  []string
+	}{
+		{"valid", func(r *InvokeRequest) {}, nil},
+		{"missing model", func(r *InvokeRequest) { r.Model = "" }, []string{"model"}},
+		{"empty messages", func(r *InvokeRequest) { r.Messages = nil }, []string{"messages"}},
+		{"assistant first", func(r *InvokeRequest) {
+			r.Messages = []Message{AssistantMessage(TextBlock("hello"))}
+		}, []string{"messages[0].role"}},
+		{"same role twice", func(r *InvokeRequest) {
+			r.Messages = append(r.Messages, UserMessage(TextBlock("again")))
+		}, []string{"messages[1].role"}},
+		{"unknown role", func(r *InvokeRequest) {
+			r.Messages = append(r.Messages, Message{Role: "system", Content: []ContentBlock{TextBlock("x")}})
+		}, []string{"messages[1].role"}},
+		{"empty content", func(r *InvokeRequest) { r.Messages[0].Content = nil }, []string{"messages[0].content"}},
+		{"image without format", func(r *InvokeRequest) {
+			r.Messages = []Message{
+				UserMessage(TextBlock("a")),
+				AssistantMessage(TextBlock("b")),
+				UserMessage(ImageBlock("", "aGk=")),
+			}
+		}, []string{"messages[2].content[0].format"}},
+		{"empty efs path", func(r *InvokeRequest) {
+			r.Messages[0].Content = append(r.Messages[0].Content, FileBlock(""))
+		}, []string{"messages[0].content[1].path"}},
+		{"absolute efs path", func(r *InvokeRequest) {
+			r.Messages[0].Content = append(r.Messages[0].Content, FileBlock("/data/paper.pdf"))
+		}, nil},
+		{"temperature out of range", func(r *InvokeRequest) { r.Temperature = 1.5 }, []string{"temperature"}},
+		{"tool result without id", func(r *InvokeRequest) {
+			r.Messages[0].Content = []ContentBlock{ToolResultBlock("", "done")}
+		}, []string{"messages[0].content[0].toolUseId"}},
+		{"nested empty text", func(r *InvokeRequest) {
+			r.Messages[0].Content = []ContentBlock{ToolResultBlock("call-1", "")}
+		}, []string{"messages[0].content[0].content[0].text"}},
+		{"unknown block type", func(r *InvokeRequest) {
+			r.Messages[0].Content = []ContentBlock{{Type: "video"}}
+		}, []string{"messages[0].content[0].type"}},
+		{"bad cache ttl", func(r *InvokeRequest) {
+			r.Messages[0].Content[0] = CachedBlock(TextBlock("hi"), "10m")
+		}, []string{"messages[0].content[0].cacheControl.ttl"}},
+		{"tool choice names missing tool", func(r *InvokeRequest) {
+			r.Tools = []Tool{{Name: "lookup", InputSchema: map[string]interface{}{"type": "object"}}}
+			r.ToolChoice = &ToolChoice{Type: "tool", Name: "search"}
+		}, []string{"toolChoice.name"}},
+		{"duplicate tool", func(r *InvokeRequest) {
+			schema := map[string]interface{}{"type": "object"}
+			r.Tools = []Tool{{Name: "lookup", InputSchema: schema}, {Name: "lookup", InputSchema: schema}}
+		}, []string{"tools[1].name"}},
+		{"several problems", func(r *InvokeRequest) {
+			r.Model = ""
+			r.Temperature = -1
+			r.Messages[0].Content = append(r.Messages[0].Content, ImageBlock("", ""))
+		}, []string{"model", "temperature", "messages[0].content[1].format", "messages[0].content[1].data"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(&req)
+			err := req.Validate()
+			if tt.paths == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if !errors.Is(err, ErrInvalidRequest) {
+				t.Error("expected errors.Is(err, ErrInvalidRequest)")
+			}
+			if len(verr.Problems) != len(tt.paths) {
+				t.Fatalf("expected %d problems, got %v", len(tt.paths), err)
+			}
+			for i, p := range tt.paths {
+				if verr.Problems[i].Path != p {
+					t.Errorf("problem %d: expected path %q, got %q", i, p, verr.Problems[i].Path)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationError_Unwrap(t *testing.T) {
+	req := validRequest()
+	req.Model = ""
+	err := req.Validate()
+
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Path != "model" {
+		t.Errorf("expected FieldError for model, got %v", err)
+	}
+	if IsRetryable(err) {
+		t.Error("validation errors should not be retryable")
+	}
+}
+
+func TestGovernor_ValidatesRequests(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock))
+
+	_, err := g.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected invalid request, got %v", err)
+	}
+	if _, err := g.InvokeStream(context.Background(), &InvokeRequest{Model: ModelHaiku45}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected InvokeStream to validate too, got %v", err)
+	}
+	if len(mock.Calls()) != 0 {
+		t.Error("expected invalid requests not to reach the backend")
+	}
+}
+
+func TestGovernor_ValidationDisabled(t *testing.T) {
+	mock := NewMockBackend()
+	g := NewGovernor(WithBackend(mock), WithRequestValidation(false))
+
+	if _, err := g.Invoke(context.Background(), &InvokeRequest{Model: ModelHaiku45}); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.Calls()) != 1 {
+		t.Error("expected the request to reach the backend")
+	}
+}
+
+func TestLambdaBackend_RejectsEFSPathsOutsideDataDir(t *testing.T) {
+	client := NewMockLambdaClient()
+	g := NewGovernor(WithBackend(NewLambdaBackend("llm-governor", client)))
+
+	for _, p := range []string{"/etc/passwd", "input/../../secret"} {
+		_, err := g.AskAboutFile(context.Background(), ModelHaiku45, "summarize", p)
+		var verr *ValidationError
+		if !errors.As(err, &verr) || verr.Problems[0].Path != "messages[0].content[1].path" {
+			t.Errorf("%s: expected a path problem, got %v", p, err)
+		}
+	}
+	if len(client.Payloads()) != 0 {
+		t.Error("expected rejected paths not to reach the governor")
+	}
+}
+
+func TestAnthropicBackend_AskAboutLocalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paper.pdf")
+	if err := os.WriteFile(path, []byte("fake pdf content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var apiReq map[string]interface{}
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if err := json.NewDecoder(r.Body).Decode(&apiReq); err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(
+				`{"model":"claude-haiku-4-5","stop_reason":"end_turn","content":[{"type":"text","text":"a paper"}]}`)),
+		}, nil
+	})}
+	g := NewGovernor(WithBackend(NewAnthropicBackend(WithAPIKey("test"), WithHTTPClient(client))))
+
+	text, err := g.AskAboutFile(context.Background(), ModelHaiku45, "summarize", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "a paper" {
+		t.Errorf("expected the model's answer, got %q", text)
+	}
+	content := apiReq["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+	if doc := content[1].(map[string]interface{}); doc["type"] != "document" {
+		t.Errorf("expected the local file to be sent as a document, got %v", doc)
+	}
+}